@@ -0,0 +1,341 @@
+package v1alpha1
+
+import (
+	"sigs.k8s.io/controller-runtime/pkg/conversion"
+
+	"github.com/webserver/webserver-operator/api/v1beta1"
+)
+
+// metricsFeature is the Features key that maps to the typed v1beta1 metrics toggle
+const metricsFeature = "metrics"
+
+// ConvertTo converts this Webserver to the v1beta1 hub version
+func (src *Webserver) ConvertTo(dstRaw conversion.Hub) error {
+	dst := dstRaw.(*v1beta1.Webserver)
+	dst.ObjectMeta = src.ObjectMeta
+
+	dst.Spec.Replicas = src.Spec.Replicas
+	dst.Spec.Image = src.Spec.Image
+	dst.Spec.Port = src.Spec.Port
+	dst.Spec.ServiceType = v1beta1.ServiceType(src.Spec.ServiceType)
+	dst.Spec.Content = v1beta1.WebserverContent{
+		Title:         src.Spec.Config.Title,
+		Message:       src.Spec.Config.Message,
+		Color:         src.Spec.Config.Color,
+		DefaultLocale: src.Spec.Config.DefaultLocale,
+	}
+	for code, locale := range src.Spec.Config.Locales {
+		if dst.Spec.Content.Locales == nil {
+			dst.Spec.Content.Locales = map[string]v1beta1.LocaleContent{}
+		}
+		dst.Spec.Content.Locales[code] = v1beta1.LocaleContent{
+			Title:    locale.Title,
+			Message:  locale.Message,
+			Template: locale.Template,
+		}
+	}
+	dst.Spec.Features = v1beta1.WebserverFeatures{}
+	for name, enabled := range src.Spec.Config.Features {
+		if name == metricsFeature {
+			dst.Spec.Features.Metrics = &v1beta1.FeatureToggle{Enabled: enabled}
+			continue
+		}
+		if dst.Spec.Features.Extra == nil {
+			dst.Spec.Features.Extra = map[string]bool{}
+		}
+		dst.Spec.Features.Extra[name] = enabled
+	}
+	dst.Spec.ClassName = src.Spec.ClassName
+	dst.Spec.Schedule = nil
+	for _, window := range src.Spec.Schedule {
+		dst.Spec.Schedule = append(dst.Spec.Schedule, v1beta1.ScheduleWindow{
+			Name:     window.Name,
+			Cron:     window.Cron,
+			Duration: window.Duration,
+			TimeZone: window.TimeZone,
+			Replicas: window.Replicas,
+		})
+	}
+	dst.Spec.TTL = src.Spec.TTL
+	dst.Spec.ExtendTTLOnActivity = src.Spec.ExtendTTLOnActivity
+	dst.Spec.SecurityContext = src.Spec.SecurityContext
+	dst.Spec.ExtraContainers = src.Spec.ExtraContainers
+	dst.Spec.InitContainers = src.Spec.InitContainers
+	dst.Spec.ExtraVolumes = src.Spec.ExtraVolumes
+	dst.Spec.ExtraVolumeMounts = src.Spec.ExtraVolumeMounts
+	dst.Spec.Env = src.Spec.Env
+	dst.Spec.EnvFrom = src.Spec.EnvFrom
+	dst.Spec.CommonLabels = src.Spec.CommonLabels
+	dst.Spec.CommonAnnotations = src.Spec.CommonAnnotations
+	dst.Spec.PodAnnotations = src.Spec.PodAnnotations
+	dst.Spec.Disruption = nil
+	if src.Spec.Disruption != nil {
+		dst.Spec.Disruption = &v1beta1.DisruptionSpec{
+			MinAvailable:   src.Spec.Disruption.MinAvailable,
+			MaxUnavailable: src.Spec.Disruption.MaxUnavailable,
+		}
+	}
+	dst.Spec.Service = nil
+	if src.Spec.Service != nil {
+		dst.Spec.Service = &v1beta1.ServiceSpec{
+			Annotations:              src.Spec.Service.Annotations,
+			LoadBalancerSourceRanges: src.Spec.Service.LoadBalancerSourceRanges,
+			ExternalTrafficPolicy:    src.Spec.Service.ExternalTrafficPolicy,
+			SessionAffinity:          src.Spec.Service.SessionAffinity,
+			Headless:                 src.Spec.Service.Headless,
+		}
+		for _, port := range src.Spec.Service.Ports {
+			dst.Spec.Service.Ports = append(dst.Spec.Service.Ports, v1beta1.ServicePort{
+				Name:       port.Name,
+				Port:       port.Port,
+				TargetPort: port.TargetPort,
+				NodePort:   port.NodePort,
+			})
+		}
+	}
+	dst.Spec.Bundle = nil
+	if src.Spec.Bundle != nil {
+		dst.Spec.Bundle = &v1beta1.ContentBundle{
+			URL:    src.Spec.Bundle.URL,
+			SHA256: src.Spec.Bundle.SHA256,
+		}
+	}
+
+	dst.Status = v1beta1.WebserverStatus{
+		Conditions:          src.Status.Conditions,
+		ObservedGeneration:  src.Status.ObservedGeneration,
+		ReadyReplicas:       src.Status.ReadyReplicas,
+		Phase:               src.Status.Phase,
+		ClassName:           src.Status.ClassName,
+		URL:                 src.Status.URL,
+		InternalURL:         src.Status.InternalURL,
+		LoadBalancerIngress: src.Status.LoadBalancerIngress,
+		NodePort:            src.Status.NodePort,
+	}
+	if src.Status.Image != nil {
+		dst.Status.Image = &v1beta1.ImageStatus{
+			Requested: src.Status.Image.Requested,
+			Digest:    src.Status.Image.Digest,
+			Deployed:  src.Status.Image.Deployed,
+		}
+	}
+	if src.Status.ContentVerification != nil {
+		dst.Status.ContentVerification = &v1beta1.ContentVerificationStatus{
+			Marker:              src.Status.ContentVerification.Marker,
+			LatencyMilliseconds: src.Status.ContentVerification.LatencyMilliseconds,
+			LastCheckTime:       src.Status.ContentVerification.LastCheckTime,
+		}
+	}
+	if src.Status.ActiveSchedule != nil {
+		dst.Status.ActiveSchedule = &v1beta1.ActiveSchedule{
+			Name:     src.Status.ActiveSchedule.Name,
+			Replicas: src.Status.ActiveSchedule.Replicas,
+			Until:    src.Status.ActiveSchedule.Until,
+		}
+	}
+	dst.Status.NextScheduleTime = src.Status.NextScheduleTime
+	dst.Status.ExpiresAt = src.Status.ExpiresAt
+	dst.Status.LastActivityTime = src.Status.LastActivityTime
+	if src.Status.PodSummary != nil {
+		dst.Status.PodSummary = &v1beta1.PodSummary{
+			Total:    src.Status.PodSummary.Total,
+			Ready:    src.Status.PodSummary.Ready,
+			Pending:  src.Status.PodSummary.Pending,
+			Running:  src.Status.PodSummary.Running,
+			Failed:   src.Status.PodSummary.Failed,
+			Restarts: src.Status.PodSummary.Restarts,
+		}
+		for _, issue := range src.Status.PodSummary.Issues {
+			dst.Status.PodSummary.Issues = append(dst.Status.PodSummary.Issues, v1beta1.PodIssue{
+				Pod:       issue.Pod,
+				Container: issue.Container,
+				Reason:    issue.Reason,
+				Message:   issue.Message,
+				Restarts:  issue.Restarts,
+			})
+		}
+	}
+	if src.Status.ContentManifest != nil {
+		dst.Status.ContentManifest = &v1beta1.ContentManifest{
+			URL:        src.Status.ContentManifest.URL,
+			SHA256:     src.Status.ContentManifest.SHA256,
+			TotalBytes: src.Status.ContentManifest.TotalBytes,
+			Shards:     src.Status.ContentManifest.Shards,
+		}
+		for _, file := range src.Status.ContentManifest.Files {
+			dst.Status.ContentManifest.Files = append(dst.Status.ContentManifest.Files, v1beta1.ContentFile{
+				Path:  file.Path,
+				Size:  file.Size,
+				Shard: file.Shard,
+			})
+		}
+	}
+
+	return nil
+}
+
+// ConvertFrom converts the v1beta1 hub version to this Webserver
+func (dst *Webserver) ConvertFrom(srcRaw conversion.Hub) error {
+	src := srcRaw.(*v1beta1.Webserver)
+	dst.ObjectMeta = src.ObjectMeta
+
+	dst.Spec.Replicas = src.Spec.Replicas
+	dst.Spec.Image = src.Spec.Image
+	dst.Spec.Port = src.Spec.Port
+	dst.Spec.ServiceType = string(src.Spec.ServiceType)
+	dst.Spec.Config = WebserverConfig{
+		Title:         src.Spec.Content.Title,
+		Message:       src.Spec.Content.Message,
+		Color:         src.Spec.Content.Color,
+		DefaultLocale: src.Spec.Content.DefaultLocale,
+	}
+	for code, locale := range src.Spec.Content.Locales {
+		if dst.Spec.Config.Locales == nil {
+			dst.Spec.Config.Locales = map[string]LocaleContent{}
+		}
+		dst.Spec.Config.Locales[code] = LocaleContent{
+			Title:    locale.Title,
+			Message:  locale.Message,
+			Template: locale.Template,
+		}
+	}
+	for name, enabled := range src.Spec.Features.Extra {
+		if dst.Spec.Config.Features == nil {
+			dst.Spec.Config.Features = map[string]bool{}
+		}
+		dst.Spec.Config.Features[name] = enabled
+	}
+	if src.Spec.Features.Metrics != nil {
+		if dst.Spec.Config.Features == nil {
+			dst.Spec.Config.Features = map[string]bool{}
+		}
+		dst.Spec.Config.Features[metricsFeature] = src.Spec.Features.Metrics.Enabled
+	}
+	dst.Spec.ClassName = src.Spec.ClassName
+	dst.Spec.Schedule = nil
+	for _, window := range src.Spec.Schedule {
+		dst.Spec.Schedule = append(dst.Spec.Schedule, ScheduleWindow{
+			Name:     window.Name,
+			Cron:     window.Cron,
+			Duration: window.Duration,
+			TimeZone: window.TimeZone,
+			Replicas: window.Replicas,
+		})
+	}
+	dst.Spec.TTL = src.Spec.TTL
+	dst.Spec.ExtendTTLOnActivity = src.Spec.ExtendTTLOnActivity
+	dst.Spec.SecurityContext = src.Spec.SecurityContext
+	dst.Spec.ExtraContainers = src.Spec.ExtraContainers
+	dst.Spec.InitContainers = src.Spec.InitContainers
+	dst.Spec.ExtraVolumes = src.Spec.ExtraVolumes
+	dst.Spec.ExtraVolumeMounts = src.Spec.ExtraVolumeMounts
+	dst.Spec.Env = src.Spec.Env
+	dst.Spec.EnvFrom = src.Spec.EnvFrom
+	dst.Spec.CommonLabels = src.Spec.CommonLabels
+	dst.Spec.CommonAnnotations = src.Spec.CommonAnnotations
+	dst.Spec.PodAnnotations = src.Spec.PodAnnotations
+	dst.Spec.Disruption = nil
+	if src.Spec.Disruption != nil {
+		dst.Spec.Disruption = &DisruptionSpec{
+			MinAvailable:   src.Spec.Disruption.MinAvailable,
+			MaxUnavailable: src.Spec.Disruption.MaxUnavailable,
+		}
+	}
+	dst.Spec.Service = nil
+	if src.Spec.Service != nil {
+		dst.Spec.Service = &ServiceSpec{
+			Annotations:              src.Spec.Service.Annotations,
+			LoadBalancerSourceRanges: src.Spec.Service.LoadBalancerSourceRanges,
+			ExternalTrafficPolicy:    src.Spec.Service.ExternalTrafficPolicy,
+			SessionAffinity:          src.Spec.Service.SessionAffinity,
+			Headless:                 src.Spec.Service.Headless,
+		}
+		for _, port := range src.Spec.Service.Ports {
+			dst.Spec.Service.Ports = append(dst.Spec.Service.Ports, ServicePort{
+				Name:       port.Name,
+				Port:       port.Port,
+				TargetPort: port.TargetPort,
+				NodePort:   port.NodePort,
+			})
+		}
+	}
+	dst.Spec.Bundle = nil
+	if src.Spec.Bundle != nil {
+		dst.Spec.Bundle = &ContentBundle{
+			URL:    src.Spec.Bundle.URL,
+			SHA256: src.Spec.Bundle.SHA256,
+		}
+	}
+
+	dst.Status = WebserverStatus{
+		Conditions:          src.Status.Conditions,
+		ObservedGeneration:  src.Status.ObservedGeneration,
+		ReadyReplicas:       src.Status.ReadyReplicas,
+		Phase:               src.Status.Phase,
+		ClassName:           src.Status.ClassName,
+		URL:                 src.Status.URL,
+		InternalURL:         src.Status.InternalURL,
+		LoadBalancerIngress: src.Status.LoadBalancerIngress,
+		NodePort:            src.Status.NodePort,
+	}
+	if src.Status.Image != nil {
+		dst.Status.Image = &ImageStatus{
+			Requested: src.Status.Image.Requested,
+			Digest:    src.Status.Image.Digest,
+			Deployed:  src.Status.Image.Deployed,
+		}
+	}
+	if src.Status.ContentVerification != nil {
+		dst.Status.ContentVerification = &ContentVerificationStatus{
+			Marker:              src.Status.ContentVerification.Marker,
+			LatencyMilliseconds: src.Status.ContentVerification.LatencyMilliseconds,
+			LastCheckTime:       src.Status.ContentVerification.LastCheckTime,
+		}
+	}
+	if src.Status.ActiveSchedule != nil {
+		dst.Status.ActiveSchedule = &ActiveSchedule{
+			Name:     src.Status.ActiveSchedule.Name,
+			Replicas: src.Status.ActiveSchedule.Replicas,
+			Until:    src.Status.ActiveSchedule.Until,
+		}
+	}
+	dst.Status.NextScheduleTime = src.Status.NextScheduleTime
+	dst.Status.ExpiresAt = src.Status.ExpiresAt
+	dst.Status.LastActivityTime = src.Status.LastActivityTime
+	if src.Status.PodSummary != nil {
+		dst.Status.PodSummary = &PodSummary{
+			Total:    src.Status.PodSummary.Total,
+			Ready:    src.Status.PodSummary.Ready,
+			Pending:  src.Status.PodSummary.Pending,
+			Running:  src.Status.PodSummary.Running,
+			Failed:   src.Status.PodSummary.Failed,
+			Restarts: src.Status.PodSummary.Restarts,
+		}
+		for _, issue := range src.Status.PodSummary.Issues {
+			dst.Status.PodSummary.Issues = append(dst.Status.PodSummary.Issues, PodIssue{
+				Pod:       issue.Pod,
+				Container: issue.Container,
+				Reason:    issue.Reason,
+				Message:   issue.Message,
+				Restarts:  issue.Restarts,
+			})
+		}
+	}
+	if src.Status.ContentManifest != nil {
+		dst.Status.ContentManifest = &ContentManifest{
+			URL:        src.Status.ContentManifest.URL,
+			SHA256:     src.Status.ContentManifest.SHA256,
+			TotalBytes: src.Status.ContentManifest.TotalBytes,
+			Shards:     src.Status.ContentManifest.Shards,
+		}
+		for _, file := range src.Status.ContentManifest.Files {
+			dst.Status.ContentManifest.Files = append(dst.Status.ContentManifest.Files, ContentFile{
+				Path:  file.Path,
+				Size:  file.Size,
+				Shard: file.Shard,
+			})
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,90 @@
+package v1alpha1
+
+import (
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/util/diff"
+	"sigs.k8s.io/randfill"
+
+	"github.com/webserver/webserver-operator/api/v1beta1"
+)
+
+const fuzzIterations = 500
+
+func newFiller() *randfill.Filler {
+	return randfill.New().NilChance(0.2).NumElements(0, 3)
+}
+
+func TestWebserverConversionRoundTripFromSpoke(t *testing.T) {
+	filler := newFiller()
+	for i := 0; i < fuzzIterations; i++ {
+		original := &Webserver{}
+		filler.Fill(original)
+		original.TypeMeta.Reset()
+
+		hub := &v1beta1.Webserver{}
+		if err := original.DeepCopy().ConvertTo(hub); err != nil {
+			t.Fatalf("ConvertTo() error = %v", err)
+		}
+		roundTripped := &Webserver{}
+		if err := roundTripped.ConvertFrom(hub); err != nil {
+			t.Fatalf("ConvertFrom() error = %v", err)
+		}
+
+		if !equality.Semantic.DeepEqual(original, roundTripped) {
+			t.Fatalf("v1alpha1 -> v1beta1 -> v1alpha1 round trip changed the object:\n%s", diff.Diff(original, roundTripped))
+		}
+	}
+}
+
+func TestWebserverConversionRoundTripFromHub(t *testing.T) {
+	filler := newFiller()
+	for i := 0; i < fuzzIterations; i++ {
+		original := &v1beta1.Webserver{}
+		filler.Fill(original)
+		original.TypeMeta.Reset()
+
+		spoke := &Webserver{}
+		if err := spoke.ConvertFrom(original.DeepCopy()); err != nil {
+			t.Fatalf("ConvertFrom() error = %v", err)
+		}
+		roundTripped := &v1beta1.Webserver{}
+		if err := spoke.ConvertTo(roundTripped); err != nil {
+			t.Fatalf("ConvertTo() error = %v", err)
+		}
+
+		if !equality.Semantic.DeepEqual(original, roundTripped) {
+			t.Fatalf("v1beta1 -> v1alpha1 -> v1beta1 round trip changed the object:\n%s", diff.Diff(original, roundTripped))
+		}
+	}
+}
+
+func TestWebserverConversionMapsFeatures(t *testing.T) {
+	original := &Webserver{
+		Spec: WebserverSpec{
+			ServiceType: "LoadBalancer",
+			Config: WebserverConfig{
+				Title:    "Demo",
+				Features: map[string]bool{"metrics": true, "feature1": false},
+			},
+		},
+	}
+
+	hub := &v1beta1.Webserver{}
+	if err := original.ConvertTo(hub); err != nil {
+		t.Fatal(err)
+	}
+	if hub.Spec.Features.Metrics == nil || !hub.Spec.Features.Metrics.Enabled {
+		t.Errorf("Expected typed metrics feature, got %+v", hub.Spec.Features)
+	}
+	if enabled, ok := hub.Spec.Features.Extra["feature1"]; !ok || enabled {
+		t.Errorf("Expected feature1=false in extra features, got %v", hub.Spec.Features.Extra)
+	}
+	if hub.Spec.ServiceType != v1beta1.ServiceTypeLoadBalancer {
+		t.Errorf("Expected LoadBalancer service type, got %q", hub.Spec.ServiceType)
+	}
+	if hub.Spec.Content.Title != "Demo" {
+		t.Errorf("Expected title in content section, got %q", hub.Spec.Content.Title)
+	}
+}
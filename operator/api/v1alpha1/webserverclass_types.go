@@ -0,0 +1,69 @@
+package v1alpha1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// DefaultClassAnnotation marks the WebserverClass used by Webservers that do not set spec.className
+const DefaultClassAnnotation = "webserver.io/is-default-class"
+
+// WebserverClassSpec defines org-wide defaults and policy for Webservers
+type WebserverClassSpec struct {
+	// Image is the container image used when a Webserver does not set one
+	Image string `json:"image,omitempty"`
+
+	// Port is the port used when a Webserver does not set one
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	Port int32 `json:"port,omitempty"`
+
+	// Resources are the compute resources given to the web server container
+	Resources *corev1.ResourceRequirements `json:"resources,omitempty"`
+
+	// AllowedRegistries lists the registry prefixes images may be pulled from, e.g. "docker.io/library/"
+	AllowedRegistries []string `json:"allowedRegistries,omitempty"`
+
+	// ForbidLatestTag rejects images using the latest tag or no tag at all
+	ForbidLatestTag bool `json:"forbidLatestTag,omitempty"`
+
+	// ResolveDigests pins image tags to the immutable digest they point at
+	ResolveDigests bool `json:"resolveDigests,omitempty"`
+
+	// MaxReplicas is the highest replica count a Webserver of this class may request
+	// +kubebuilder:validation:Minimum=1
+	MaxReplicas int32 `json:"maxReplicas,omitempty"`
+
+	// AllowedServiceTypes lists the Service types Webservers of this class may use
+	AllowedServiceTypes []string `json:"allowedServiceTypes,omitempty"`
+
+	// SecurityContext is applied to the web server container
+	SecurityContext *corev1.SecurityContext `json:"securityContext,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:resource:scope=Cluster
+//+kubebuilder:printcolumn:name="Image",type="string",JSONPath=".spec.image"
+//+kubebuilder:printcolumn:name="Max Replicas",type="integer",JSONPath=".spec.maxReplicas"
+//+kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// WebserverClass is the Schema for the webserverclasses API
+type WebserverClass struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec WebserverClassSpec `json:"spec,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// WebserverClassList contains a list of WebserverClass
+type WebserverClassList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []WebserverClass `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&WebserverClass{}, &WebserverClassList{})
+}
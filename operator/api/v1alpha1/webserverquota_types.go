@@ -0,0 +1,72 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// WebserverQuotaSpec caps the Webservers of a namespace. A limit left unset
+// is not enforced.
+type WebserverQuotaSpec struct {
+	// MaxWebservers is the number of Webservers the namespace may run
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MaxWebservers *int32 `json:"maxWebservers,omitempty"`
+
+	// MaxReplicas is the combined replica count of all Webservers in the
+	// namespace. A Webserver counts with the larger of spec.replicas and its
+	// largest schedule window.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MaxReplicas *int32 `json:"maxReplicas,omitempty"`
+}
+
+// WebserverQuotaUsage is the share of a quota taken by admitted Webservers
+type WebserverQuotaUsage struct {
+	// Webservers is the number of admitted Webservers
+	Webservers int32 `json:"webservers"`
+
+	// Replicas is the combined replica count of admitted Webservers
+	Replicas int32 `json:"replicas"`
+}
+
+// WebserverQuotaStatus defines the observed state of WebserverQuota
+type WebserverQuotaStatus struct {
+	// ObservedGeneration reflects the generation of the most recently observed WebserverQuota
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Used is what the admitted Webservers of the namespace consume
+	Used WebserverQuotaUsage `json:"used"`
+
+	// Exceeded lists the Webservers held back because they do not fit, in name order
+	Exceeded []string `json:"exceeded,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Webservers",type="integer",JSONPath=".status.used.webservers"
+//+kubebuilder:printcolumn:name="Max Webservers",type="integer",JSONPath=".spec.maxWebservers"
+//+kubebuilder:printcolumn:name="Replicas",type="integer",JSONPath=".status.used.replicas"
+//+kubebuilder:printcolumn:name="Max Replicas",type="integer",JSONPath=".spec.maxReplicas"
+//+kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// WebserverQuota is the Schema for the webserverquotas API
+type WebserverQuota struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   WebserverQuotaSpec   `json:"spec,omitempty"`
+	Status WebserverQuotaStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// WebserverQuotaList contains a list of WebserverQuota
+type WebserverQuotaList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []WebserverQuota `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&WebserverQuota{}, &WebserverQuotaList{})
+}
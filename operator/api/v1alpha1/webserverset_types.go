@@ -0,0 +1,122 @@
+package v1alpha1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// WebserverSetSpec defines the desired state of WebserverSet
+type WebserverSetSpec struct {
+	// Template is stamped out once for every generated target
+	Template WebserverTemplateSpec `json:"template"`
+
+	// Generators produce the namespaces and names of the child Webservers
+	// +kubebuilder:validation:MinItems=1
+	Generators []WebserverSetGenerator `json:"generators"`
+
+	// Rollout controls how template changes roll across the fleet
+	Rollout WebserverSetRollout `json:"rollout,omitempty"`
+}
+
+// WebserverTemplateSpec describes the Webservers created from a WebserverSet.
+// The strings {{namespace}}, {{name}} and {{<key>}} in the page title and
+// message are replaced with the values of each generated target.
+type WebserverTemplateSpec struct {
+	// Labels are added to every child Webserver
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// Annotations are added to every child Webserver
+	Annotations map[string]string `json:"annotations,omitempty"`
+
+	// Spec is the spec of every child Webserver
+	Spec WebserverSpec `json:"spec,omitempty"`
+}
+
+// WebserverSetGenerator produces targets. Exactly one field should be set.
+type WebserverSetGenerator struct {
+	// List names the targets explicitly
+	List []WebserverSetElement `json:"list,omitempty"`
+
+	// NamespaceSelector creates one Webserver, named after the set, in every matching namespace
+	NamespaceSelector *metav1.LabelSelector `json:"namespaceSelector,omitempty"`
+
+	// Matrix creates one Webserver for every combination of values. A "namespace"
+	// key selects the namespace; otherwise children go to the "default" namespace.
+	Matrix map[string][]string `json:"matrix,omitempty"`
+}
+
+// WebserverSetElement is a single explicitly listed target
+type WebserverSetElement struct {
+	// Namespace is the namespace of the child Webserver
+	Namespace string `json:"namespace"`
+
+	// Name is the name of the child Webserver; the set name is used when empty
+	Name string `json:"name,omitempty"`
+
+	// Values are substituted into the template
+	Values map[string]string `json:"values,omitempty"`
+}
+
+// WebserverSetRollout controls how template changes are applied to children
+type WebserverSetRollout struct {
+	// WaveSize is the number of children updated per wave, in name order;
+	// all children form a single wave when zero
+	// +kubebuilder:validation:Minimum=0
+	WaveSize int32 `json:"waveSize,omitempty"`
+
+	// MaxUnavailable is the number or percentage of children that may be
+	// unavailable at once during a rollout; defaults to 1
+	// +kubebuilder:validation:XIntOrString
+	MaxUnavailable *intstr.IntOrString `json:"maxUnavailable,omitempty"`
+}
+
+// WebserverSetStatus defines the observed state of WebserverSet
+type WebserverSetStatus struct {
+	// Conditions represent the latest available observations of the fleet
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// ObservedGeneration reflects the generation of the most recently observed WebserverSet
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// Desired is the number of generated children
+	Desired int32 `json:"desired,omitempty"`
+
+	// Updated is the number of children running the current template
+	Updated int32 `json:"updated,omitempty"`
+
+	// Ready is the number of children reporting Ready
+	Ready int32 `json:"ready,omitempty"`
+
+	// CurrentWave is the wave being rolled out, starting at zero
+	CurrentWave int32 `json:"currentWave,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:resource:scope=Cluster
+//+kubebuilder:printcolumn:name="Desired",type="integer",JSONPath=".status.desired"
+//+kubebuilder:printcolumn:name="Updated",type="integer",JSONPath=".status.updated"
+//+kubebuilder:printcolumn:name="Ready",type="integer",JSONPath=".status.ready"
+//+kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// WebserverSet is the Schema for the webserversets API
+type WebserverSet struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   WebserverSetSpec   `json:"spec,omitempty"`
+	Status WebserverSetStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// WebserverSetList contains a list of WebserverSet
+type WebserverSetList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []WebserverSet `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&WebserverSet{}, &WebserverSetList{})
+}
@@ -0,0 +1,4 @@
+package v1beta1
+
+// Hub marks v1beta1 as the version every other Webserver version converts through
+func (*Webserver) Hub() {}
@@ -0,0 +1,483 @@
+package v1beta1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// ServiceType is the type of Kubernetes service exposing the web server
+// +kubebuilder:validation:Enum=ClusterIP;NodePort;LoadBalancer
+type ServiceType string
+
+const (
+	// ServiceTypeClusterIP exposes the web server inside the cluster only
+	ServiceTypeClusterIP ServiceType = "ClusterIP"
+
+	// ServiceTypeNodePort exposes the web server on a port of every node
+	ServiceTypeNodePort ServiceType = "NodePort"
+
+	// ServiceTypeLoadBalancer exposes the web server through a cloud load balancer
+	ServiceTypeLoadBalancer ServiceType = "LoadBalancer"
+)
+
+// WebserverSpec defines the desired state of Webserver
+type WebserverSpec struct {
+	// Replicas is the number of desired replicas for the web server deployment
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=10
+	Replicas int32 `json:"replicas,omitempty"`
+
+	// Image is the container image to use for the web server
+	Image string `json:"image,omitempty"`
+
+	// Port is the port the web server listens on
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	Port int32 `json:"port,omitempty"`
+
+	// ServiceType is the type of Kubernetes service to create
+	ServiceType ServiceType `json:"serviceType,omitempty"`
+
+	// Content describes the page served by the web server
+	Content WebserverContent `json:"content,omitempty"`
+
+	// Features enables optional behaviour of the web server
+	Features WebserverFeatures `json:"features,omitempty"`
+
+	// ClassName is the WebserverClass supplying defaults and policy; the default class is used when empty
+	ClassName string `json:"className,omitempty"`
+
+	// Schedule lists recurring windows that override the replica count while open
+	// +optional
+	Schedule []ScheduleWindow `json:"schedule,omitempty"`
+
+	// TTL deletes the Webserver this long after it was created, e.g. "72h"
+	// +optional
+	TTL *metav1.Duration `json:"ttl,omitempty"`
+
+	// ExtendTTLOnActivity counts the TTL from the last spec change instead of creation
+	// +optional
+	ExtendTTLOnActivity bool `json:"extendTTLOnActivity,omitempty"`
+
+	// SecurityContext overrides fields of the hardened default security context of the web server container
+	// +optional
+	SecurityContext *corev1.SecurityContext `json:"securityContext,omitempty"`
+
+	// ExtraContainers run next to the web server, e.g. log shippers or auth proxies
+	// +optional
+	ExtraContainers []corev1.Container `json:"extraContainers,omitempty"`
+
+	// InitContainers run to completion before the web server starts
+	// +optional
+	InitContainers []corev1.Container `json:"initContainers,omitempty"`
+
+	// ExtraVolumes are added to the pod and can be mounted by any container
+	// +optional
+	ExtraVolumes []corev1.Volume `json:"extraVolumes,omitempty"`
+
+	// ExtraVolumeMounts are added to the web server container
+	// +optional
+	ExtraVolumeMounts []corev1.VolumeMount `json:"extraVolumeMounts,omitempty"`
+
+	// Env sets environment variables of the web server container
+	// +optional
+	Env []corev1.EnvVar `json:"env,omitempty"`
+
+	// EnvFrom populates environment variables of the web server container from ConfigMaps or Secrets
+	// +optional
+	EnvFrom []corev1.EnvFromSource `json:"envFrom,omitempty"`
+
+	// Disruption bounds voluntary disruptions such as node drains while more than one replica runs
+	// +optional
+	Disruption *DisruptionSpec `json:"disruption,omitempty"`
+
+	// Bundle serves an archive of static files instead of the generated page
+	// +optional
+	Bundle *ContentBundle `json:"bundle,omitempty"`
+
+	// Service configures the Service in front of the web server
+	// +optional
+	Service *ServiceSpec `json:"service,omitempty"`
+
+	// CommonLabels are added to every object created for the Webserver,
+	// including its pods. Labels set by the operator take precedence.
+	// +optional
+	CommonLabels map[string]string `json:"commonLabels,omitempty"`
+
+	// CommonAnnotations are added to every object created for the Webserver,
+	// including its pods
+	// +optional
+	CommonAnnotations map[string]string `json:"commonAnnotations,omitempty"`
+
+	// PodAnnotations are added to the pods of the Webserver and take
+	// precedence over commonAnnotations
+	// +optional
+	PodAnnotations map[string]string `json:"podAnnotations,omitempty"`
+}
+
+// DisruptionSpec configures the PodDisruptionBudget of a Webserver; at most
+// one of minAvailable and maxUnavailable may be set, maxUnavailable 1 is used
+// when neither is
+// +kubebuilder:validation:XValidation:rule="!(has(self.minAvailable) && has(self.maxUnavailable))",message="minAvailable and maxUnavailable are mutually exclusive"
+type DisruptionSpec struct {
+	// MinAvailable is the number or percentage of pods that must stay available
+	// +kubebuilder:validation:XIntOrString
+	// +optional
+	MinAvailable *intstr.IntOrString `json:"minAvailable,omitempty"`
+
+	// MaxUnavailable is the number or percentage of pods that may be unavailable
+	// +kubebuilder:validation:XIntOrString
+	// +optional
+	MaxUnavailable *intstr.IntOrString `json:"maxUnavailable,omitempty"`
+}
+
+// ServiceSpec configures the Service of a Webserver. Changing Headless
+// recreates the Service, since its cluster IP cannot be changed in place.
+type ServiceSpec struct {
+	// Ports exposed by the Service; a single http port 80 when empty
+	// +listType=map
+	// +listMapKey=name
+	// +kubebuilder:validation:MaxItems=10
+	// +optional
+	Ports []ServicePort `json:"ports,omitempty"`
+
+	// Annotations are set on the Service, e.g. to configure a cloud load balancer
+	// +optional
+	Annotations map[string]string `json:"annotations,omitempty"`
+
+	// LoadBalancerSourceRanges restricts the client CIDRs a LoadBalancer Service accepts
+	// +optional
+	LoadBalancerSourceRanges []string `json:"loadBalancerSourceRanges,omitempty"`
+
+	// ExternalTrafficPolicy routes external traffic to node-local pods only when Local
+	// +kubebuilder:validation:Enum=Cluster;Local
+	// +optional
+	ExternalTrafficPolicy corev1.ServiceExternalTrafficPolicy `json:"externalTrafficPolicy,omitempty"`
+
+	// SessionAffinity sends a client to the same pod when ClientIP
+	// +kubebuilder:validation:Enum=None;ClientIP
+	// +optional
+	SessionAffinity corev1.ServiceAffinity `json:"sessionAffinity,omitempty"`
+
+	// Headless creates a Service without a cluster IP that resolves to the pod IPs
+	// +optional
+	Headless bool `json:"headless,omitempty"`
+}
+
+// ServicePort is one port of the Webserver Service
+type ServicePort struct {
+	// Name identifies the port, e.g. http, https or metrics
+	// +kubebuilder:validation:Pattern=`^[a-z]([-a-z0-9]*[a-z0-9])?$`
+	// +kubebuilder:validation:MaxLength=15
+	Name string `json:"name"`
+
+	// Port is the port the Service listens on
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	Port int32 `json:"port"`
+
+	// TargetPort is the pod port traffic goes to. It defaults to the web
+	// server port for http and to the container port of the same name otherwise,
+	// which the web server or an extra container must expose.
+	// +kubebuilder:validation:XIntOrString
+	// +optional
+	TargetPort *intstr.IntOrString `json:"targetPort,omitempty"`
+
+	// NodePort fixes the node port of NodePort and LoadBalancer Services; one is allocated when unset
+	// +kubebuilder:validation:Minimum=30000
+	// +kubebuilder:validation:Maximum=32767
+	// +optional
+	NodePort int32 `json:"nodePort,omitempty"`
+}
+
+// ContentBundle points at a gzip-compressed tar archive of the site; the
+// archive root becomes the document root
+type ContentBundle struct {
+	// URL is where the archive is downloaded from; publish new content under a new URL or checksum
+	// +kubebuilder:validation:Pattern=`^https?://`
+	URL string `json:"url"`
+
+	// SHA256 is the expected hex digest of the archive; the archive is rejected on a mismatch
+	// +kubebuilder:validation:Pattern=`^[a-f0-9]{64}$`
+	// +optional
+	SHA256 string `json:"sha256,omitempty"`
+}
+
+// ScheduleWindow scales a Webserver to a replica count for a recurring window
+type ScheduleWindow struct {
+	// Name identifies the window in status
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+
+	// Cron is the five-field cron expression at which the window opens, e.g. "0 9 * * 1-5"
+	// +kubebuilder:validation:MinLength=1
+	Cron string `json:"cron"`
+
+	// Duration is how long the window stays open, e.g. "9h"
+	Duration metav1.Duration `json:"duration"`
+
+	// TimeZone is the IANA time zone the cron expression is evaluated in; UTC when empty
+	// +optional
+	TimeZone string `json:"timeZone,omitempty"`
+
+	// Replicas is the replica count while the window is open
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=10
+	Replicas int32 `json:"replicas"`
+}
+
+// WebserverContent describes the page served by the web server
+type WebserverContent struct {
+	// Title is the title displayed on the web page
+	Title string `json:"title,omitempty"`
+
+	// Message is the message displayed on the web page
+	Message string `json:"message,omitempty"`
+
+	// Color is the background color of the web page
+	Color string `json:"color,omitempty"`
+
+	// Locales overrides the page per locale code, e.g. de or pt-BR. Every
+	// locale is served under /<locale>/ and picked for / from the
+	// Accept-Language header.
+	// +kubebuilder:validation:MaxProperties=20
+	// +kubebuilder:validation:XValidation:rule="self.all(k, k.matches('^[a-z]{2,3}(-[A-Za-z0-9]{2,8})*$'))",message="locale codes must look like en or pt-BR"
+	// +optional
+	Locales map[string]LocaleContent `json:"locales,omitempty"`
+
+	// DefaultLocale is the locale of the page served when no locale matches
+	// the Accept-Language header; en when empty
+	// +kubebuilder:validation:Pattern=`^[a-z]{2,3}(-[A-Za-z0-9]{2,8})*$`
+	// +optional
+	DefaultLocale string `json:"defaultLocale,omitempty"`
+}
+
+// LocaleContent overrides the page for one locale. Fields left empty are
+// taken from the default page.
+type LocaleContent struct {
+	// Title is the title displayed on the page of the locale
+	// +optional
+	Title string `json:"title,omitempty"`
+
+	// Message is the message displayed on the page of the locale
+	// +optional
+	Message string `json:"message,omitempty"`
+
+	// Template replaces the generated page with an html/template rendered with
+	// .Locale, .Title, .Message, .Color, .Name and .Namespace
+	// +optional
+	Template string `json:"template,omitempty"`
+}
+
+// WebserverFeatures holds the typed feature toggles. Typed features cannot
+// also be given as extra flags, which v1alpha1 could not tell apart.
+// +kubebuilder:validation:XValidation:rule="!has(self.extra) || !('metrics' in self.extra)",message="metrics is a typed feature; set features.metrics.enabled instead"
+type WebserverFeatures struct {
+	// Metrics exports request metrics for the served site
+	Metrics *FeatureToggle `json:"metrics,omitempty"`
+
+	// Extra holds feature flags that have no typed field; metrics is not allowed here
+	Extra map[string]bool `json:"extra,omitempty"`
+}
+
+// FeatureToggle enables or disables a single feature
+type FeatureToggle struct {
+	// Enabled turns the feature on
+	Enabled bool `json:"enabled"`
+}
+
+// WebserverStatus defines the observed state of Webserver
+type WebserverStatus struct {
+	// Conditions represent the latest available observations of an object's state
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// ObservedGeneration reflects the generation of the most recently observed Webserver resource
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// ReadyReplicas is the number of ready replicas
+	ReadyReplicas int32 `json:"readyReplicas,omitempty"`
+
+	// Phase represents the current phase of the Webserver deployment
+	Phase string `json:"phase,omitempty"`
+
+	// ClassName is the WebserverClass that was applied in the last reconcile
+	ClassName string `json:"className,omitempty"`
+
+	// URL is the address users should visit to reach the web server
+	URL string `json:"url,omitempty"`
+
+	// InternalURL is the in-cluster address of the web server Service
+	InternalURL string `json:"internalURL,omitempty"`
+
+	// LoadBalancerIngress lists the IPs or hostnames assigned to a LoadBalancer Service
+	LoadBalancerIngress []string `json:"loadBalancerIngress,omitempty"`
+
+	// NodePort is the node port allocated for NodePort and LoadBalancer Services
+	NodePort int32 `json:"nodePort,omitempty"`
+
+	// Image records the image reference deployed for spec.image
+	Image *ImageStatus `json:"image,omitempty"`
+
+	// ContentVerification records the outcome of the last synthetic content check
+	ContentVerification *ContentVerificationStatus `json:"contentVerification,omitempty"`
+
+	// ActiveSchedule is the schedule window currently setting the replica count
+	ActiveSchedule *ActiveSchedule `json:"activeSchedule,omitempty"`
+
+	// NextScheduleTime is when the scheduled replica count next changes
+	NextScheduleTime *metav1.Time `json:"nextScheduleTime,omitempty"`
+
+	// ExpiresAt is when the Webserver will be deleted
+	ExpiresAt *metav1.Time `json:"expiresAt,omitempty"`
+
+	// LastActivityTime is when a spec change was last observed, used by extendTTLOnActivity
+	LastActivityTime *metav1.Time `json:"lastActivityTime,omitempty"`
+
+	// PodSummary aggregates the state of the pods running the web server
+	PodSummary *PodSummary `json:"podSummary,omitempty"`
+
+	// ContentManifest lists the files of the content bundle being served
+	ContentManifest *ContentManifest `json:"contentManifest,omitempty"`
+}
+
+// ImageStatus describes how spec.image was resolved
+type ImageStatus struct {
+	// Requested is the image reference taken from the spec
+	Requested string `json:"requested,omitempty"`
+
+	// Digest is the immutable digest the requested tag resolved to
+	Digest string `json:"digest,omitempty"`
+
+	// Deployed is the image reference used in the Deployment
+	Deployed string `json:"deployed,omitempty"`
+}
+
+// ContentVerificationStatus describes the last fetch of the served page
+type ContentVerificationStatus struct {
+	// Marker is the content marker expected in the served page
+	Marker string `json:"marker,omitempty"`
+
+	// LatencyMilliseconds is how long the fetch that changed the outcome took
+	LatencyMilliseconds int64 `json:"latencyMilliseconds,omitempty"`
+
+	// LastCheckTime is when the outcome of the check last changed; fetches
+	// with the same outcome are not recorded
+	LastCheckTime *metav1.Time `json:"lastCheckTime,omitempty"`
+}
+
+// PodSummary aggregates the pods behind a Webserver
+type PodSummary struct {
+	// Total is the number of pods
+	Total int32 `json:"total,omitempty"`
+
+	// Ready is the number of pods passing their readiness checks
+	Ready int32 `json:"ready,omitempty"`
+
+	// Pending is the number of pods in the Pending phase
+	Pending int32 `json:"pending,omitempty"`
+
+	// Running is the number of pods in the Running phase
+	Running int32 `json:"running,omitempty"`
+
+	// Failed is the number of pods in the Failed phase
+	Failed int32 `json:"failed,omitempty"`
+
+	// Restarts is the total number of container restarts across all pods
+	Restarts int32 `json:"restarts,omitempty"`
+
+	// Issues lists the most pressing container and scheduling problems
+	Issues []PodIssue `json:"issues,omitempty"`
+}
+
+// PodIssue describes a problem with one pod or container
+type PodIssue struct {
+	// Pod is the name of the affected pod
+	Pod string `json:"pod"`
+
+	// Container is the affected container; empty for pod-level problems
+	Container string `json:"container,omitempty"`
+
+	// Reason is the waiting, termination or scheduling reason, e.g. CrashLoopBackOff
+	Reason string `json:"reason"`
+
+	// Message gives details about the reason
+	Message string `json:"message,omitempty"`
+
+	// Restarts is how often the container has restarted
+	Restarts int32 `json:"restarts,omitempty"`
+}
+
+// ContentManifest describes the content bundle and the ConfigMaps it is sharded across
+type ContentManifest struct {
+	// URL is the archive the bundle was downloaded from
+	URL string `json:"url"`
+
+	// SHA256 is the hex digest of the downloaded archive
+	SHA256 string `json:"sha256"`
+
+	// Files lists every file of the bundle in path order
+	Files []ContentFile `json:"files,omitempty"`
+
+	// TotalBytes is the combined size of all files
+	TotalBytes int64 `json:"totalBytes"`
+
+	// Shards are the names of the ConfigMaps holding the files
+	Shards []string `json:"shards,omitempty"`
+}
+
+// ContentFile describes one file of the content bundle
+type ContentFile struct {
+	// Path is the path of the file below the document root
+	Path string `json:"path"`
+
+	// Size is the size of the file in bytes
+	Size int64 `json:"size"`
+
+	// Shard is the ConfigMap holding the file
+	Shard string `json:"shard"`
+}
+
+// ActiveSchedule describes the open schedule window
+type ActiveSchedule struct {
+	// Name is the name of the open window
+	Name string `json:"name"`
+
+	// Replicas is the replica count set by the window
+	Replicas int32 `json:"replicas"`
+
+	// Until is when the window closes
+	Until metav1.Time `json:"until"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:storageversion
+//+kubebuilder:subresource:status
+//+kubebuilder:subresource:scale:specpath=.spec.replicas,statuspath=.status.readyReplicas
+//+kubebuilder:printcolumn:name="Phase",type="string",JSONPath=".status.phase"
+//+kubebuilder:printcolumn:name="Ready",type="integer",JSONPath=".status.readyReplicas"
+//+kubebuilder:printcolumn:name="Desired",type="integer",JSONPath=".spec.replicas"
+//+kubebuilder:printcolumn:name="URL",type="string",JSONPath=".status.url"
+//+kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// Webserver is the Schema for the webservers API
+type Webserver struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   WebserverSpec   `json:"spec,omitempty"`
+	Status WebserverStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// WebserverList contains a list of Webserver
+type WebserverList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []Webserver `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&Webserver{}, &WebserverList{})
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	webserverv1alpha1 "github.com/webserver/webserver-operator/api/v1alpha1"
+	webserverv1beta1 "github.com/webserver/webserver-operator/api/v1beta1"
+	"github.com/webserver/webserver-operator/internal/plugin"
+)
+
+func main() {
+	scheme := runtime.NewScheme()
+	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
+	utilruntime.Must(webserverv1alpha1.AddToScheme(scheme))
+	utilruntime.Must(webserverv1beta1.AddToScheme(scheme))
+
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	overrides := &clientcmd.ConfigOverrides{}
+	// controller-runtime registers --kubeconfig when it is linked in
+	if flag.Lookup("kubeconfig") == nil {
+		flag.String("kubeconfig", "", "Path to the kubeconfig file.")
+	}
+	flag.StringVar(&overrides.Context.Namespace, "namespace", "", "Namespace of the Webserver; the kubeconfig namespace when empty.")
+	flag.StringVar(&overrides.Context.Namespace, "n", "", "Shorthand for --namespace.")
+	flag.StringVar(&overrides.CurrentContext, "context", "", "The kubeconfig context to use.")
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), plugin.Usage+"\nGlobal flags:\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	// -n is also parsed by the command; the cluster must be known before it runs
+	connection, args := plugin.SplitConnectionFlags(flag.Args())
+	_ = flag.CommandLine.Parse(connection)
+	if len(args) == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	loadingRules.ExplicitPath = flag.Lookup("kubeconfig").Value.String()
+
+	kubeconfig := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, overrides)
+	config, err := kubeconfig.ClientConfig()
+	if err != nil {
+		fail(err)
+	}
+	namespace, _, err := kubeconfig.Namespace()
+	if err != nil {
+		fail(err)
+	}
+	c, err := client.New(config, client.Options{Scheme: scheme})
+	if err != nil {
+		fail(err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	p := &plugin.Plugin{
+		Client:    c,
+		Forwarder: &plugin.SPDYForwarder{Config: config, ErrOut: os.Stderr},
+		Namespace: namespace,
+		Out:       os.Stdout,
+	}
+	if err := p.Run(ctx, args); err != nil {
+		if errors.Is(err, plugin.ErrUsage) {
+			flag.Usage()
+			os.Exit(2)
+		}
+		fail(err)
+	}
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, "error:", err)
+	os.Exit(1)
+}
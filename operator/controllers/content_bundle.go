@@ -0,0 +1,403 @@
+package controllers
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	webserverv1alpha1 "github.com/webserver/webserver-operator/api/v1alpha1"
+)
+
+const (
+	// contentShardLabel marks the ConfigMaps holding content shards with the name of their Webserver
+	contentShardLabel = "webserver.io/content-shard"
+
+	// contentShardBytes is the most file data placed in one shard, leaving
+	// headroom below the 1 MiB ConfigMap limit for keys and metadata
+	contentShardBytes = 900 * 1024
+
+	// maxContentBytes bounds both the downloaded archive and the unpacked files
+	maxContentBytes = 32 << 20
+
+	// maxContentFiles bounds the number of files so the manifest fits in status
+	maxContentFiles = 2000
+)
+
+// errInvalidBundle marks archives that cannot be served; retrying does not help
+var errInvalidBundle = errors.New("invalid content bundle")
+
+// invalidKeyChars matches characters not allowed in ConfigMap keys
+var invalidKeyChars = regexp.MustCompile(`[^-._a-zA-Z0-9]`)
+
+// ContentFetcher downloads content bundle archives
+type ContentFetcher interface {
+	Fetch(ctx context.Context, url string) ([]byte, error)
+}
+
+// HTTPContentFetcher downloads archives with a GET request
+type HTTPContentFetcher struct {
+	// Client is the HTTP client used for requests; a client with Timeout is used when nil
+	Client *http.Client
+
+	// Timeout bounds each request when Client is nil
+	Timeout time.Duration
+}
+
+// Fetch downloads the archive at url, refusing archives above maxContentBytes
+func (f *HTTPContentFetcher) Fetch(ctx context.Context, url string) ([]byte, error) {
+	httpClient := f.Client
+	if httpClient == nil {
+		timeout := f.Timeout
+		if timeout == 0 {
+			timeout = 30 * time.Second
+		}
+		httpClient = &http.Client{Timeout: timeout}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+	archive, err := io.ReadAll(io.LimitReader(resp.Body, maxContentBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(archive) > maxContentBytes {
+		return nil, fmt.Errorf("%w: archive is larger than %d bytes", errInvalidBundle, maxContentBytes)
+	}
+	return archive, nil
+}
+
+// bundleFile is one file unpacked from a content archive
+type bundleFile struct {
+	Path string
+	Data []byte
+}
+
+// readBundle unpacks a gzip-compressed tar archive into its regular files, sorted by path
+func readBundle(archive []byte) ([]bundleFile, error) {
+	gz, err := gzip.NewReader(bytes.NewReader(archive))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errInvalidBundle, err)
+	}
+	defer gz.Close()
+
+	var files []bundleFile
+	seen := map[string]bool{}
+	total := int64(0)
+	reader := tar.NewReader(gz)
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", errInvalidBundle, err)
+		}
+		switch header.Typeflag {
+		case tar.TypeDir:
+			continue
+		case tar.TypeReg:
+		default:
+			return nil, fmt.Errorf("%w: %s is not a regular file", errInvalidBundle, header.Name)
+		}
+
+		filePath, err := bundlePath(header.Name)
+		if err != nil {
+			return nil, err
+		}
+		if seen[filePath] {
+			return nil, fmt.Errorf("%w: %s appears more than once", errInvalidBundle, filePath)
+		}
+		seen[filePath] = true
+		if len(files) == maxContentFiles {
+			return nil, fmt.Errorf("%w: more than %d files", errInvalidBundle, maxContentFiles)
+		}
+		total += header.Size
+		if total > maxContentBytes {
+			return nil, fmt.Errorf("%w: files are larger than %d bytes", errInvalidBundle, maxContentBytes)
+		}
+
+		data, err := io.ReadAll(reader)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", errInvalidBundle, err)
+		}
+		files = append(files, bundleFile{Path: filePath, Data: data})
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	return files, nil
+}
+
+// bundlePath cleans an archive path and rejects paths escaping the document root
+func bundlePath(name string) (string, error) {
+	cleaned := path.Clean(strings.TrimPrefix(name, "./"))
+	if path.IsAbs(name) || cleaned == "." || cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return "", fmt.Errorf("%w: path %q is outside the document root", errInvalidBundle, name)
+	}
+	return cleaned, nil
+}
+
+// contentKey derives a ConfigMap key from a file path; the hash keeps keys
+// unique when sanitising maps different paths to the same name
+func contentKey(filePath string) string {
+	sum := sha256.Sum256([]byte(filePath))
+	key := hex.EncodeToString(sum[:6]) + "-" + invalidKeyChars.ReplaceAllString(path.Base(filePath), "_")
+	if len(key) > 253 {
+		key = key[:253]
+	}
+	return key
+}
+
+// shardBundle packs the files in path order into shards of at most
+// contentShardBytes, so the same bundle always yields the same shards
+func shardBundle(files []bundleFile) ([][]bundleFile, error) {
+	var shards [][]bundleFile
+	size := 0
+	for _, file := range files {
+		fileSize := len(contentKey(file.Path)) + len(file.Data)
+		if fileSize > contentShardBytes {
+			return nil, fmt.Errorf("%w: %s is %d bytes, more than the %d bytes a ConfigMap can hold",
+				errInvalidBundle, file.Path, len(file.Data), contentShardBytes)
+		}
+		if len(shards) == 0 || size+fileSize > contentShardBytes {
+			shards = append(shards, nil)
+			size = 0
+		}
+		shards[len(shards)-1] = append(shards[len(shards)-1], file)
+		size += fileSize
+	}
+	return shards, nil
+}
+
+// contentShardName is the name of the ConfigMap holding shard i
+func contentShardName(webserver *webserverv1alpha1.Webserver, i int) string {
+	return fmt.Sprintf("%s-content-%d", webserver.Name, i)
+}
+
+// reconcileContentBundle downloads spec.bundle and stores it across content
+// shards unless the manifest in status already describes it. It returns a
+// message explaining why the bundle cannot be served, if so.
+func (r *WebserverReconciler) reconcileContentBundle(ctx context.Context, webserver *webserverv1alpha1.Webserver) (string, error) {
+	bundle := webserver.Spec.Bundle
+	if bundle == nil {
+		webserver.Status.ContentManifest = nil
+		return "", nil
+	}
+
+	current, err := r.contentUpToDate(ctx, webserver)
+	if err != nil || current {
+		return "", err
+	}
+
+	fetcher := r.Fetcher
+	if fetcher == nil {
+		fetcher = &HTTPContentFetcher{}
+	}
+	archive, err := fetcher.Fetch(ctx, bundle.URL)
+	if errors.Is(err, errInvalidBundle) {
+		return err.Error(), nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("downloading content bundle: %w", err)
+	}
+
+	sum := sha256.Sum256(archive)
+	digest := hex.EncodeToString(sum[:])
+	if bundle.SHA256 != "" && bundle.SHA256 != digest {
+		return fmt.Sprintf("content bundle digest %s does not match spec.bundle.sha256 %s", digest, bundle.SHA256), nil
+	}
+
+	files, err := readBundle(archive)
+	if err != nil {
+		return err.Error(), nil
+	}
+	shards, err := shardBundle(files)
+	if err != nil {
+		return err.Error(), nil
+	}
+	return "", r.applyContentShards(ctx, webserver, digest, shards)
+}
+
+// contentUpToDate reports whether the manifest matches spec.bundle and all of its shards exist
+func (r *WebserverReconciler) contentUpToDate(ctx context.Context, webserver *webserverv1alpha1.Webserver) (bool, error) {
+	manifest := webserver.Status.ContentManifest
+	bundle := webserver.Spec.Bundle
+	if manifest == nil || manifest.URL != bundle.URL || (bundle.SHA256 != "" && bundle.SHA256 != manifest.SHA256) {
+		return false, nil
+	}
+	for _, name := range manifest.Shards {
+		err := r.Get(ctx, client.ObjectKey{Name: name, Namespace: webserver.Namespace}, &corev1.ConfigMap{})
+		if err != nil {
+			return false, client.IgnoreNotFound(err)
+		}
+	}
+	return true, nil
+}
+
+// applyContentShards writes one ConfigMap per shard, keeping text files in
+// Data and binary files in BinaryData, and records the manifest in status
+func (r *WebserverReconciler) applyContentShards(ctx context.Context, webserver *webserverv1alpha1.Webserver, digest string, shards [][]bundleFile) error {
+	manifest := &webserverv1alpha1.ContentManifest{URL: webserver.Spec.Bundle.URL, SHA256: digest}
+
+	for i, files := range shards {
+		shard := &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      contentShardName(webserver, i),
+				Namespace: webserver.Namespace,
+			},
+		}
+		op, err := r.createOrUpdate(ctx, "mutateContentShard", webserver, shard, func() error {
+			if err := ctrl.SetControllerReference(webserver, shard, r.Scheme); err != nil {
+				return err
+			}
+			r.setChildMetadata(shard, webserver)
+			shard.Labels[contentShardLabel] = webserver.Name
+			shard.Data = map[string]string{}
+			shard.BinaryData = map[string][]byte{}
+			for _, file := range files {
+				if utf8.Valid(file.Data) {
+					shard.Data[contentKey(file.Path)] = string(file.Data)
+				} else {
+					shard.BinaryData[contentKey(file.Path)] = file.Data
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		if op != controllerutil.OperationResultNone {
+			log.FromContext(ctx).Info("Content shard operation", "shard", shard.Name, "operation", op)
+		}
+
+		manifest.Shards = append(manifest.Shards, shard.Name)
+		for _, file := range files {
+			manifest.Files = append(manifest.Files, webserverv1alpha1.ContentFile{
+				Path:  file.Path,
+				Size:  int64(len(file.Data)),
+				Shard: shard.Name,
+			})
+			manifest.TotalBytes += int64(len(file.Data))
+		}
+	}
+
+	webserver.Status.ContentManifest = manifest
+	return nil
+}
+
+// deleteStaleContentShards removes shards no longer listed in the manifest
+func (r *WebserverReconciler) deleteStaleContentShards(ctx context.Context, webserver *webserverv1alpha1.Webserver) error {
+	shards := &corev1.ConfigMapList{}
+	if err := r.List(ctx, shards, client.InNamespace(webserver.Namespace), client.MatchingLabels{contentShardLabel: webserver.Name}); err != nil {
+		return err
+	}
+
+	current := map[string]bool{}
+	if webserver.Status.ContentManifest != nil {
+		for _, name := range webserver.Status.ContentManifest.Shards {
+			current[name] = true
+		}
+	}
+	for i := range shards.Items {
+		shard := &shards.Items[i]
+		if current[shard.Name] || !metav1.IsControlledBy(shard, webserver) {
+			continue
+		}
+		if err := r.Delete(ctx, shard); client.IgnoreNotFound(err) != nil {
+			return err
+		}
+		log.FromContext(ctx).Info("Deleted stale content shard", "shard", shard.Name)
+	}
+	return nil
+}
+
+// bundleServesIndex reports whether the content bundle replaces the generated index.html
+func bundleServesIndex(webserver *webserverv1alpha1.Webserver) bool {
+	manifest := webserver.Status.ContentManifest
+	if webserver.Spec.Bundle == nil || manifest == nil {
+		return false
+	}
+	for _, file := range manifest.Files {
+		if file.Path == "index.html" {
+			return true
+		}
+	}
+	return false
+}
+
+// contentVolumeSource mounts the generated page, or projects the content
+// shards into the document root when a bundle is served
+func contentVolumeSource(webserver *webserverv1alpha1.Webserver) corev1.VolumeSource {
+	generated := corev1.ConfigMapProjection{
+		LocalObjectReference: corev1.LocalObjectReference{Name: webserver.Name + "-config"},
+		Items:                append([]corev1.KeyToPath{{Key: "index.html", Path: "index.html"}}, localeItems(webserver)...),
+	}
+
+	manifest := webserver.Status.ContentManifest
+	if webserver.Spec.Bundle == nil || manifest == nil {
+		return corev1.VolumeSource{
+			ConfigMap: &corev1.ConfigMapVolumeSource{
+				LocalObjectReference: generated.LocalObjectReference,
+				Items:                generated.Items,
+			},
+		}
+	}
+
+	var sources []corev1.VolumeProjection
+	items := map[string][]corev1.KeyToPath{}
+	bundled := map[string]bool{}
+	for _, file := range manifest.Files {
+		items[file.Shard] = append(items[file.Shard], corev1.KeyToPath{Key: contentKey(file.Path), Path: file.Path})
+		bundled[file.Path] = true
+	}
+	if !bundleServesIndex(webserver) {
+		// Locale pages the bundle ships itself take precedence
+		generatedItems := generated.Items[:0]
+		for _, item := range generated.Items {
+			if !bundled[item.Path] {
+				generatedItems = append(generatedItems, item)
+			}
+		}
+		generated.Items = generatedItems
+		sources = append(sources, corev1.VolumeProjection{ConfigMap: &generated})
+	}
+	for _, name := range manifest.Shards {
+		sources = append(sources, corev1.VolumeProjection{
+			ConfigMap: &corev1.ConfigMapProjection{
+				LocalObjectReference: corev1.LocalObjectReference{Name: name},
+				Items:                items[name],
+			},
+		})
+	}
+	return corev1.VolumeSource{Projected: &corev1.ProjectedVolumeSource{Sources: sources}}
+}
@@ -0,0 +1,267 @@
+package controllers
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"testing"
+
+	webserverv1alpha1 "github.com/webserver/webserver-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// fakeFetcher serves archives from memory and counts downloads
+type fakeFetcher struct {
+	archives map[string][]byte
+	fetches  int
+}
+
+func (f *fakeFetcher) Fetch(_ context.Context, url string) ([]byte, error) {
+	f.fetches++
+	archive, ok := f.archives[url]
+	if !ok {
+		return nil, fmt.Errorf("unexpected status code 404")
+	}
+	return archive, nil
+}
+
+// tarball builds a gzip-compressed tar archive; entries are written in the given order
+func tarball(t *testing.T, files ...bundleFile) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for _, file := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: file.Path, Mode: 0o644, Size: int64(len(file.Data)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write(file.Data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func bundleWebserver(url string) *webserverv1alpha1.Webserver {
+	return &webserverv1alpha1.Webserver{
+		ObjectMeta: metav1.ObjectMeta{Name: "site", Namespace: "web"},
+		Spec:       webserverv1alpha1.WebserverSpec{Bundle: &webserverv1alpha1.ContentBundle{URL: url}},
+	}
+}
+
+func getWebserver(t *testing.T, r *WebserverReconciler) *webserverv1alpha1.Webserver {
+	t.Helper()
+	webserver := &webserverv1alpha1.Webserver{}
+	if err := r.Get(context.Background(), types.NamespacedName{Name: "site", Namespace: "web"}, webserver); err != nil {
+		t.Fatal(err)
+	}
+	return webserver
+}
+
+func TestContentBundleSharded(t *testing.T) {
+	logo := []byte{0x89, 'P', 'N', 'G', 0xff, 0xfe}
+	large := strings.Repeat("a", 600*1024)
+	fetcher := &fakeFetcher{archives: map[string][]byte{
+		"https://example.com/site-v1.tar.gz": tarball(t,
+			bundleFile{Path: "index.html", Data: []byte("<h1>Docs</h1>")},
+			bundleFile{Path: "./img/logo.png", Data: logo},
+			bundleFile{Path: "docs/a.html", Data: []byte(large)},
+			bundleFile{Path: "docs/b.html", Data: []byte(large)},
+		),
+	}}
+	r := newTestReconciler(t, bundleWebserver("https://example.com/site-v1.tar.gz"))
+	r.Fetcher = fetcher
+
+	deployment := reconcileDeployment(t, r, "site")
+
+	manifest := getWebserver(t, r).Status.ContentManifest
+	if manifest == nil {
+		t.Fatal("Expected a content manifest in status")
+	}
+	wantShards := []string{"site-content-0", "site-content-1"}
+	if fmt.Sprint(manifest.Shards) != fmt.Sprint(wantShards) {
+		t.Errorf("Expected shards %v, got %v", wantShards, manifest.Shards)
+	}
+	wantFiles := []webserverv1alpha1.ContentFile{
+		{Path: "docs/a.html", Size: 600 * 1024, Shard: "site-content-0"},
+		{Path: "docs/b.html", Size: 600 * 1024, Shard: "site-content-1"},
+		{Path: "img/logo.png", Size: int64(len(logo)), Shard: "site-content-1"},
+		{Path: "index.html", Size: 13, Shard: "site-content-1"},
+	}
+	if fmt.Sprint(manifest.Files) != fmt.Sprint(wantFiles) {
+		t.Errorf("Expected files %v, got %v", wantFiles, manifest.Files)
+	}
+	if want := int64(2*600*1024 + len(logo) + 13); manifest.TotalBytes != want {
+		t.Errorf("Expected %d bytes in total, got %d", want, manifest.TotalBytes)
+	}
+
+	shard := &corev1.ConfigMap{}
+	if err := r.Get(context.Background(), types.NamespacedName{Name: "site-content-1", Namespace: "web"}, shard); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(shard.BinaryData[contentKey("img/logo.png")], logo) {
+		t.Errorf("Expected the logo in BinaryData, got %v", shard.BinaryData)
+	}
+	if shard.Data[contentKey("index.html")] != "<h1>Docs</h1>" {
+		t.Errorf("Expected index.html in Data, got %v", shard.Data)
+	}
+
+	var volume *corev1.Volume
+	for i := range deployment.Spec.Template.Spec.Volumes {
+		if deployment.Spec.Template.Spec.Volumes[i].Name == "html-content" {
+			volume = &deployment.Spec.Template.Spec.Volumes[i]
+		}
+	}
+	if volume == nil || volume.Projected == nil {
+		t.Fatalf("Expected a projected html-content volume, got %+v", volume)
+	}
+	sources := volume.Projected.Sources
+	if len(sources) != 2 || sources[0].ConfigMap.Name != "site-content-0" || sources[1].ConfigMap.Name != "site-content-1" {
+		t.Fatalf("Expected only the shards to be projected, got %+v", sources)
+	}
+	if items := sources[1].ConfigMap.Items; len(items) != 3 || items[1].Path != "img/logo.png" || items[1].Key != contentKey("img/logo.png") {
+		t.Errorf("Expected shard files at their paths, got %+v", items)
+	}
+
+	reconcileDeployment(t, r, "site")
+	if fetcher.fetches != 1 {
+		t.Errorf("Expected the unchanged bundle to be downloaded once, got %d downloads", fetcher.fetches)
+	}
+}
+
+func TestContentBundleKeepsGeneratedIndex(t *testing.T) {
+	fetcher := &fakeFetcher{archives: map[string][]byte{
+		"https://example.com/assets.tar.gz": tarball(t, bundleFile{Path: "css/site.css", Data: []byte("body {}")}),
+	}}
+	r := newTestReconciler(t, bundleWebserver("https://example.com/assets.tar.gz"))
+	r.Fetcher = fetcher
+
+	deployment := reconcileDeployment(t, r, "site")
+
+	sources := deployment.Spec.Template.Spec.Volumes[0].Projected.Sources
+	if len(sources) != 2 || sources[0].ConfigMap.Name != "site-config" || sources[0].ConfigMap.Items[0].Path != "index.html" {
+		t.Errorf("Expected the generated page next to the bundle, got %+v", sources)
+	}
+}
+
+func TestContentBundleStaleShardsDeleted(t *testing.T) {
+	large := strings.Repeat("a", 600*1024)
+	fetcher := &fakeFetcher{archives: map[string][]byte{
+		"https://example.com/v1.tar.gz": tarball(t,
+			bundleFile{Path: "a.html", Data: []byte(large)},
+			bundleFile{Path: "b.html", Data: []byte(large)},
+			bundleFile{Path: "c.html", Data: []byte(large)},
+		),
+		"https://example.com/v2.tar.gz": tarball(t, bundleFile{Path: "index.html", Data: []byte("v2")}),
+	}}
+	r := newTestReconciler(t, bundleWebserver("https://example.com/v1.tar.gz"))
+	r.Fetcher = fetcher
+	reconcileDeployment(t, r, "site")
+
+	webserver := getWebserver(t, r)
+	if len(webserver.Status.ContentManifest.Shards) != 3 {
+		t.Fatalf("Expected 3 shards, got %v", webserver.Status.ContentManifest.Shards)
+	}
+	webserver.Spec.Bundle.URL = "https://example.com/v2.tar.gz"
+	if err := r.Update(context.Background(), webserver); err != nil {
+		t.Fatal(err)
+	}
+	reconcileDeployment(t, r, "site")
+
+	for _, name := range []string{"site-content-1", "site-content-2"} {
+		err := r.Get(context.Background(), types.NamespacedName{Name: name, Namespace: "web"}, &corev1.ConfigMap{})
+		if !apierrors.IsNotFound(err) {
+			t.Errorf("Expected stale shard %s to be deleted, got %v", name, err)
+		}
+	}
+	shard := &corev1.ConfigMap{}
+	if err := r.Get(context.Background(), types.NamespacedName{Name: "site-content-0", Namespace: "web"}, shard); err != nil {
+		t.Fatal(err)
+	}
+	if len(shard.Data) != 1 || shard.Data[contentKey("index.html")] != "v2" {
+		t.Errorf("Expected the first shard to hold the new bundle, got %v", shard.Data)
+	}
+
+	webserver = getWebserver(t, r)
+	webserver.Spec.Bundle = nil
+	if err := r.Update(context.Background(), webserver); err != nil {
+		t.Fatal(err)
+	}
+	deployment := reconcileDeployment(t, r, "site")
+
+	if err := r.Get(context.Background(), types.NamespacedName{Name: "site-content-0", Namespace: "web"}, shard); !apierrors.IsNotFound(err) {
+		t.Errorf("Expected every shard to be deleted without a bundle, got %v", err)
+	}
+	if deployment.Spec.Template.Spec.Volumes[0].ConfigMap == nil {
+		t.Errorf("Expected the generated page to be mounted again, got %+v", deployment.Spec.Template.Spec.Volumes[0])
+	}
+}
+
+func TestContentBundleRejected(t *testing.T) {
+	archive := tarball(t, bundleFile{Path: "index.html", Data: []byte("ok")})
+	sum := sha256.Sum256([]byte("other"))
+
+	tests := []struct {
+		name    string
+		archive []byte
+		sha256  string
+		want    string
+	}{
+		{
+			name:    "path outside the document root",
+			archive: tarball(t, bundleFile{Path: "../etc/passwd", Data: []byte("root")}),
+			want:    `path "../etc/passwd" is outside the document root`,
+		},
+		{
+			name:    "file larger than a shard",
+			archive: tarball(t, bundleFile{Path: "video.mp4", Data: bytes.Repeat([]byte{0xff}, contentShardBytes)}),
+			want:    "video.mp4 is 921600 bytes",
+		},
+		{
+			name:    "checksum mismatch",
+			archive: archive,
+			sha256:  hex.EncodeToString(sum[:]),
+			want:    "does not match spec.bundle.sha256",
+		},
+		{
+			name:    "not an archive",
+			archive: []byte("<html>not found</html>"),
+			want:    "invalid content bundle",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			webserver := bundleWebserver("https://example.com/site.tar.gz")
+			webserver.Spec.Bundle.SHA256 = tt.sha256
+			r := newTestReconciler(t, webserver)
+			r.Fetcher = &fakeFetcher{archives: map[string][]byte{"https://example.com/site.tar.gz": tt.archive}}
+
+			req := ctrl.Request{NamespacedName: types.NamespacedName{Name: "site", Namespace: "web"}}
+			if _, err := r.Reconcile(context.Background(), req); err != nil {
+				t.Fatalf("Reconcile() error = %v", err)
+			}
+
+			condition := meta.FindStatusCondition(getWebserver(t, r).Status.Conditions, "Ready")
+			if condition == nil || condition.Reason != "InvalidContentBundle" || !strings.Contains(condition.Message, tt.want) {
+				t.Errorf("Expected InvalidContentBundle mentioning %q, got %+v", tt.want, condition)
+			}
+		})
+	}
+}
@@ -0,0 +1,195 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	webserverv1alpha1 "github.com/webserver/webserver-operator/api/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+)
+
+const (
+	// contentMarkerAnnotation holds the marker of the page rendered into the ConfigMap
+	contentMarkerAnnotation = "webserver.io/content-marker"
+
+	// contentMarkerMeta is the name of the meta tag carrying the marker in the page
+	contentMarkerMeta = "webserver-content-marker"
+
+	// ConditionContentVerified reports whether the served page matches the rendered content
+	ConditionContentVerified = "ContentVerified"
+
+	// contentVerificationRetry is how soon an unverified Webserver is checked again
+	contentVerificationRetry = 30 * time.Second
+)
+
+// ErrContentMismatch is returned when the served page does not carry the expected marker
+var ErrContentMismatch = errors.New("served content does not match rendered content")
+
+// ContentVerifier fetches a served page and checks it against the expected marker
+type ContentVerifier interface {
+	Verify(ctx context.Context, url, marker string) (time.Duration, error)
+}
+
+// HTTPContentVerifier verifies content by issuing a GET request to the page
+type HTTPContentVerifier struct {
+	// Client is the HTTP client used for requests; a client with Timeout is used when nil
+	Client *http.Client
+
+	// Timeout bounds each request when Client is nil
+	Timeout time.Duration
+}
+
+// Verify fetches url and checks that the page carries marker. The returned
+// latency covers the request and reading the body.
+func (v *HTTPContentVerifier) Verify(ctx context.Context, url, marker string) (time.Duration, error) {
+	httpClient := v.Client
+	if httpClient == nil {
+		timeout := v.Timeout
+		if timeout == 0 {
+			timeout = 10 * time.Second
+		}
+		httpClient = &http.Client{Timeout: timeout}
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	start := time.Now()
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	latency := time.Since(start)
+	if err != nil {
+		return latency, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return latency, fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+	if !bytes.Contains(body, []byte(fmt.Sprintf(`<meta name="%s" content="%s">`, contentMarkerMeta, marker))) {
+		return latency, ErrContentMismatch
+	}
+
+	return latency, nil
+}
+
+// contentMarker derives a stable marker from everything rendered into the
+// page except the generation timestamp
+func contentMarker(webserver *webserverv1alpha1.Webserver) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s|%s|%s|%s|%s|%d|%s|%d|%s",
+		webserver.Spec.Config.Title,
+		webserver.Spec.Config.Color,
+		webserver.Spec.Config.Message,
+		webserver.Name,
+		webserver.Namespace,
+		webserver.Spec.Replicas,
+		webserver.Spec.Image,
+		webserver.Spec.Port,
+		webserver.Spec.ServiceType) + localeDigest(webserver)))
+	return hex.EncodeToString(sum[:8])
+}
+
+// verifyContent fetches the page through the Service once the rollout has
+// finished and records the result as the ContentVerified condition. It
+// returns how soon the check should be retried, or zero when it passed.
+func (r *WebserverReconciler) verifyContent(ctx context.Context, webserver *webserverv1alpha1.Webserver) (time.Duration, error) {
+	if r.Verifier == nil {
+		return 0, nil
+	}
+
+	// A bundle serving its own index.html carries no marker to check
+	if bundleServesIndex(webserver) {
+		meta.RemoveStatusCondition(&webserver.Status.Conditions, ConditionContentVerified)
+		webserver.Status.ContentVerification = nil
+		return 0, nil
+	}
+
+	deployment := &appsv1.Deployment{}
+	if err := r.Get(ctx, types.NamespacedName{
+		Name:      webserver.Name + "-deployment",
+		Namespace: webserver.Namespace,
+	}, deployment); err != nil {
+		return 0, err
+	}
+
+	marker := contentMarker(webserver)
+	condition := metav1.Condition{
+		Type:               ConditionContentVerified,
+		ObservedGeneration: webserver.Generation,
+	}
+
+	if !rolloutComplete(deployment) {
+		condition.Status = metav1.ConditionUnknown
+		condition.Reason = "RolloutInProgress"
+		condition.Message = "Waiting for the rollout to finish before verifying content"
+		meta.SetStatusCondition(&webserver.Status.Conditions, condition)
+		return contentVerificationRetry, nil
+	}
+
+	latency, err := r.Verifier.Verify(ctx, webserver.Status.InternalURL, marker)
+	switch {
+	case err == nil:
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = "ContentMatched"
+		condition.Message = fmt.Sprintf("Served page matches rendered content (%s)", latency.Round(time.Millisecond))
+	case errors.Is(err, ErrContentMismatch):
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = "ContentMismatch"
+		condition.Message = fmt.Sprintf("Served page does not carry content marker %s", marker)
+	default:
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = "FetchFailed"
+		condition.Message = err.Error()
+	}
+
+	// Record the check only when its outcome changed. The status write of
+	// every fetch would otherwise trigger the next reconcile and fetch.
+	previous := meta.FindStatusCondition(webserver.Status.Conditions, ConditionContentVerified)
+	if previous != nil && previous.Reason == condition.Reason && previous.ObservedGeneration == condition.ObservedGeneration &&
+		webserver.Status.ContentVerification != nil && webserver.Status.ContentVerification.Marker == marker {
+		condition.Message = previous.Message
+	} else {
+		now := metav1.Now()
+		webserver.Status.ContentVerification = &webserverv1alpha1.ContentVerificationStatus{
+			Marker:              marker,
+			LatencyMilliseconds: latency.Milliseconds(),
+			LastCheckTime:       &now,
+		}
+	}
+	meta.SetStatusCondition(&webserver.Status.Conditions, condition)
+
+	if condition.Status != metav1.ConditionTrue {
+		return contentVerificationRetry, nil
+	}
+	return 0, nil
+}
+
+// rolloutComplete reports whether every desired replica runs the latest template
+func rolloutComplete(deployment *appsv1.Deployment) bool {
+	if deployment.Status.ObservedGeneration < deployment.Generation {
+		return false
+	}
+	desired := int32(1)
+	if deployment.Spec.Replicas != nil {
+		desired = *deployment.Spec.Replicas
+	}
+	return deployment.Status.UpdatedReplicas == desired &&
+		deployment.Status.ReadyReplicas == desired &&
+		deployment.Status.Replicas == desired
+}
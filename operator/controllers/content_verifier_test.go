@@ -0,0 +1,170 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	webserverv1alpha1 "github.com/webserver/webserver-operator/api/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+func newTestScheme(t *testing.T) *runtime.Scheme {
+	t.Helper()
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := webserverv1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	return scheme
+}
+
+func TestHTTPContentVerifier(t *testing.T) {
+	webserver := &webserverv1alpha1.Webserver{
+		ObjectMeta: metav1.ObjectMeta{Name: "site", Namespace: "web"},
+		Spec: webserverv1alpha1.WebserverSpec{
+			Replicas: 1,
+			Image:    "nginx:1.25",
+			Port:     80,
+			Config:   webserverv1alpha1.WebserverConfig{Title: "Hello", Message: "World", Color: "#fff"},
+		},
+	}
+
+	r := &WebserverReconciler{Scheme: newTestScheme(t)}
+	configmap := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "site-config", Namespace: "web"}}
+	if err := r.mutateConfigMap(configmap, webserver); err != nil {
+		t.Fatal(err)
+	}
+	page := configmap.Data["index.html"]
+	marker := configmap.Annotations[contentMarkerAnnotation]
+	if marker != contentMarker(webserver) {
+		t.Fatalf("Expected annotation to carry marker %q, got %q", contentMarker(webserver), marker)
+	}
+
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		wantErr bool
+		match   error
+	}{
+		{name: "rendered page matches", status: http.StatusOK, body: page},
+		{name: "stale page", status: http.StatusOK, body: "<html>old</html>", wantErr: true, match: ErrContentMismatch},
+		{name: "server error", status: http.StatusInternalServerError, body: page, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(tt.status)
+				_, _ = w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			verifier := &HTTPContentVerifier{Client: server.Client()}
+			latency, err := verifier.Verify(context.Background(), server.URL, marker)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Verify() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.match != nil && !errors.Is(err, tt.match) {
+				t.Errorf("Expected error %v, got %v", tt.match, err)
+			}
+			if latency <= 0 {
+				t.Errorf("Expected a positive latency, got %v", latency)
+			}
+		})
+	}
+}
+
+// stubVerifier reports a fixed result and counts the fetches
+type stubVerifier struct {
+	calls   int
+	latency time.Duration
+	err     error
+}
+
+func (v *stubVerifier) Verify(context.Context, string, string) (time.Duration, error) {
+	v.calls++
+	v.latency += time.Millisecond
+	return v.latency, v.err
+}
+
+// completeRollout marks the Deployment of the site Webserver as fully rolled out
+func completeRollout(t *testing.T, r *WebserverReconciler) {
+	t.Helper()
+	deployment := &appsv1.Deployment{}
+	if err := r.Get(context.Background(), types.NamespacedName{Name: "site-deployment", Namespace: "web"}, deployment); err != nil {
+		t.Fatal(err)
+	}
+	deployment.Status = appsv1.DeploymentStatus{
+		ObservedGeneration: deployment.Generation,
+		Replicas:           *deployment.Spec.Replicas,
+		UpdatedReplicas:    *deployment.Spec.Replicas,
+		ReadyReplicas:      *deployment.Spec.Replicas,
+	}
+	if err := r.Status().Update(context.Background(), deployment); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestContentVerificationSettles(t *testing.T) {
+	r := newTestReconciler(t, &webserverv1alpha1.Webserver{ObjectMeta: metav1.ObjectMeta{Name: "site", Namespace: "web"}})
+	verifier := &stubVerifier{}
+	r.Verifier = verifier
+	ctx := context.Background()
+	key := types.NamespacedName{Name: "site", Namespace: "web"}
+
+	if _, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: key}); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	completeRollout(t, r)
+	if _, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: key}); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	verified := &webserverv1alpha1.Webserver{}
+	if err := r.Get(ctx, key, verified); err != nil {
+		t.Fatal(err)
+	}
+	if condition := meta.FindStatusCondition(verified.Status.Conditions, ConditionContentVerified); condition == nil || condition.Reason != "ContentMatched" {
+		t.Fatalf("Expected the content to be verified, got %v", verified.Status.Conditions)
+	}
+
+	// Later fetches with the same outcome do not write the status again
+	if _, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: key}); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	settled := &webserverv1alpha1.Webserver{}
+	if err := r.Get(ctx, key, settled); err != nil {
+		t.Fatal(err)
+	}
+	if settled.ResourceVersion != verified.ResourceVersion {
+		t.Errorf("Expected no status write for an unchanged outcome, resourceVersion went from %s to %s", verified.ResourceVersion, settled.ResourceVersion)
+	}
+	if verifier.calls != 2 {
+		t.Errorf("Expected one fetch per completed reconcile, got %d", verifier.calls)
+	}
+
+	// A changed outcome is recorded
+	verifier.err = ErrContentMismatch
+	if _, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: key}); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	if err := r.Get(ctx, key, settled); err != nil {
+		t.Fatal(err)
+	}
+	if condition := meta.FindStatusCondition(settled.Status.Conditions, ConditionContentVerified); condition == nil || condition.Reason != "ContentMismatch" {
+		t.Errorf("Expected the mismatch to be recorded, got %v", settled.Status.Conditions)
+	}
+}
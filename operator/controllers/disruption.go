@@ -0,0 +1,76 @@
+package controllers
+
+import (
+	"context"
+
+	policyv1 "k8s.io/api/policy/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	webserverv1alpha1 "github.com/webserver/webserver-operator/api/v1alpha1"
+)
+
+//+kubebuilder:rbac:groups=policy,resources=poddisruptionbudgets,verbs=get;list;watch;create;update;patch;delete
+
+// reconcilePodDisruptionBudget keeps a PodDisruptionBudget for Webservers
+// running more than one replica. A single replica gets none so that node
+// drains are never blocked.
+func (r *WebserverReconciler) reconcilePodDisruptionBudget(ctx context.Context, webserver *webserverv1alpha1.Webserver) error {
+	pdb := &policyv1.PodDisruptionBudget{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      webserver.Name + "-pdb",
+			Namespace: webserver.Namespace,
+		},
+	}
+
+	if webserver.Spec.Replicas <= 1 {
+		if err := r.Get(ctx, client.ObjectKeyFromObject(pdb), pdb); err != nil {
+			return client.IgnoreNotFound(err)
+		}
+		return client.IgnoreNotFound(r.Delete(ctx, pdb))
+	}
+
+	op, err := r.createOrUpdate(ctx, "mutatePodDisruptionBudget", webserver, pdb, func() error {
+		return r.mutatePodDisruptionBudget(pdb, webserver)
+	})
+	if err != nil {
+		return err
+	}
+	if op != controllerutil.OperationResultNone {
+		log.FromContext(ctx).Info("PodDisruptionBudget operation", "operation", op)
+	}
+	return nil
+}
+
+// mutatePodDisruptionBudget creates or updates the PodDisruptionBudget
+func (r *WebserverReconciler) mutatePodDisruptionBudget(pdb *policyv1.PodDisruptionBudget, webserver *webserverv1alpha1.Webserver) error {
+	if err := ctrl.SetControllerReference(webserver, pdb, r.Scheme); err != nil {
+		return err
+	}
+	r.setChildMetadata(pdb, webserver)
+	pdb.Spec.Selector = &metav1.LabelSelector{
+		MatchLabels: selectorLabels(webserver),
+	}
+	pdb.Spec.MinAvailable, pdb.Spec.MaxUnavailable = disruptionBudget(webserver.Spec.Disruption)
+	return nil
+}
+
+// disruptionBudget returns the minAvailable and maxUnavailable of the budget,
+// allowing one pod down at a time unless the Webserver says otherwise
+func disruptionBudget(disruption *webserverv1alpha1.DisruptionSpec) (*intstr.IntOrString, *intstr.IntOrString) {
+	switch {
+	case disruption != nil && disruption.MinAvailable != nil:
+		minAvailable := *disruption.MinAvailable
+		return &minAvailable, nil
+	case disruption != nil && disruption.MaxUnavailable != nil:
+		maxUnavailable := *disruption.MaxUnavailable
+		return nil, &maxUnavailable
+	default:
+		maxUnavailable := intstr.FromInt32(1)
+		return nil, &maxUnavailable
+	}
+}
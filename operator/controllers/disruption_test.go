@@ -0,0 +1,104 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	webserverv1alpha1 "github.com/webserver/webserver-operator/api/v1alpha1"
+	policyv1 "k8s.io/api/policy/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/utils/ptr"
+)
+
+func getPodDisruptionBudget(t *testing.T, r *WebserverReconciler) (*policyv1.PodDisruptionBudget, error) {
+	t.Helper()
+	pdb := &policyv1.PodDisruptionBudget{}
+	err := r.Get(context.Background(), types.NamespacedName{Name: "site-pdb", Namespace: "web"}, pdb)
+	return pdb, err
+}
+
+func TestPodDisruptionBudget(t *testing.T) {
+	tests := []struct {
+		name               string
+		disruption         *webserverv1alpha1.DisruptionSpec
+		wantMinAvailable   *intstr.IntOrString
+		wantMaxUnavailable *intstr.IntOrString
+	}{
+		{
+			name:               "default",
+			wantMaxUnavailable: ptr.To(intstr.FromInt32(1)),
+		},
+		{
+			name:             "minAvailable",
+			disruption:       &webserverv1alpha1.DisruptionSpec{MinAvailable: ptr.To(intstr.FromString("50%"))},
+			wantMinAvailable: ptr.To(intstr.FromString("50%")),
+		},
+		{
+			name:               "maxUnavailable",
+			disruption:         &webserverv1alpha1.DisruptionSpec{MaxUnavailable: ptr.To(intstr.FromInt32(2))},
+			wantMaxUnavailable: ptr.To(intstr.FromInt32(2)),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			webserver := &webserverv1alpha1.Webserver{
+				ObjectMeta: metav1.ObjectMeta{Name: "site", Namespace: "web"},
+				Spec:       webserverv1alpha1.WebserverSpec{Replicas: 3, Disruption: tt.disruption},
+			}
+			r := newTestReconciler(t, webserver)
+
+			reconcileDeployment(t, r, "site")
+
+			pdb, err := getPodDisruptionBudget(t, r)
+			if err != nil {
+				t.Fatalf("Expected a PodDisruptionBudget, got %v", err)
+			}
+			if pdb.Spec.Selector.MatchLabels["instance"] != "site" {
+				t.Errorf("Expected the budget to select the site pods, got %v", pdb.Spec.Selector)
+			}
+			if !equalIntOrString(pdb.Spec.MinAvailable, tt.wantMinAvailable) {
+				t.Errorf("Expected minAvailable %v, got %v", tt.wantMinAvailable, pdb.Spec.MinAvailable)
+			}
+			if !equalIntOrString(pdb.Spec.MaxUnavailable, tt.wantMaxUnavailable) {
+				t.Errorf("Expected maxUnavailable %v, got %v", tt.wantMaxUnavailable, pdb.Spec.MaxUnavailable)
+			}
+		})
+	}
+}
+
+func TestPodDisruptionBudgetRemovedAtOneReplica(t *testing.T) {
+	webserver := &webserverv1alpha1.Webserver{
+		ObjectMeta: metav1.ObjectMeta{Name: "site", Namespace: "web"},
+		Spec:       webserverv1alpha1.WebserverSpec{Replicas: 2},
+	}
+	r := newTestReconciler(t, webserver)
+
+	reconcileDeployment(t, r, "site")
+	if _, err := getPodDisruptionBudget(t, r); err != nil {
+		t.Fatalf("Expected a PodDisruptionBudget at 2 replicas, got %v", err)
+	}
+
+	if err := r.Get(context.Background(), types.NamespacedName{Name: "site", Namespace: "web"}, webserver); err != nil {
+		t.Fatal(err)
+	}
+	webserver.Spec.Replicas = 1
+	if err := r.Update(context.Background(), webserver); err != nil {
+		t.Fatal(err)
+	}
+	reconcileDeployment(t, r, "site")
+
+	if _, err := getPodDisruptionBudget(t, r); !apierrors.IsNotFound(err) {
+		t.Errorf("Expected the PodDisruptionBudget to be removed at 1 replica, got %v", err)
+	}
+}
+
+func equalIntOrString(a, b *intstr.IntOrString) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
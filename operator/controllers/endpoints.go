@@ -0,0 +1,115 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	webserverv1alpha1 "github.com/webserver/webserver-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+)
+
+// updateEndpoints records where the Webserver can be reached, based on the
+// owned Service and any owned Ingress routes
+func (r *WebserverReconciler) updateEndpoints(ctx context.Context, webserver *webserverv1alpha1.Webserver, service *corev1.Service) error {
+	ingresses := &networkingv1.IngressList{}
+	if err := r.List(ctx, ingresses,
+		client.InNamespace(webserver.Namespace),
+		client.MatchingLabels{"instance": webserver.Name},
+	); err != nil {
+		return err
+	}
+
+	var owned []networkingv1.Ingress
+	for _, ingress := range ingresses.Items {
+		if metav1.IsControlledBy(&ingress, webserver) {
+			owned = append(owned, ingress)
+		}
+	}
+
+	setEndpointStatus(&webserver.Status, service, owned)
+	return nil
+}
+
+// setEndpointStatus fills the URL, InternalURL, LoadBalancerIngress and
+// NodePort status fields. The public URL prefers an Ingress host, then a
+// load balancer address, and falls back to the in-cluster address.
+func setEndpointStatus(status *webserverv1alpha1.WebserverStatus, service *corev1.Service, ingresses []networkingv1.Ingress) {
+	status.URL = ""
+	status.InternalURL = ""
+	status.LoadBalancerIngress = nil
+	status.NodePort = 0
+
+	var port corev1.ServicePort
+	for _, p := range service.Spec.Ports {
+		if p.Name == "http" {
+			port = p
+			break
+		}
+	}
+	if port.Port == 0 && len(service.Spec.Ports) > 0 {
+		port = service.Spec.Ports[0]
+	}
+
+	// A headless Service resolves to the pods, so clients connect to the target port
+	internalPort := port.Port
+	if service.Spec.ClusterIP == corev1.ClusterIPNone && port.TargetPort.Type == intstr.Int {
+		internalPort = port.TargetPort.IntVal
+	}
+	status.InternalURL = httpURL("http", fmt.Sprintf("%s.%s.svc", service.Name, service.Namespace), internalPort)
+
+	if service.Spec.Type == corev1.ServiceTypeNodePort || service.Spec.Type == corev1.ServiceTypeLoadBalancer {
+		status.NodePort = port.NodePort
+	}
+
+	for _, ingress := range service.Status.LoadBalancer.Ingress {
+		if ingress.Hostname != "" {
+			status.LoadBalancerIngress = append(status.LoadBalancerIngress, ingress.Hostname)
+		} else if ingress.IP != "" {
+			status.LoadBalancerIngress = append(status.LoadBalancerIngress, ingress.IP)
+		}
+	}
+
+	if url := ingressURL(ingresses); url != "" {
+		status.URL = url
+	} else if len(status.LoadBalancerIngress) > 0 {
+		status.URL = httpURL("http", status.LoadBalancerIngress[0], port.Port)
+	} else {
+		status.URL = status.InternalURL
+	}
+}
+
+// ingressURL returns the URL of the first host found on the given Ingresses,
+// using https when the host is covered by a TLS section
+func ingressURL(ingresses []networkingv1.Ingress) string {
+	for _, ingress := range ingresses {
+		for _, rule := range ingress.Spec.Rules {
+			if rule.Host == "" {
+				continue
+			}
+			scheme := "http"
+			for _, tls := range ingress.Spec.TLS {
+				for _, host := range tls.Hosts {
+					if host == rule.Host {
+						scheme = "https"
+					}
+				}
+			}
+			return scheme + "://" + rule.Host
+		}
+	}
+	return ""
+}
+
+// httpURL builds a URL for host, leaving out the port when it is the default
+// for the scheme
+func httpURL(scheme, host string, port int32) string {
+	if port == 0 || (scheme == "http" && port == 80) || (scheme == "https" && port == 443) {
+		return scheme + "://" + host
+	}
+	return fmt.Sprintf("%s://%s:%d", scheme, host, port)
+}
@@ -0,0 +1,89 @@
+package controllers
+
+import (
+	"testing"
+
+	webserverv1alpha1 "github.com/webserver/webserver-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestSetEndpointStatus(t *testing.T) {
+	service := func(serviceType corev1.ServiceType, nodePort int32, lb ...corev1.LoadBalancerIngress) *corev1.Service {
+		return &corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{Name: "site-service", Namespace: "web"},
+			Spec: corev1.ServiceSpec{
+				Type:  serviceType,
+				Ports: []corev1.ServicePort{{Name: "http", Port: 80, NodePort: nodePort}},
+			},
+			Status: corev1.ServiceStatus{
+				LoadBalancer: corev1.LoadBalancerStatus{Ingress: lb},
+			},
+		}
+	}
+
+	tests := []struct {
+		name      string
+		service   *corev1.Service
+		ingresses []networkingv1.Ingress
+		wantURL   string
+		wantLB    []string
+		wantNode  int32
+	}{
+		{
+			name:    "cluster ip falls back to internal url",
+			service: service(corev1.ServiceTypeClusterIP, 0),
+			wantURL: "http://site-service.web.svc",
+		},
+		{
+			name:     "node port is reported",
+			service:  service(corev1.ServiceTypeNodePort, 30080),
+			wantURL:  "http://site-service.web.svc",
+			wantNode: 30080,
+		},
+		{
+			name:     "load balancer address is preferred",
+			service:  service(corev1.ServiceTypeLoadBalancer, 30081, corev1.LoadBalancerIngress{IP: "203.0.113.7"}),
+			wantURL:  "http://203.0.113.7",
+			wantLB:   []string{"203.0.113.7"},
+			wantNode: 30081,
+		},
+		{
+			name:    "ingress host with tls wins",
+			service: service(corev1.ServiceTypeClusterIP, 0),
+			ingresses: []networkingv1.Ingress{{
+				Spec: networkingv1.IngressSpec{
+					TLS:   []networkingv1.IngressTLS{{Hosts: []string{"site.example.com"}}},
+					Rules: []networkingv1.IngressRule{{Host: "site.example.com"}},
+				},
+			}},
+			wantURL: "https://site.example.com",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status := &webserverv1alpha1.WebserverStatus{}
+			setEndpointStatus(status, tt.service, tt.ingresses)
+
+			if status.URL != tt.wantURL {
+				t.Errorf("Expected URL %q, got %q", tt.wantURL, status.URL)
+			}
+			if status.InternalURL != "http://site-service.web.svc" {
+				t.Errorf("Unexpected internal URL %q", status.InternalURL)
+			}
+			if len(status.LoadBalancerIngress) != len(tt.wantLB) {
+				t.Fatalf("Expected load balancer ingress %v, got %v", tt.wantLB, status.LoadBalancerIngress)
+			}
+			for i := range tt.wantLB {
+				if status.LoadBalancerIngress[i] != tt.wantLB[i] {
+					t.Errorf("Expected load balancer ingress %v, got %v", tt.wantLB, status.LoadBalancerIngress)
+				}
+			}
+			if status.NodePort != tt.wantNode {
+				t.Errorf("Expected node port %d, got %d", tt.wantNode, status.NodePort)
+			}
+		})
+	}
+}
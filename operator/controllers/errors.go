@@ -0,0 +1,131 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	webserverv1alpha1 "github.com/webserver/webserver-operator/api/v1alpha1"
+)
+
+// ErrorClass decides how a failed reconcile is retried
+type ErrorClass string
+
+const (
+	// ErrorConflict is a write that lost a race with another writer; it is
+	// retried at once without backoff
+	ErrorConflict ErrorClass = "Conflict"
+
+	// ErrorTransient may go away by itself; it is retried with the backoff of
+	// the rate limiter
+	ErrorTransient ErrorClass = "Transient"
+
+	// ErrorPermanent is a spec that cannot be reconciled; the Webserver is
+	// failed and left alone until its generation changes
+	ErrorPermanent ErrorClass = "Permanent"
+
+	// ErrorDependencyMissing waits for another object, such as a
+	// WebserverClass or WebserverQuota, to be created or changed; the watch on
+	// that object requeues the Webserver
+	ErrorDependencyMissing ErrorClass = "DependencyMissing"
+)
+
+const (
+	// stalledCondition marks a Webserver whose current generation cannot be reconciled
+	stalledCondition = "Stalled"
+
+	// conflictRetry is how soon a reconcile that lost a write race is retried
+	conflictRetry = 10 * time.Millisecond
+)
+
+// reconcileError is a failure with a known class and the reason reported in
+// the Ready condition
+type reconcileError struct {
+	class   ErrorClass
+	reason  string
+	message string
+}
+
+func (e *reconcileError) Error() string {
+	return e.reason + ": " + e.message
+}
+
+// permanentError reports a spec that cannot be reconciled
+func permanentError(reason, message string) error {
+	return &reconcileError{class: ErrorPermanent, reason: reason, message: message}
+}
+
+// dependencyMissingError reports a Webserver waiting for another object
+func dependencyMissingError(reason, message string) error {
+	return &reconcileError{class: ErrorDependencyMissing, reason: reason, message: message}
+}
+
+// classifyError returns the class of a reconcile error. API errors rejecting
+// a child as invalid are permanent; errors of unknown origin are transient.
+func classifyError(err error) ErrorClass {
+	var classified *reconcileError
+	switch {
+	case errors.As(err, &classified):
+		return classified.class
+	case apierrors.IsConflict(err), apierrors.IsAlreadyExists(err):
+		return ErrorConflict
+	case apierrors.IsInvalid(err), apierrors.IsBadRequest(err):
+		return ErrorPermanent
+	default:
+		return ErrorTransient
+	}
+}
+
+// handleError turns a failed reconcile into the result its class asks for
+func (r *WebserverReconciler) handleError(ctx context.Context, original, webserver *webserverv1alpha1.Webserver, err error) (ctrl.Result, error) {
+	reason, message := "ReconcileError", err.Error()
+	var classified *reconcileError
+	if errors.As(err, &classified) {
+		reason, message = classified.reason, classified.message
+	}
+
+	switch classifyError(err) {
+	case ErrorPermanent:
+		if classified == nil {
+			reason = "Invalid"
+		}
+		r.markFailed(ctx, webserver, reason, message)
+		meta.SetStatusCondition(&webserver.Status.Conditions, metav1.Condition{
+			Type:               stalledCondition,
+			Status:             metav1.ConditionTrue,
+			ObservedGeneration: webserver.Generation,
+			Reason:             reason,
+			Message:            "Waiting for the spec to change: " + message,
+		})
+		return retryResult(r.writeStatus(ctx, original, webserver))
+	case ErrorDependencyMissing:
+		return retryResult(r.setFailed(ctx, original, webserver, reason, message))
+	default:
+		return retryResult(err)
+	}
+}
+
+// retryResult requeues conflicts at once and leaves other errors to the
+// backoff of the rate limiter
+func retryResult(err error) (ctrl.Result, error) {
+	switch {
+	case err == nil:
+		return ctrl.Result{}, nil
+	case classifyError(err) == ErrorConflict:
+		return ctrl.Result{RequeueAfter: conflictRetry}, nil
+	default:
+		return ctrl.Result{}, err
+	}
+}
+
+// stalled reports whether the current generation of the Webserver already
+// failed permanently
+func stalled(webserver *webserverv1alpha1.Webserver) bool {
+	condition := meta.FindStatusCondition(webserver.Status.Conditions, stalledCondition)
+	return condition != nil && condition.Status == metav1.ConditionTrue && condition.ObservedGeneration == webserver.Generation
+}
@@ -0,0 +1,166 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	webserverv1alpha1 "github.com/webserver/webserver-operator/api/v1alpha1"
+)
+
+// newInterceptedReconciler builds a reconciler whose client passes every call
+// through funcs and bumps the generation on updates like the API server does
+func newInterceptedReconciler(t *testing.T, funcs interceptor.Funcs, objs ...client.Object) *WebserverReconciler {
+	t.Helper()
+	scheme := newTestScheme(t)
+	if funcs.Update == nil {
+		funcs.Update = func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.UpdateOption) error {
+			if webserver, ok := obj.(*webserverv1alpha1.Webserver); ok {
+				webserver.Generation++
+			}
+			return c.Update(ctx, obj, opts...)
+		}
+	}
+	c := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(objs...).
+		WithStatusSubresource(&webserverv1alpha1.Webserver{}, &webserverv1alpha1.WebserverQuota{}).
+		WithInterceptorFuncs(funcs).
+		Build()
+	return &WebserverReconciler{Client: c, Scheme: scheme}
+}
+
+func TestClassifyError(t *testing.T) {
+	deployments := schema.GroupResource{Group: "apps", Resource: "deployments"}
+	tests := []struct {
+		err  error
+		want ErrorClass
+	}{
+		{apierrors.NewConflict(deployments, "site", errors.New("modified")), ErrorConflict},
+		{apierrors.NewAlreadyExists(deployments, "site"), ErrorConflict},
+		{apierrors.NewInvalid(schema.GroupKind{Group: "apps", Kind: "Deployment"}, "site", field.ErrorList{}), ErrorPermanent},
+		{apierrors.NewBadRequest("bad"), ErrorPermanent},
+		{apierrors.NewServiceUnavailable("down"), ErrorTransient},
+		{errors.New("connection refused"), ErrorTransient},
+		{permanentError("InvalidService", "bad port"), ErrorPermanent},
+		{fmt.Errorf("wrapped: %w", dependencyMissingError("ClassNotFound", "missing")), ErrorDependencyMissing},
+	}
+	for _, tt := range tests {
+		if got := classifyError(tt.err); got != tt.want {
+			t.Errorf("classifyError(%v): expected %s, got %s", tt.err, tt.want, got)
+		}
+	}
+}
+
+func TestPermanentErrorStallsUntilSpecChanges(t *testing.T) {
+	webserver := localizedWebserver(map[string]webserverv1alpha1.LocaleContent{
+		"fr": {Template: "<p>{{.Greeting}}</p>"},
+	})
+	webserver.Generation = 1
+	r := newInterceptedReconciler(t, interceptor.Funcs{}, webserver)
+	ctx := context.Background()
+	key := types.NamespacedName{Name: "site", Namespace: "web"}
+
+	result, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: key})
+	if err != nil || result.RequeueAfter != 0 {
+		t.Fatalf("Expected no retry of a permanent error, got %+v, %v", result, err)
+	}
+	current := &webserverv1alpha1.Webserver{}
+	if err := r.Get(ctx, key, current); err != nil {
+		t.Fatal(err)
+	}
+	stalledCond := meta.FindStatusCondition(current.Status.Conditions, stalledCondition)
+	if current.Status.Phase != "Failed" || stalledCond == nil || stalledCond.Reason != "InvalidLocale" || stalledCond.ObservedGeneration != 1 {
+		t.Fatalf("Expected the Webserver to be stalled with InvalidLocale, got %s %v", current.Status.Phase, current.Status.Conditions)
+	}
+
+	// The same generation is not reconciled again
+	if _, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: key}); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	if err := r.Get(ctx, key, &appsv1.Deployment{}); !apierrors.IsNotFound(err) {
+		t.Errorf("Expected no Deployment for a stalled Webserver, got %v", err)
+	}
+
+	current.Spec.Config.Locales["fr"] = webserverv1alpha1.LocaleContent{Title: "Bienvenue"}
+	if err := r.Update(ctx, current); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: key}); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	if err := r.Get(ctx, key, current); err != nil {
+		t.Fatal(err)
+	}
+	if current.Status.Phase != "Ready" || meta.FindStatusCondition(current.Status.Conditions, stalledCondition) != nil {
+		t.Errorf("Expected the fixed spec to be reconciled, got %s %v", current.Status.Phase, current.Status.Conditions)
+	}
+}
+
+func TestDependencyMissingWaits(t *testing.T) {
+	webserver := &webserverv1alpha1.Webserver{
+		ObjectMeta: metav1.ObjectMeta{Name: "site", Namespace: "web", Generation: 1},
+		Spec:       webserverv1alpha1.WebserverSpec{ClassName: "premium"},
+	}
+	r := newTestReconciler(t, webserver)
+	key := types.NamespacedName{Name: "site", Namespace: "web"}
+
+	result, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: key})
+	if err != nil || result.RequeueAfter != 0 {
+		t.Fatalf("Expected to wait for the class watch, got %+v, %v", result, err)
+	}
+	current := &webserverv1alpha1.Webserver{}
+	if err := r.Get(context.Background(), key, current); err != nil {
+		t.Fatal(err)
+	}
+	if current.Status.Conditions[0].Reason != "ClassNotFound" || stalled(current) {
+		t.Errorf("Expected ClassNotFound without stalling, got %v", current.Status.Conditions)
+	}
+}
+
+func TestConflictRequeuesAtOnce(t *testing.T) {
+	webserver := &webserverv1alpha1.Webserver{ObjectMeta: metav1.ObjectMeta{Name: "site", Namespace: "web"}}
+	r := newInterceptedReconciler(t, interceptor.Funcs{
+		Create: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+			if _, ok := obj.(*appsv1.Deployment); ok {
+				return apierrors.NewAlreadyExists(schema.GroupResource{Group: "apps", Resource: "deployments"}, obj.GetName())
+			}
+			return c.Create(ctx, obj, opts...)
+		},
+	}, webserver)
+
+	result, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: types.NamespacedName{Name: "site", Namespace: "web"}})
+	if err != nil || result.RequeueAfter != conflictRetry {
+		t.Errorf("Expected a requeue after %s without an error, got %+v, %v", conflictRetry, result, err)
+	}
+}
+
+func TestTransientErrorBacksOff(t *testing.T) {
+	webserver := &webserverv1alpha1.Webserver{ObjectMeta: metav1.ObjectMeta{Name: "site", Namespace: "web"}}
+	r := newInterceptedReconciler(t, interceptor.Funcs{
+		Create: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+			if _, ok := obj.(*appsv1.Deployment); ok {
+				return apierrors.NewServiceUnavailable("etcd is unavailable")
+			}
+			return c.Create(ctx, obj, opts...)
+		},
+	}, webserver)
+
+	_, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: types.NamespacedName{Name: "site", Namespace: "web"}})
+	if !apierrors.IsServiceUnavailable(err) {
+		t.Errorf("Expected the error to be returned for the rate limiter, got %v", err)
+	}
+}
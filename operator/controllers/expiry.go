@@ -0,0 +1,63 @@
+package controllers
+
+import (
+	"fmt"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	webserverv1alpha1 "github.com/webserver/webserver-operator/api/v1alpha1"
+)
+
+// expiresAtAnnotation sets an absolute expiry time in RFC 3339 format, taking precedence over spec.ttl
+const expiresAtAnnotation = "webserver.io/expires-at"
+
+// recordActivity stamps status.lastActivityTime when the spec changed since
+// the last reconcile and the TTL is extended on activity
+func recordActivity(webserver *webserverv1alpha1.Webserver, now time.Time) {
+	if !webserver.Spec.ExtendTTLOnActivity {
+		webserver.Status.LastActivityTime = nil
+		return
+	}
+	if webserver.Status.LastActivityTime == nil || webserver.Generation != webserver.Status.ObservedGeneration {
+		activity := metav1.NewTime(now)
+		webserver.Status.LastActivityTime = &activity
+	}
+}
+
+// expiryFor computes when the Webserver expires; the zero time means never
+func expiryFor(webserver *webserverv1alpha1.Webserver) (time.Time, error) {
+	if value, ok := webserver.Annotations[expiresAtAnnotation]; ok {
+		expiresAt, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("annotation %s must be an RFC 3339 time, got %q", expiresAtAnnotation, value)
+		}
+		return expiresAt, nil
+	}
+
+	if webserver.Spec.TTL == nil {
+		return time.Time{}, nil
+	}
+	if webserver.Spec.TTL.Duration <= 0 {
+		return time.Time{}, fmt.Errorf("spec.ttl must be positive, got %s", webserver.Spec.TTL.Duration)
+	}
+	start := webserver.CreationTimestamp.Time
+	if webserver.Spec.ExtendTTLOnActivity && webserver.Status.LastActivityTime != nil {
+		start = webserver.Status.LastActivityTime.Time
+	}
+	return start.Add(webserver.Spec.TTL.Duration), nil
+}
+
+// requeueBefore shortens the requeue of result so the Webserver is
+// reconciled again when it expires
+func requeueBefore(result ctrl.Result, expiresAt, now time.Time) ctrl.Result {
+	if expiresAt.IsZero() {
+		return result
+	}
+	untilExpiry := max(expiresAt.Sub(now), time.Second)
+	if result.RequeueAfter == 0 || untilExpiry < result.RequeueAfter {
+		result.RequeueAfter = untilExpiry
+	}
+	return result
+}
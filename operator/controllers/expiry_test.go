@@ -0,0 +1,240 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	webserverv1alpha1 "github.com/webserver/webserver-operator/api/v1alpha1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	clocktesting "k8s.io/utils/clock/testing"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+var previewCreated = time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+
+func previewWebserver() *webserverv1alpha1.Webserver {
+	return &webserverv1alpha1.Webserver{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "pr-42",
+			Namespace:         "previews",
+			CreationTimestamp: metav1.NewTime(previewCreated),
+		},
+		Spec: webserverv1alpha1.WebserverSpec{
+			TTL: &metav1.Duration{Duration: 48 * time.Hour},
+		},
+	}
+}
+
+func TestExpiryFor(t *testing.T) {
+	activity := metav1.NewTime(previewCreated.Add(24 * time.Hour))
+
+	tests := []struct {
+		name    string
+		mutate  func(*webserverv1alpha1.Webserver)
+		want    time.Time
+		wantErr bool
+	}{
+		{
+			name:   "no ttl",
+			mutate: func(w *webserverv1alpha1.Webserver) { w.Spec.TTL = nil },
+		},
+		{
+			name:   "ttl from creation",
+			mutate: func(*webserverv1alpha1.Webserver) {},
+			want:   previewCreated.Add(48 * time.Hour),
+		},
+		{
+			name: "ttl from last activity",
+			mutate: func(w *webserverv1alpha1.Webserver) {
+				w.Spec.ExtendTTLOnActivity = true
+				w.Status.LastActivityTime = &activity
+			},
+			want: activity.Add(48 * time.Hour),
+		},
+		{
+			name: "annotation wins",
+			mutate: func(w *webserverv1alpha1.Webserver) {
+				w.Annotations = map[string]string{expiresAtAnnotation: "2026-10-02T08:00:00Z"}
+			},
+			want: time.Date(2026, 10, 2, 8, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "invalid annotation",
+			mutate: func(w *webserverv1alpha1.Webserver) {
+				w.Annotations = map[string]string{expiresAtAnnotation: "tomorrow"}
+			},
+			wantErr: true,
+		},
+		{
+			name:    "negative ttl",
+			mutate:  func(w *webserverv1alpha1.Webserver) { w.Spec.TTL.Duration = -time.Hour },
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			webserver := previewWebserver()
+			tt.mutate(webserver)
+			got, err := expiryFor(webserver)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expected error %v, got %v", tt.wantErr, err)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("Expected expiry %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestReconcileExpiry(t *testing.T) {
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: "pr-42", Namespace: "previews"}}
+	r := newTestReconciler(t, previewWebserver())
+	fakeClock := clocktesting.NewFakePassiveClock(previewCreated.Add(47 * time.Hour))
+	r.Clock = fakeClock
+	r.ResyncPeriod = 2 * time.Hour
+
+	result, err := r.Reconcile(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	if result.RequeueAfter != time.Hour {
+		t.Errorf("Expected requeue at expiry in 1h, got %s", result.RequeueAfter)
+	}
+	webserver := &webserverv1alpha1.Webserver{}
+	if err := r.Get(context.Background(), req.NamespacedName, webserver); err != nil {
+		t.Fatal(err)
+	}
+	if webserver.Status.ExpiresAt == nil || !webserver.Status.ExpiresAt.Time.Equal(previewCreated.Add(48*time.Hour)) {
+		t.Errorf("Expected status.expiresAt at creation plus TTL, got %v", webserver.Status.ExpiresAt)
+	}
+
+	fakeClock.SetTime(previewCreated.Add(48 * time.Hour))
+	if _, err := r.Reconcile(context.Background(), req); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	if err := r.Get(context.Background(), req.NamespacedName, webserver); !errors.IsNotFound(err) {
+		t.Errorf("Expected expired Webserver to be deleted, got %v", err)
+	}
+}
+
+func TestReconcileExpiryOfFailedWebserver(t *testing.T) {
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: "pr-42", Namespace: "previews"}}
+	preview := previewWebserver()
+	preview.Spec.ClassName = "missing"
+	r := newTestReconciler(t, preview)
+	fakeClock := clocktesting.NewFakePassiveClock(previewCreated.Add(47 * time.Hour))
+	r.Clock = fakeClock
+
+	result, err := r.Reconcile(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	if result.RequeueAfter != time.Hour {
+		t.Errorf("Expected a Webserver waiting for its class to be requeued at expiry in 1h, got %s", result.RequeueAfter)
+	}
+
+	fakeClock.SetTime(previewCreated.Add(48 * time.Hour))
+	if _, err := r.Reconcile(context.Background(), req); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	if err := r.Get(context.Background(), req.NamespacedName, &webserverv1alpha1.Webserver{}); !errors.IsNotFound(err) {
+		t.Errorf("Expected the expired Webserver to be deleted, got %v", err)
+	}
+}
+
+func TestReconcileExpiryOfSuspendedWebserver(t *testing.T) {
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: "pr-42", Namespace: "previews"}}
+	preview := previewWebserver()
+	preview.Annotations = map[string]string{webserverv1alpha1.SuspendAnnotation: "true"}
+	r := newTestReconciler(t, preview)
+	fakeClock := clocktesting.NewFakePassiveClock(previewCreated.Add(47 * time.Hour))
+	r.Clock = fakeClock
+
+	result, err := r.Reconcile(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	if result.RequeueAfter != time.Hour {
+		t.Errorf("Expected a suspended Webserver to be requeued at expiry in 1h, got %s", result.RequeueAfter)
+	}
+	webserver := &webserverv1alpha1.Webserver{}
+	if err := r.Get(context.Background(), req.NamespacedName, webserver); err != nil {
+		t.Fatal(err)
+	}
+	if webserver.Status.Phase != "Suspended" || webserver.Status.ExpiresAt == nil {
+		t.Errorf("Expected a suspended Webserver reporting its expiry, got %s %v", webserver.Status.Phase, webserver.Status.ExpiresAt)
+	}
+
+	fakeClock.SetTime(previewCreated.Add(48 * time.Hour))
+	if _, err := r.Reconcile(context.Background(), req); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	if err := r.Get(context.Background(), req.NamespacedName, webserver); !errors.IsNotFound(err) {
+		t.Errorf("Expected the expired suspended Webserver to be deleted, got %v", err)
+	}
+}
+
+func TestReconcileExpiryRespectsFinalizers(t *testing.T) {
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: "pr-42", Namespace: "previews"}}
+	preview := previewWebserver()
+	preview.Finalizers = []string{"example.com/archive"}
+	r := newTestReconciler(t, preview)
+	r.Clock = clocktesting.NewFakePassiveClock(previewCreated.Add(72 * time.Hour))
+
+	for i := 0; i < 2; i++ {
+		if _, err := r.Reconcile(context.Background(), req); err != nil {
+			t.Fatalf("Reconcile() error = %v", err)
+		}
+	}
+
+	webserver := &webserverv1alpha1.Webserver{}
+	if err := r.Get(context.Background(), req.NamespacedName, webserver); err != nil {
+		t.Fatalf("Expected Webserver to wait for its finalizer, got %v", err)
+	}
+	if webserver.DeletionTimestamp.IsZero() {
+		t.Error("Expected Webserver to be marked for deletion")
+	}
+	if len(webserver.Finalizers) != 1 {
+		t.Errorf("Expected finalizers to be left alone, got %v", webserver.Finalizers)
+	}
+}
+
+func TestReconcileExtendsExpiryOnActivity(t *testing.T) {
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: "pr-42", Namespace: "previews"}}
+	preview := previewWebserver()
+	preview.Spec.ExtendTTLOnActivity = true
+	r := newTestReconciler(t, preview)
+	fakeClock := clocktesting.NewFakePassiveClock(previewCreated)
+	r.Clock = fakeClock
+
+	if _, err := r.Reconcile(context.Background(), req); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+
+	// A spec change a day later pushes the expiry back
+	fakeClock.SetTime(previewCreated.Add(24 * time.Hour))
+	webserver := &webserverv1alpha1.Webserver{}
+	if err := r.Get(context.Background(), req.NamespacedName, webserver); err != nil {
+		t.Fatal(err)
+	}
+	webserver.Spec.Config.Message = "New commit"
+	webserver.Generation++
+	if err := r.Update(context.Background(), webserver); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Reconcile(context.Background(), req); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+
+	if err := r.Get(context.Background(), req.NamespacedName, webserver); err != nil {
+		t.Fatal(err)
+	}
+	want := previewCreated.Add(72 * time.Hour)
+	if webserver.Status.ExpiresAt == nil || !webserver.Status.ExpiresAt.Time.Equal(want) {
+		t.Errorf("Expected expiry extended to %s, got %v", want, webserver.Status.ExpiresAt)
+	}
+}
@@ -0,0 +1,73 @@
+package controllers
+
+import "strings"
+
+// normalizeImage expands a short image reference to its fully qualified
+// form, e.g. "nginx:1.25" becomes "docker.io/library/nginx:1.25"
+func normalizeImage(image string) string {
+	name := image
+	domain := "docker.io"
+	if i := strings.IndexRune(image, '/'); i >= 0 {
+		first := image[:i]
+		if strings.ContainsAny(first, ".:") || first == "localhost" {
+			return image
+		}
+	} else {
+		name = "library/" + image
+	}
+	return domain + "/" + name
+}
+
+// imageAllowed reports whether image comes from one of the registry prefixes.
+// An empty prefix list allows every image.
+func imageAllowed(image string, prefixes []string) bool {
+	if len(prefixes) == 0 {
+		return true
+	}
+	normalized := normalizeImage(image)
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(normalized, prefix) || strings.HasPrefix(image, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// imageReference is a parsed, fully qualified image reference
+type imageReference struct {
+	Registry   string
+	Repository string
+	Tag        string
+	Digest     string
+}
+
+// parseImageReference splits an image into registry, repository, tag and
+// digest after normalizing it
+func parseImageReference(image string) imageReference {
+	name := normalizeImage(image)
+	ref := imageReference{}
+	if i := strings.IndexRune(name, '@'); i >= 0 {
+		ref.Digest = name[i+1:]
+		name = name[:i]
+	}
+	i := strings.IndexRune(name, '/')
+	ref.Registry = name[:i]
+	name = name[i+1:]
+	if i := strings.LastIndex(name, ":"); i >= 0 {
+		ref.Tag = name[i+1:]
+		name = name[:i]
+	}
+	ref.Repository = name
+	return ref
+}
+
+// Name returns the reference without tag or digest
+func (ref imageReference) Name() string {
+	return ref.Registry + "/" + ref.Repository
+}
+
+// isLatest reports whether the reference floats on the latest tag, either
+// explicitly or by omitting both tag and digest
+func (ref imageReference) isLatest() bool {
+	return ref.Tag == "latest" || (ref.Tag == "" && ref.Digest == "")
+}
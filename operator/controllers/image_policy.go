@@ -0,0 +1,238 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	webserverv1alpha1 "github.com/webserver/webserver-operator/api/v1alpha1"
+)
+
+// ImagePolicy restricts which images Webservers may run. The operator-level
+// policy is combined with the policy of the Webserver's class.
+type ImagePolicy struct {
+	// AllowedRegistries lists the registry prefixes images may be pulled from
+	AllowedRegistries []string
+
+	// ForbidLatest rejects images using the latest tag or no tag at all
+	ForbidLatest bool
+
+	// ResolveDigests pins image tags to the digest they point at
+	ResolveDigests bool
+}
+
+// DigestResolver looks up the immutable digest an image tag points at
+type DigestResolver interface {
+	Resolve(ctx context.Context, image string) (string, error)
+}
+
+// manifestMediaTypes are the manifest formats accepted from registries
+var manifestMediaTypes = []string{
+	"application/vnd.oci.image.index.v1+json",
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+	"application/vnd.oci.image.manifest.v1+json",
+	"application/vnd.docker.distribution.manifest.v2+json",
+}
+
+// RegistryDigestResolver resolves digests through the OCI distribution API.
+// Only anonymous pulls are supported, including the bearer token flow used
+// by Docker Hub and most public registries.
+type RegistryDigestResolver struct {
+	// Client is the HTTP client used for requests; http.DefaultClient is used when nil
+	Client *http.Client
+
+	// InsecureRegistries are contacted over plain HTTP
+	InsecureRegistries []string
+}
+
+// Resolve returns the digest of image, querying the registry unless the
+// reference already carries a digest
+func (r *RegistryDigestResolver) Resolve(ctx context.Context, image string) (string, error) {
+	ref := parseImageReference(image)
+	if ref.Digest != "" {
+		return ref.Digest, nil
+	}
+	tag := ref.Tag
+	if tag == "" {
+		tag = "latest"
+	}
+
+	scheme := "https"
+	for _, registry := range r.InsecureRegistries {
+		if registry == ref.Registry {
+			scheme = "http"
+		}
+	}
+	host := ref.Registry
+	if host == "docker.io" {
+		host = "registry-1.docker.io"
+	}
+	manifestURL := fmt.Sprintf("%s://%s/v2/%s/manifests/%s", scheme, host, ref.Repository, tag)
+
+	resp, err := r.headManifest(ctx, manifestURL, "")
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		token, err := r.fetchToken(ctx, resp.Header.Get("WWW-Authenticate"))
+		if err != nil {
+			return "", err
+		}
+		if resp, err = r.headManifest(ctx, manifestURL, token); err != nil {
+			return "", err
+		}
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("registry returned status %d for %s", resp.StatusCode, image)
+	}
+
+	digest := resp.Header.Get("Docker-Content-Digest")
+	if digest == "" {
+		return "", fmt.Errorf("registry did not return a digest for %s", image)
+	}
+	return digest, nil
+}
+
+func (r *RegistryDigestResolver) httpClient() *http.Client {
+	if r.Client != nil {
+		return r.Client
+	}
+	return http.DefaultClient
+}
+
+// headManifest issues a HEAD request for a manifest, optionally authenticated
+func (r *RegistryDigestResolver) headManifest(ctx context.Context, manifestURL, token string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, manifestURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", strings.Join(manifestMediaTypes, ", "))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := r.httpClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	return resp, nil
+}
+
+// challengeParam matches the key="value" pairs of a WWW-Authenticate header
+var challengeParam = regexp.MustCompile(`(\w+)="([^"]*)"`)
+
+// fetchToken obtains an anonymous pull token for a Bearer challenge
+func (r *RegistryDigestResolver) fetchToken(ctx context.Context, challenge string) (string, error) {
+	if !strings.HasPrefix(challenge, "Bearer ") {
+		return "", fmt.Errorf("unsupported registry authentication challenge %q", challenge)
+	}
+
+	params := map[string]string{}
+	for _, match := range challengeParam.FindAllStringSubmatch(challenge, -1) {
+		params[match[1]] = match[2]
+	}
+	realm, err := url.Parse(params["realm"])
+	if err != nil || params["realm"] == "" {
+		return "", fmt.Errorf("invalid token realm in challenge %q", challenge)
+	}
+	query := realm.Query()
+	for _, key := range []string{"service", "scope"} {
+		if params[key] != "" {
+			query.Set(key, params[key])
+		}
+	}
+	realm.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, realm.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := r.httpClient().Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token endpoint returned status %d", resp.StatusCode)
+	}
+
+	var body struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", err
+	}
+	if body.Token != "" {
+		return body.Token, nil
+	}
+	return body.AccessToken, nil
+}
+
+// imagePolicyFor combines the operator policy with the class policy
+func (r *WebserverReconciler) imagePolicyFor(class *webserverv1alpha1.WebserverClass) ImagePolicy {
+	policy := r.ImagePolicy
+	if class != nil {
+		policy.ForbidLatest = policy.ForbidLatest || class.Spec.ForbidLatestTag
+		policy.ResolveDigests = policy.ResolveDigests || class.Spec.ResolveDigests
+	}
+	return policy
+}
+
+// resolveImage enforces the image policy and records the image to deploy in
+// status. Policy violations are returned separately from resolution errors.
+// A digest already recorded for the same spec.image is reused so that the
+// deployed image only changes when the spec does.
+func (r *WebserverReconciler) resolveImage(ctx context.Context, webserver *webserverv1alpha1.Webserver, class *webserverv1alpha1.WebserverClass) ([]string, error) {
+	policy := r.imagePolicyFor(class)
+	image := webserver.Spec.Image
+	ref := parseImageReference(image)
+
+	var violations []string
+	if !imageAllowed(image, policy.AllowedRegistries) {
+		violations = append(violations, fmt.Sprintf("image %q is not from an allowed registry (%s)",
+			image, strings.Join(policy.AllowedRegistries, ", ")))
+	}
+	if policy.ForbidLatest && ref.isLatest() {
+		violations = append(violations, fmt.Sprintf("image %q uses the latest tag", image))
+	}
+	if len(violations) > 0 {
+		return violations, nil
+	}
+
+	if !policy.ResolveDigests {
+		webserver.Status.Image = &webserverv1alpha1.ImageStatus{Requested: image, Digest: ref.Digest, Deployed: image}
+		return nil, nil
+	}
+
+	if current := webserver.Status.Image; current != nil && current.Requested == image && current.Digest != "" {
+		return nil, nil
+	}
+	if r.Resolver == nil {
+		return nil, fmt.Errorf("digest resolution requested but no resolver is configured")
+	}
+
+	digest, err := r.Resolver.Resolve(ctx, image)
+	if err != nil {
+		return nil, fmt.Errorf("resolving digest of %q: %w", image, err)
+	}
+	webserver.Status.Image = &webserverv1alpha1.ImageStatus{
+		Requested: image,
+		Digest:    digest,
+		Deployed:  ref.Name() + "@" + digest,
+	}
+	return nil, nil
+}
+
+// deployedImage returns the image reference to put in the Deployment
+func deployedImage(webserver *webserverv1alpha1.Webserver) string {
+	if current := webserver.Status.Image; current != nil && current.Requested == webserver.Spec.Image && current.Deployed != "" {
+		return current.Deployed
+	}
+	return webserver.Spec.Image
+}
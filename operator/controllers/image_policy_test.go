@@ -0,0 +1,126 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	webserverv1alpha1 "github.com/webserver/webserver-operator/api/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+const testDigest = "sha256:4c0fdaa8b6341bfdeca5f18f7837462c80cff90527ee35ef185571e1c327beac"
+
+func TestRegistryDigestResolver(t *testing.T) {
+	var registry *httptest.Server
+	registry = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/token":
+			if r.URL.Query().Get("scope") != "repository:acme/site:pull" {
+				t.Errorf("Unexpected token scope %q", r.URL.Query().Get("scope"))
+			}
+			_, _ = w.Write([]byte(`{"token":"anonymous"}`))
+		case r.URL.Path == "/v2/acme/site/manifests/v1" && r.Method == http.MethodHead:
+			if r.Header.Get("Authorization") != "Bearer anonymous" {
+				w.Header().Set("WWW-Authenticate",
+					`Bearer realm="`+registry.URL+`/token",service="registry.test",scope="repository:acme/site:pull"`)
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Header().Set("Docker-Content-Digest", testDigest)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer registry.Close()
+
+	host := strings.TrimPrefix(registry.URL, "http://")
+	resolver := &RegistryDigestResolver{Client: registry.Client(), InsecureRegistries: []string{host}}
+
+	digest, err := resolver.Resolve(context.Background(), host+"/acme/site:v1")
+	if err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+	if digest != testDigest {
+		t.Errorf("Expected digest %s, got %s", testDigest, digest)
+	}
+
+	if _, err := resolver.Resolve(context.Background(), host+"/acme/missing:v1"); err == nil {
+		t.Error("Expected an error for a missing manifest")
+	}
+}
+
+type staticResolver struct {
+	digest string
+	calls  int
+}
+
+func (s *staticResolver) Resolve(context.Context, string) (string, error) {
+	s.calls++
+	return s.digest, nil
+}
+
+func TestReconcilePinsImageDigest(t *testing.T) {
+	webserver := &webserverv1alpha1.Webserver{
+		ObjectMeta: metav1.ObjectMeta{Name: "site", Namespace: "web"},
+		Spec:       webserverv1alpha1.WebserverSpec{Image: "nginx:1.25"},
+	}
+	resolver := &staticResolver{digest: testDigest}
+	r := newTestReconciler(t, webserver)
+	r.ImagePolicy = ImagePolicy{ForbidLatest: true, ResolveDigests: true}
+	r.Resolver = resolver
+
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: "site", Namespace: "web"}}
+	for i := 0; i < 2; i++ {
+		if _, err := r.Reconcile(context.Background(), req); err != nil {
+			t.Fatalf("Reconcile() error = %v", err)
+		}
+	}
+	if resolver.calls != 1 {
+		t.Errorf("Expected the digest to be resolved once, got %d calls", resolver.calls)
+	}
+
+	deployment := &appsv1.Deployment{}
+	if err := r.Get(context.Background(), types.NamespacedName{Name: "site-deployment", Namespace: "web"}, deployment); err != nil {
+		t.Fatal(err)
+	}
+	want := "docker.io/library/nginx@" + testDigest
+	if got := deployment.Spec.Template.Spec.Containers[0].Image; got != want {
+		t.Errorf("Expected deployed image %s, got %s", want, got)
+	}
+
+	updated := &webserverv1alpha1.Webserver{}
+	if err := r.Get(context.Background(), req.NamespacedName, updated); err != nil {
+		t.Fatal(err)
+	}
+	if updated.Status.Image == nil || updated.Status.Image.Digest != testDigest {
+		t.Errorf("Expected digest in status, got %+v", updated.Status.Image)
+	}
+}
+
+func TestReconcileForbidsLatestTag(t *testing.T) {
+	webserver := &webserverv1alpha1.Webserver{
+		ObjectMeta: metav1.ObjectMeta{Name: "site", Namespace: "web"},
+		Spec:       webserverv1alpha1.WebserverSpec{Image: "nginx"},
+	}
+	r := newTestReconciler(t, webserver)
+	r.ImagePolicy = ImagePolicy{ForbidLatest: true}
+
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: "site", Namespace: "web"}}
+	if _, err := r.Reconcile(context.Background(), req); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+
+	updated := &webserverv1alpha1.Webserver{}
+	if err := r.Get(context.Background(), req.NamespacedName, updated); err != nil {
+		t.Fatal(err)
+	}
+	if updated.Status.Phase != "Failed" {
+		t.Errorf("Expected phase Failed, got %q", updated.Status.Phase)
+	}
+}
@@ -0,0 +1,119 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+
+	webserverv1alpha1 "github.com/webserver/webserver-operator/api/v1alpha1"
+)
+
+// forcedReconcileBuffer is how many forced reconciles may wait for the controller
+const forcedReconcileBuffer = 64
+
+// ErrForcedReconcileBusy is returned when too many forced reconciles are pending
+var ErrForcedReconcileBusy = errors.New("too many forced reconciles are pending")
+
+// ReconcileRecord describes the last reconcile of a Webserver
+type ReconcileRecord struct {
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+
+	// Time is when the reconcile started
+	Time metav1.Time `json:"time"`
+
+	// Duration is how long the reconcile took
+	Duration metav1.Duration `json:"duration"`
+
+	// Result is the phase the Webserver was left in, or Error
+	Result string `json:"result"`
+
+	// RequeueAfter is when the Webserver is reconciled again without a change
+	RequeueAfter *metav1.Duration `json:"requeueAfter,omitempty"`
+
+	// Error is the error the reconcile returned
+	Error string `json:"error,omitempty"`
+}
+
+// ReconcileHistory keeps the last reconcile of every Webserver. A nil
+// history records nothing.
+type ReconcileHistory struct {
+	mu      sync.Mutex
+	records map[types.NamespacedName]ReconcileRecord
+}
+
+// NewReconcileHistory returns an empty history
+func NewReconcileHistory() *ReconcileHistory {
+	return &ReconcileHistory{records: map[types.NamespacedName]ReconcileRecord{}}
+}
+
+// Records returns the last reconcile of every Webserver sorted by namespace and name
+func (h *ReconcileHistory) Records() []ReconcileRecord {
+	if h == nil {
+		return nil
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	records := make([]ReconcileRecord, 0, len(h.records))
+	for _, record := range h.records {
+		records = append(records, record)
+	}
+	sort.Slice(records, func(i, j int) bool {
+		if records[i].Namespace != records[j].Namespace {
+			return records[i].Namespace < records[j].Namespace
+		}
+		return records[i].Name < records[j].Name
+	})
+	return records
+}
+
+// record stores a reconcile, forgetting Webservers that no longer exist
+func (h *ReconcileHistory) record(key types.NamespacedName, start time.Time, duration time.Duration, outcome string, result ctrl.Result, err error) {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if outcome == "NotFound" {
+		delete(h.records, key)
+		return
+	}
+	record := ReconcileRecord{
+		Namespace: key.Namespace,
+		Name:      key.Name,
+		Time:      metav1.NewTime(start),
+		Duration:  metav1.Duration{Duration: duration},
+		Result:    outcome,
+	}
+	if result.RequeueAfter > 0 {
+		record.RequeueAfter = &metav1.Duration{Duration: result.RequeueAfter}
+	}
+	if err != nil {
+		record.Error = err.Error()
+	}
+	h.records[key] = record
+}
+
+// ForceReconcile queues a reconcile of the Webserver although nothing changed
+func (r *WebserverReconciler) ForceReconcile(ctx context.Context, key types.NamespacedName) error {
+	if r.forced == nil {
+		return errors.New("the Webserver controller is not running")
+	}
+	webserver := &webserverv1alpha1.Webserver{}
+	if err := r.Get(ctx, key, webserver); err != nil {
+		return err
+	}
+	select {
+	case r.forced <- event.GenericEvent{Object: webserver}:
+		return nil
+	default:
+		return ErrForcedReconcileBusy
+	}
+}
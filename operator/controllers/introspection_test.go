@@ -0,0 +1,64 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	webserverv1alpha1 "github.com/webserver/webserver-operator/api/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+)
+
+func TestReconcileHistory(t *testing.T) {
+	webserver := &webserverv1alpha1.Webserver{ObjectMeta: metav1.ObjectMeta{Name: "site", Namespace: "web"}}
+	r := newTestReconciler(t, webserver)
+	r.History = NewReconcileHistory()
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: "site", Namespace: "web"}}
+
+	if _, err := r.Reconcile(context.Background(), req); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	records := r.History.Records()
+	if len(records) != 1 || records[0].Name != "site" || records[0].Result != "Ready" || records[0].Error != "" {
+		t.Fatalf("Expected a successful reconcile of site, got %+v", records)
+	}
+	if records[0].Time.IsZero() || records[0].RequeueAfter == nil {
+		t.Errorf("Expected the start time and the resync to be recorded, got %+v", records[0])
+	}
+
+	if err := r.Delete(context.Background(), webserver); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Reconcile(context.Background(), req); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	if records := r.History.Records(); len(records) != 0 {
+		t.Errorf("Expected the deleted Webserver to be forgotten, got %+v", records)
+	}
+}
+
+func TestForceReconcile(t *testing.T) {
+	r := newTestReconciler(t, &webserverv1alpha1.Webserver{ObjectMeta: metav1.ObjectMeta{Name: "site", Namespace: "web"}})
+	key := types.NamespacedName{Name: "site", Namespace: "web"}
+	if err := r.ForceReconcile(context.Background(), key); err == nil {
+		t.Error("Expected an error before the controller is set up")
+	}
+
+	r.forced = make(chan event.GenericEvent, 1)
+	if err := r.ForceReconcile(context.Background(), key); err != nil {
+		t.Fatalf("ForceReconcile() error = %v", err)
+	}
+	if queued := <-r.forced; queued.Object.GetName() != "site" {
+		t.Errorf("Expected site to be queued, got %s", queued.Object.GetName())
+	}
+	if err := r.ForceReconcile(context.Background(), types.NamespacedName{Name: "missing", Namespace: "web"}); err == nil {
+		t.Error("Expected an error for a missing Webserver")
+	}
+
+	r.forced <- event.GenericEvent{}
+	if err := r.ForceReconcile(context.Background(), key); err != ErrForcedReconcileBusy {
+		t.Errorf("Expected ErrForcedReconcileBusy with a full queue, got %v", err)
+	}
+}
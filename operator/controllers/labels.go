@@ -0,0 +1,113 @@
+package controllers
+
+import (
+	"sort"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	webserverv1alpha1 "github.com/webserver/webserver-operator/api/v1alpha1"
+)
+
+// Recommended labels set on every child of a Webserver
+const (
+	nameLabel      = "app.kubernetes.io/name"
+	instanceLabel  = "app.kubernetes.io/instance"
+	managedByLabel = "app.kubernetes.io/managed-by"
+)
+
+// commonAnnotationsAnnotation lists the annotations set from spec.commonAnnotations
+const commonAnnotationsAnnotation = "webserver.io/common-annotations"
+
+// selectorLabels select the pods of a Webserver. They are the labels used
+// before the recommended labels were added and must not change, since the
+// selector of a Deployment is immutable.
+func selectorLabels(webserver *webserverv1alpha1.Webserver) map[string]string {
+	return map[string]string{
+		"app":      "webserver",
+		"instance": webserver.Name,
+	}
+}
+
+// childLabels returns the labels of the objects created for the Webserver:
+// its own labels matching an inherited prefix, spec.commonLabels, and the
+// operator labels, which take precedence
+func (r *WebserverReconciler) childLabels(webserver *webserverv1alpha1.Webserver) map[string]string {
+	labels := map[string]string{}
+	for key, value := range webserver.Labels {
+		if r.inheritsLabel(key) {
+			labels[key] = value
+		}
+	}
+	for key, value := range webserver.Spec.CommonLabels {
+		labels[key] = value
+	}
+	for key, value := range selectorLabels(webserver) {
+		labels[key] = value
+	}
+	labels["managed-by"] = "webserver-operator"
+	labels[nameLabel] = "webserver"
+	labels[instanceLabel] = webserver.Name
+	labels[managedByLabel] = "webserver-operator"
+	return labels
+}
+
+// inheritsLabel reports whether a Webserver label is copied to its children
+func (r *WebserverReconciler) inheritsLabel(key string) bool {
+	for _, prefix := range r.InheritedLabelPrefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// podAnnotations returns the annotations of the pod template:
+// spec.commonAnnotations overridden by spec.podAnnotations
+func podAnnotations(webserver *webserverv1alpha1.Webserver) map[string]string {
+	if len(webserver.Spec.CommonAnnotations) == 0 && len(webserver.Spec.PodAnnotations) == 0 {
+		return nil
+	}
+	annotations := map[string]string{}
+	for key, value := range webserver.Spec.CommonAnnotations {
+		annotations[key] = value
+	}
+	for key, value := range webserver.Spec.PodAnnotations {
+		annotations[key] = value
+	}
+	return annotations
+}
+
+// setChildMetadata sets the labels of a child and applies
+// spec.commonAnnotations to it
+func (r *WebserverReconciler) setChildMetadata(obj metav1.Object, webserver *webserverv1alpha1.Webserver) {
+	obj.SetLabels(r.childLabels(webserver))
+	setTrackedAnnotations(obj, commonAnnotationsAnnotation, webserver.Spec.CommonAnnotations)
+}
+
+// setTrackedAnnotations applies the desired annotations and removes the ones
+// an earlier call set, which are listed in the tracking annotation, leaving
+// annotations of other controllers alone
+func setTrackedAnnotations(obj metav1.Object, tracking string, desired map[string]string) {
+	annotations := obj.GetAnnotations()
+	for _, key := range strings.Split(annotations[tracking], ",") {
+		if _, ok := desired[key]; !ok {
+			delete(annotations, key)
+		}
+	}
+	delete(annotations, tracking)
+
+	if len(desired) > 0 {
+		if annotations == nil {
+			annotations = map[string]string{}
+		}
+		keys := make([]string, 0, len(desired))
+		for key, value := range desired {
+			annotations[key] = value
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		annotations[tracking] = strings.Join(keys, ",")
+	}
+	obj.SetAnnotations(annotations)
+}
@@ -0,0 +1,107 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	webserverv1alpha1 "github.com/webserver/webserver-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestChildMetadataPropagation(t *testing.T) {
+	webserver := &webserverv1alpha1.Webserver{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "site", Namespace: "web",
+			Labels: map[string]string{"example.com/team": "web", "environment": "prod", "tier": "frontend"},
+		},
+		Spec: webserverv1alpha1.WebserverSpec{
+			CommonLabels:      map[string]string{"cost-center": "1234", "app": "shop"},
+			CommonAnnotations: map[string]string{"example.com/owner": "web-team"},
+			PodAnnotations:    map[string]string{"example.com/owner": "pods", "prometheus.io/scrape": "true"},
+			Service:           &webserverv1alpha1.ServiceSpec{Annotations: map[string]string{"example.com/lb": "internal"}},
+		},
+	}
+	r := newTestReconciler(t, webserver)
+	r.InheritedLabelPrefixes = []string{"example.com/", "environment"}
+
+	deployment := reconcileDeployment(t, r, "site")
+
+	want := map[string]string{
+		"app":                          "webserver",
+		"instance":                     "site",
+		"managed-by":                   "webserver-operator",
+		"app.kubernetes.io/name":       "webserver",
+		"app.kubernetes.io/instance":   "site",
+		"app.kubernetes.io/managed-by": "webserver-operator",
+		"example.com/team":             "web",
+		"environment":                  "prod",
+		"cost-center":                  "1234",
+	}
+	if !equality.Semantic.DeepEqual(deployment.Labels, want) {
+		t.Errorf("Expected Deployment labels %v, got %v", want, deployment.Labels)
+	}
+	if !equality.Semantic.DeepEqual(deployment.Spec.Template.Labels, want) {
+		t.Errorf("Expected pod labels %v, got %v", want, deployment.Spec.Template.Labels)
+	}
+	if !equality.Semantic.DeepEqual(deployment.Spec.Selector.MatchLabels, map[string]string{"app": "webserver", "instance": "site"}) {
+		t.Errorf("Expected the selector to stay on app and instance, got %v", deployment.Spec.Selector.MatchLabels)
+	}
+	if deployment.Annotations["example.com/owner"] != "web-team" {
+		t.Errorf("Expected the common annotation on the Deployment, got %v", deployment.Annotations)
+	}
+	pod := deployment.Spec.Template.Annotations
+	if pod["example.com/owner"] != "pods" || pod["prometheus.io/scrape"] != "true" {
+		t.Errorf("Expected pod annotations to override common annotations, got %v", pod)
+	}
+
+	service := getService(t, r)
+	if service.Labels["cost-center"] != "1234" {
+		t.Errorf("Expected the common label on the Service, got %v", service.Labels)
+	}
+	if service.Annotations["example.com/owner"] != "web-team" || service.Annotations["example.com/lb"] != "internal" {
+		t.Errorf("Expected common and Service annotations on the Service, got %v", service.Annotations)
+	}
+}
+
+func TestCommonAnnotationsRemoved(t *testing.T) {
+	webserver := &webserverv1alpha1.Webserver{
+		ObjectMeta: metav1.ObjectMeta{Name: "site", Namespace: "web"},
+		Spec: webserverv1alpha1.WebserverSpec{
+			CommonAnnotations: map[string]string{"example.com/owner": "web-team"},
+		},
+	}
+	r := newTestReconciler(t, webserver)
+	reconcileDeployment(t, r, "site")
+
+	// Another controller annotates the ConfigMap
+	configmap := &corev1.ConfigMap{}
+	if err := r.Get(context.Background(), types.NamespacedName{Name: "site-config", Namespace: "web"}, configmap); err != nil {
+		t.Fatal(err)
+	}
+	configmap.Annotations["example.com/foreign"] = "y"
+	if err := r.Update(context.Background(), configmap); err != nil {
+		t.Fatal(err)
+	}
+	current := &webserverv1alpha1.Webserver{}
+	if err := r.Get(context.Background(), types.NamespacedName{Name: "site", Namespace: "web"}, current); err != nil {
+		t.Fatal(err)
+	}
+	current.Spec.CommonAnnotations = nil
+	if err := r.Update(context.Background(), current); err != nil {
+		t.Fatal(err)
+	}
+	reconcileDeployment(t, r, "site")
+
+	if err := r.Get(context.Background(), types.NamespacedName{Name: "site-config", Namespace: "web"}, configmap); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := configmap.Annotations["example.com/owner"]; ok {
+		t.Errorf("Expected the dropped common annotation to be removed, got %v", configmap.Annotations)
+	}
+	if configmap.Annotations["example.com/foreign"] != "y" || configmap.Annotations[contentMarkerAnnotation] == "" {
+		t.Errorf("Expected the foreign and the content marker annotation to be kept, got %v", configmap.Annotations)
+	}
+}
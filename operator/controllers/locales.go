@@ -0,0 +1,190 @@
+package controllers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"html/template"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+
+	webserverv1alpha1 "github.com/webserver/webserver-operator/api/v1alpha1"
+)
+
+// defaultLocale is the locale of the page when the Webserver sets none
+const defaultLocale = "en"
+
+// localeTemplateData is what a locale template is rendered with
+type localeTemplateData struct {
+	Locale    string
+	Title     string
+	Message   string
+	Color     string
+	Name      string
+	Namespace string
+}
+
+// localeCodes returns the locales served besides the default one, longest
+// first so that pt-BR is matched before pt
+func localeCodes(webserver *webserverv1alpha1.Webserver) []string {
+	var codes []string
+	for code := range webserver.Spec.Config.Locales {
+		if code != webserver.Spec.Config.DefaultLocale {
+			codes = append(codes, code)
+		}
+	}
+	sortLocales(codes)
+	return codes
+}
+
+// sortLocales orders locale codes longest first, then by name
+func sortLocales(codes []string) {
+	sort.Slice(codes, func(i, j int) bool {
+		if len(codes[i]) != len(codes[j]) {
+			return len(codes[i]) > len(codes[j])
+		}
+		return codes[i] < codes[j]
+	})
+}
+
+// localeKey is the ConfigMap key of the page of a locale
+func localeKey(code string) string {
+	return "index." + code + ".html"
+}
+
+// localeViolations lists the locale templates that cannot be rendered
+func localeViolations(webserver *webserverv1alpha1.Webserver) []string {
+	codes := make([]string, 0, len(webserver.Spec.Config.Locales))
+	for code := range webserver.Spec.Config.Locales {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+
+	var violations []string
+	for _, code := range codes {
+		if _, err := localePage(webserver, "", code); err != nil {
+			violations = append(violations, fmt.Sprintf("locale %q: %v", code, err))
+		}
+	}
+	return violations
+}
+
+// localePage renders the page of a locale, falling back to the default page
+// for the title and message the locale leaves empty
+func localePage(webserver *webserverv1alpha1.Webserver, marker, code string) (string, error) {
+	config := webserver.Spec.Config
+	override := config.Locales[code]
+	title, message := config.Title, config.Message
+	if override.Title != "" {
+		title = override.Title
+	}
+	if override.Message != "" {
+		message = override.Message
+	}
+	if override.Template == "" {
+		return generatedPage(webserver, marker, code, title, message), nil
+	}
+
+	tmpl, err := template.New(code).Parse(override.Template)
+	if err != nil {
+		return "", err
+	}
+	var page bytes.Buffer
+	if err := tmpl.Execute(&page, localeTemplateData{
+		Locale:    code,
+		Title:     title,
+		Message:   message,
+		Color:     config.Color,
+		Name:      webserver.Name,
+		Namespace: webserver.Namespace,
+	}); err != nil {
+		return "", err
+	}
+
+	// The content verifier looks for the marker, so templates carry it too
+	meta := fmt.Sprintf(`<meta name="%s" content="%s">`, contentMarkerMeta, marker)
+	if before, after, ok := strings.Cut(page.String(), "<head>"); ok {
+		return before + "<head>\n" + meta + after, nil
+	}
+	return meta + "\n" + page.String(), nil
+}
+
+// localePages renders the ConfigMap entries of every page: index.html in the
+// default locale and one entry per other locale
+func localePages(webserver *webserverv1alpha1.Webserver, marker string) (map[string]string, error) {
+	index, err := localePage(webserver, marker, webserver.Spec.Config.DefaultLocale)
+	if err != nil {
+		return nil, err
+	}
+	pages := map[string]string{"index.html": index}
+	for _, code := range localeCodes(webserver) {
+		page, err := localePage(webserver, marker, code)
+		if err != nil {
+			return nil, err
+		}
+		pages[localeKey(code)] = page
+	}
+	return pages, nil
+}
+
+// localeItems mounts the page of every locale, including the default one,
+// at /<locale>/index.html
+func localeItems(webserver *webserverv1alpha1.Webserver) []corev1.KeyToPath {
+	if len(webserver.Spec.Config.Locales) == 0 {
+		return nil
+	}
+	items := []corev1.KeyToPath{{Key: "index.html", Path: webserver.Spec.Config.DefaultLocale + "/index.html"}}
+	for _, code := range localeCodes(webserver) {
+		items = append(items, corev1.KeyToPath{Key: localeKey(code), Path: code + "/index.html"})
+	}
+	return items
+}
+
+// localeDigest summarizes the locales for the content marker; it is empty
+// when the Webserver has none, so the marker of other Webservers is unchanged
+func localeDigest(webserver *webserverv1alpha1.Webserver) string {
+	if len(webserver.Spec.Config.Locales) == 0 {
+		return ""
+	}
+	codes := make([]string, 0, len(webserver.Spec.Config.Locales))
+	for code := range webserver.Spec.Config.Locales {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	sum := sha256.New()
+	fmt.Fprintf(sum, "%s", webserver.Spec.Config.DefaultLocale)
+	for _, code := range codes {
+		locale := webserver.Spec.Config.Locales[code]
+		fmt.Fprintf(sum, "|%s|%q|%q|%q", code, locale.Title, locale.Message, locale.Template)
+	}
+	return hex.EncodeToString(sum.Sum(nil))
+}
+
+// nginxLocaleMap picks the page for / from the Accept-Language header. The
+// first language of the header wins when a locale matches it, otherwise any
+// language listed; a region such as de-AT falls back to de.
+func nginxLocaleMap(webserver *webserverv1alpha1.Webserver) string {
+	codes := append(localeCodes(webserver), webserver.Spec.Config.DefaultLocale)
+	sortLocales(codes)
+	page := func(code string) string {
+		if code == webserver.Spec.Config.DefaultLocale {
+			return "/index.html"
+		}
+		return "/" + code + "/index.html"
+	}
+
+	var b strings.Builder
+	b.WriteString("map $http_accept_language $webserver_locale_page {\n")
+	b.WriteString("    default  /index.html;\n")
+	for _, code := range codes {
+		fmt.Fprintf(&b, "    \"~*^%s\\b\"  %s;\n", code, page(code))
+	}
+	for _, code := range codes {
+		fmt.Fprintf(&b, "    \"~*,\\s*%s\\b\"  %s;\n", code, page(code))
+	}
+	b.WriteString("}\n\n")
+	return b.String()
+}
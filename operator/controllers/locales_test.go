@@ -0,0 +1,93 @@
+package controllers
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	webserverv1alpha1 "github.com/webserver/webserver-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+func localizedWebserver(locales map[string]webserverv1alpha1.LocaleContent) *webserverv1alpha1.Webserver {
+	return &webserverv1alpha1.Webserver{
+		ObjectMeta: metav1.ObjectMeta{Name: "site", Namespace: "web"},
+		Spec: webserverv1alpha1.WebserverSpec{
+			Config: webserverv1alpha1.WebserverConfig{Title: "Welcome", Locales: locales},
+		},
+	}
+}
+
+func TestLocalizedPages(t *testing.T) {
+	r := newTestReconciler(t, localizedWebserver(map[string]webserverv1alpha1.LocaleContent{
+		"de":    {Title: "Willkommen"},
+		"pt":    {Title: "Bem-vindo"},
+		"pt-BR": {Template: "<html><head><title>{{.Title}}</title></head><body>{{.Locale}} {{.Message}}</body></html>"},
+	}))
+
+	deployment := reconcileDeployment(t, r, "site")
+
+	configmap := &corev1.ConfigMap{}
+	if err := r.Get(context.Background(), types.NamespacedName{Name: "site-config", Namespace: "web"}, configmap); err != nil {
+		t.Fatal(err)
+	}
+	if page := configmap.Data["index.html"]; !strings.Contains(page, `<html lang="en">`) || !strings.Contains(page, "<title>Welcome</title>") {
+		t.Errorf("Expected the default page in en, got %q", page)
+	}
+	if page := configmap.Data["index.de.html"]; !strings.Contains(page, `<html lang="de">`) || !strings.Contains(page, "<h1>Willkommen</h1>") {
+		t.Errorf("Expected the de page with its own title, got %q", page)
+	}
+	page := configmap.Data["index.pt-BR.html"]
+	if !strings.Contains(page, "<title>Welcome</title>") || !strings.Contains(page, "pt-BR Welcome to the Webserver Operator Demo!") {
+		t.Errorf("Expected the pt-BR template rendered with the default title and message, got %q", page)
+	}
+	if !strings.Contains(page, `<meta name="`+contentMarkerMeta+`"`) {
+		t.Errorf("Expected the content marker in the template page, got %q", page)
+	}
+
+	config := configmap.Data[nginxConfigKey]
+	for _, want := range []string{"map $http_accept_language $webserver_locale_page", "location = /", "try_files  $webserver_locale_page /index.html;"} {
+		if !strings.Contains(config, want) {
+			t.Errorf("Expected %q in the nginx config, got %s", want, config)
+		}
+	}
+	if strings.Index(config, `"~*^pt-BR\b"  /pt-BR/index.html;`) > strings.Index(config, `"~*^pt\b"  /pt/index.html;`) {
+		t.Errorf("Expected pt-BR to be matched before pt, got %s", config)
+	}
+
+	var paths []string
+	for _, volume := range deployment.Spec.Template.Spec.Volumes {
+		if volume.Name == "html-content" {
+			for _, item := range volume.ConfigMap.Items {
+				paths = append(paths, item.Path)
+			}
+		}
+	}
+	if strings.Join(paths, ",") != "index.html,en/index.html,pt-BR/index.html,de/index.html,pt/index.html" {
+		t.Errorf("Expected every locale under its own path, got %v", paths)
+	}
+}
+
+func TestLocaleTemplateInvalid(t *testing.T) {
+	r := newTestReconciler(t, localizedWebserver(map[string]webserverv1alpha1.LocaleContent{
+		"fr": {Template: "<p>{{.Greeting}}</p>"},
+	}))
+
+	if _, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: types.NamespacedName{Name: "site", Namespace: "web"}}); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+
+	current := &webserverv1alpha1.Webserver{}
+	if err := r.Get(context.Background(), types.NamespacedName{Name: "site", Namespace: "web"}, current); err != nil {
+		t.Fatal(err)
+	}
+	if current.Status.Phase != "Failed" || len(current.Status.Conditions) == 0 || current.Status.Conditions[0].Reason != "InvalidLocale" {
+		t.Fatalf("Expected the Webserver to fail with InvalidLocale, got %s %v", current.Status.Phase, current.Status.Conditions)
+	}
+	if !strings.Contains(current.Status.Conditions[0].Message, `locale "fr"`) {
+		t.Errorf("Expected the message to name the locale, got %q", current.Status.Conditions[0].Message)
+	}
+}
@@ -0,0 +1,136 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/utils/ptr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	webserverv1alpha1 "github.com/webserver/webserver-operator/api/v1alpha1"
+)
+
+//+kubebuilder:rbac:groups=monitoring.coreos.com,resources=servicemonitors,verbs=get;list;watch;create;update;patch;delete
+
+const (
+	// metricsFeature is the Features key turning on request metrics
+	metricsFeature = "metrics"
+
+	// metricsContainerName is the name of the exporter sidecar
+	metricsContainerName = "metrics-exporter"
+
+	// metricsPort is the port the exporter serves Prometheus metrics on
+	metricsPort = 9113
+
+	// defaultMetricsExporterImage is used when no exporter image is configured
+	defaultMetricsExporterImage = "nginx/nginx-prometheus-exporter:1.3.0"
+
+	// stubStatusPath is the nginx location serving connection statistics
+	stubStatusPath = "/stub_status"
+)
+
+// serviceMonitorGVK identifies the Prometheus Operator ServiceMonitor kind
+var serviceMonitorGVK = schema.GroupVersionKind{Group: "monitoring.coreos.com", Version: "v1", Kind: "ServiceMonitor"}
+
+// ServiceMonitorsAvailable reports whether the ServiceMonitor CRD is installed
+func ServiceMonitorsAvailable(mapper meta.RESTMapper) (bool, error) {
+	_, err := mapper.RESTMapping(serviceMonitorGVK.GroupKind(), serviceMonitorGVK.Version)
+	if meta.IsNoMatchError(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// metricsEnabled reports whether the metrics feature is on for the Webserver
+func metricsEnabled(webserver *webserverv1alpha1.Webserver) bool {
+	return webserver.Spec.Config.Features[metricsFeature]
+}
+
+// metricsExporterContainer scrapes the nginx stub_status page and serves Prometheus metrics
+func (r *WebserverReconciler) metricsExporterContainer(webserver *webserverv1alpha1.Webserver) corev1.Container {
+	image := r.MetricsExporterImage
+	if image == "" {
+		image = defaultMetricsExporterImage
+	}
+	return corev1.Container{
+		Name:  metricsContainerName,
+		Image: image,
+		Args: []string{
+			fmt.Sprintf("--nginx.scrape-uri=http://127.0.0.1:%d%s", webserver.Spec.Port, stubStatusPath),
+			fmt.Sprintf("--web.listen-address=:%d", metricsPort),
+		},
+		Ports: []corev1.ContainerPort{{Name: "metrics", ContainerPort: metricsPort}},
+		SecurityContext: &corev1.SecurityContext{
+			RunAsNonRoot:             ptr.To(true),
+			RunAsUser:                ptr.To[int64](65534),
+			AllowPrivilegeEscalation: ptr.To(false),
+			ReadOnlyRootFilesystem:   ptr.To(true),
+			Capabilities:             &corev1.Capabilities{Drop: []corev1.Capability{"ALL"}},
+			SeccompProfile:           &corev1.SeccompProfile{Type: corev1.SeccompProfileTypeRuntimeDefault},
+		},
+	}
+}
+
+// metricsServicePort exposes the exporter through the web server Service
+func metricsServicePort() corev1.ServicePort {
+	return corev1.ServicePort{
+		Name:       "metrics",
+		Port:       metricsPort,
+		TargetPort: intstr.FromString("metrics"),
+	}
+}
+
+// reconcileServiceMonitor creates the ServiceMonitor scraping the exporter
+// while metrics are enabled and deletes it otherwise. It does nothing when the
+// ServiceMonitor CRD is not installed.
+func (r *WebserverReconciler) reconcileServiceMonitor(ctx context.Context, webserver *webserverv1alpha1.Webserver) error {
+	if !r.ServiceMonitors {
+		return nil
+	}
+
+	serviceMonitor := &unstructured.Unstructured{}
+	serviceMonitor.SetGroupVersionKind(serviceMonitorGVK)
+	serviceMonitor.SetName(webserver.Name + "-metrics")
+	serviceMonitor.SetNamespace(webserver.Namespace)
+
+	if !metricsEnabled(webserver) {
+		err := r.Get(ctx, client.ObjectKeyFromObject(serviceMonitor), serviceMonitor)
+		if err != nil {
+			return client.IgnoreNotFound(err)
+		}
+		return client.IgnoreNotFound(r.Delete(ctx, serviceMonitor))
+	}
+
+	op, err := r.createOrUpdate(ctx, "mutateServiceMonitor", webserver, serviceMonitor, func() error {
+		if err := ctrl.SetControllerReference(webserver, serviceMonitor, r.Scheme); err != nil {
+			return err
+		}
+		r.setChildMetadata(serviceMonitor, webserver)
+		return unstructured.SetNestedField(serviceMonitor.Object, map[string]interface{}{
+			"selector": map[string]interface{}{
+				"matchLabels": map[string]interface{}{
+					"app":      "webserver",
+					"instance": webserver.Name,
+				},
+			},
+			"endpoints": []interface{}{
+				map[string]interface{}{"port": "metrics", "interval": "30s"},
+			},
+		}, "spec")
+	})
+	if err != nil {
+		return err
+	}
+	if op != controllerutil.OperationResultNone {
+		log.FromContext(ctx).Info("ServiceMonitor operation", "operation", op)
+	}
+	return nil
+}
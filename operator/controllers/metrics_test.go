@@ -0,0 +1,143 @@
+package controllers
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	webserverv1alpha1 "github.com/webserver/webserver-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func metricsWebserver() *webserverv1alpha1.Webserver {
+	return &webserverv1alpha1.Webserver{
+		ObjectMeta: metav1.ObjectMeta{Name: "site", Namespace: "web"},
+		Spec: webserverv1alpha1.WebserverSpec{
+			Config: webserverv1alpha1.WebserverConfig{Features: map[string]bool{"metrics": true}},
+		},
+	}
+}
+
+func getServiceMonitor(t *testing.T, r *WebserverReconciler) (*unstructured.Unstructured, error) {
+	t.Helper()
+	serviceMonitor := &unstructured.Unstructured{}
+	serviceMonitor.SetGroupVersionKind(serviceMonitorGVK)
+	err := r.Get(context.Background(), types.NamespacedName{Name: "site-metrics", Namespace: "web"}, serviceMonitor)
+	return serviceMonitor, err
+}
+
+func TestMetricsExporterSidecar(t *testing.T) {
+	r := newTestReconciler(t, metricsWebserver())
+	r.MetricsExporterImage = "registry.example.com/nginx-prometheus-exporter:1.3.0"
+
+	deployment := reconcileDeployment(t, r, "site")
+
+	containers := deployment.Spec.Template.Spec.Containers
+	if len(containers) != 2 || containers[1].Name != metricsContainerName {
+		t.Fatalf("Expected the metrics exporter sidecar, got %v", containers)
+	}
+	if containers[1].Image != r.MetricsExporterImage {
+		t.Errorf("Expected the configured exporter image, got %s", containers[1].Image)
+	}
+	if got := containers[1].Args[0]; got != "--nginx.scrape-uri=http://127.0.0.1:8080/stub_status" {
+		t.Errorf("Expected the exporter to scrape the web server port, got %s", got)
+	}
+	if violations := restrictedViolations(t, &deployment.Spec.Template); len(violations) > 0 {
+		t.Errorf("Expected the pod to meet the restricted profile, got %v", violations)
+	}
+
+	service := &corev1.Service{}
+	if err := r.Get(context.Background(), types.NamespacedName{Name: "site-service", Namespace: "web"}, service); err != nil {
+		t.Fatal(err)
+	}
+	if len(service.Spec.Ports) != 2 || service.Spec.Ports[1].Name != "metrics" || service.Spec.Ports[1].Port != metricsPort {
+		t.Errorf("Expected a metrics Service port, got %v", service.Spec.Ports)
+	}
+
+	configmap := &corev1.ConfigMap{}
+	if err := r.Get(context.Background(), types.NamespacedName{Name: "site-config", Namespace: "web"}, configmap); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(configmap.Data[nginxConfigKey], "location = /stub_status") {
+		t.Errorf("Expected nginx to serve stub_status, got %q", configmap.Data[nginxConfigKey])
+	}
+}
+
+func TestMetricsDisabledByDefault(t *testing.T) {
+	webserver := &webserverv1alpha1.Webserver{ObjectMeta: metav1.ObjectMeta{Name: "site", Namespace: "web"}}
+	r := newTestReconciler(t, webserver)
+
+	deployment := reconcileDeployment(t, r, "site")
+
+	if len(deployment.Spec.Template.Spec.Containers) != 1 {
+		t.Errorf("Expected no sidecar, got %v", deployment.Spec.Template.Spec.Containers)
+	}
+	configmap := &corev1.ConfigMap{}
+	if err := r.Get(context.Background(), types.NamespacedName{Name: "site-config", Namespace: "web"}, configmap); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(configmap.Data[nginxConfigKey], "stub_status") {
+		t.Errorf("Expected stub_status to stay off, got %q", configmap.Data[nginxConfigKey])
+	}
+}
+
+func TestServiceMonitorLifecycle(t *testing.T) {
+	webserver := metricsWebserver()
+	r := newTestReconciler(t, webserver)
+	r.ServiceMonitors = true
+
+	reconcileDeployment(t, r, "site")
+
+	serviceMonitor, err := getServiceMonitor(t, r)
+	if err != nil {
+		t.Fatalf("Expected a ServiceMonitor, got %v", err)
+	}
+	matchLabels, _, _ := unstructured.NestedStringMap(serviceMonitor.Object, "spec", "selector", "matchLabels")
+	if matchLabels["instance"] != "site" {
+		t.Errorf("Expected the ServiceMonitor to select the site Service, got %v", matchLabels)
+	}
+	if owners := serviceMonitor.GetOwnerReferences(); len(owners) != 1 || owners[0].Name != "site" {
+		t.Errorf("Expected the Webserver to own the ServiceMonitor, got %v", owners)
+	}
+
+	if err := r.Get(context.Background(), types.NamespacedName{Name: "site", Namespace: "web"}, webserver); err != nil {
+		t.Fatal(err)
+	}
+	webserver.Spec.Config.Features["metrics"] = false
+	if err := r.Update(context.Background(), webserver); err != nil {
+		t.Fatal(err)
+	}
+	reconcileDeployment(t, r, "site")
+
+	if _, err := getServiceMonitor(t, r); !apierrors.IsNotFound(err) {
+		t.Errorf("Expected the ServiceMonitor to be deleted, got %v", err)
+	}
+}
+
+func TestServiceMonitorSkippedWithoutCRD(t *testing.T) {
+	r := newTestReconciler(t, metricsWebserver())
+
+	reconcileDeployment(t, r, "site")
+
+	if _, err := getServiceMonitor(t, r); !apierrors.IsNotFound(err) {
+		t.Errorf("Expected no ServiceMonitor without the CRD, got %v", err)
+	}
+}
+
+func TestServiceMonitorsAvailable(t *testing.T) {
+	mapper := meta.NewDefaultRESTMapper([]schema.GroupVersion{serviceMonitorGVK.GroupVersion()})
+	if available, err := ServiceMonitorsAvailable(mapper); err != nil || available {
+		t.Errorf("Expected ServiceMonitors to be unavailable, got %v, %v", available, err)
+	}
+
+	mapper.Add(serviceMonitorGVK, meta.RESTScopeNamespace)
+	if available, err := ServiceMonitorsAvailable(mapper); err != nil || !available {
+		t.Errorf("Expected ServiceMonitors to be available, got %v, %v", available, err)
+	}
+}
@@ -0,0 +1,97 @@
+package controllers
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+
+	webserverv1alpha1 "github.com/webserver/webserver-operator/api/v1alpha1"
+)
+
+// webserverContainerName is the name of the built-in web server container
+const webserverContainerName = "webserver"
+
+// builtinVolumeNames returns the names of the volumes the operator adds to every pod
+func builtinVolumeNames() []string {
+	names := []string{"html-content", "nginx-config"}
+	for _, writable := range nginxWritablePaths {
+		names = append(names, writable.Volume)
+	}
+	return names
+}
+
+// podExtensionViolations reports extra containers and volumes whose names
+// collide with the built-in ones or with each other
+func podExtensionViolations(webserver *webserverv1alpha1.Webserver) []string {
+	var violations []string
+
+	containers := map[string]string{
+		webserverContainerName: "the built-in web server container",
+		metricsContainerName:   "the built-in metrics exporter",
+	}
+	check := func(kind string, list []corev1.Container) {
+		for _, container := range list {
+			if owner, ok := containers[container.Name]; ok {
+				violations = append(violations, fmt.Sprintf("%s %q collides with %s", kind, container.Name, owner))
+				continue
+			}
+			containers[container.Name] = kind + " " + container.Name
+		}
+	}
+	check("extra container", webserver.Spec.ExtraContainers)
+	check("init container", webserver.Spec.InitContainers)
+
+	volumes := map[string]string{}
+	for _, name := range builtinVolumeNames() {
+		volumes[name] = "a built-in volume"
+	}
+	for _, volume := range webserver.Spec.ExtraVolumes {
+		if owner, ok := volumes[volume.Name]; ok {
+			violations = append(violations, fmt.Sprintf("extra volume %q collides with %s", volume.Name, owner))
+			continue
+		}
+		volumes[volume.Name] = "extra volume " + volume.Name
+	}
+
+	mountPaths := map[string]bool{"/usr/share/nginx/html": true, "/etc/nginx/conf.d": true}
+	for _, writable := range nginxWritablePaths {
+		mountPaths[writable.Path] = true
+	}
+	for _, mount := range webserver.Spec.ExtraVolumeMounts {
+		if mountPaths[mount.MountPath] {
+			violations = append(violations, fmt.Sprintf("extra volume mount at %q collides with a built-in mount", mount.MountPath))
+		}
+	}
+
+	return violations
+}
+
+// applyPodExtensions merges the extra containers, volumes and environment of
+// the Webserver into the pod spec built by mutateDeployment
+func applyPodExtensions(podSpec *corev1.PodSpec, webserver *webserverv1alpha1.Webserver) {
+	for i := range podSpec.Containers {
+		container := &podSpec.Containers[i]
+		if container.Name != webserverContainerName {
+			continue
+		}
+		for _, mount := range webserver.Spec.ExtraVolumeMounts {
+			container.VolumeMounts = append(container.VolumeMounts, *mount.DeepCopy())
+		}
+		for _, env := range webserver.Spec.Env {
+			container.Env = append(container.Env, *env.DeepCopy())
+		}
+		for _, envFrom := range webserver.Spec.EnvFrom {
+			container.EnvFrom = append(container.EnvFrom, *envFrom.DeepCopy())
+		}
+	}
+
+	for _, container := range webserver.Spec.ExtraContainers {
+		podSpec.Containers = append(podSpec.Containers, *container.DeepCopy())
+	}
+	for _, container := range webserver.Spec.InitContainers {
+		podSpec.InitContainers = append(podSpec.InitContainers, *container.DeepCopy())
+	}
+	for _, volume := range webserver.Spec.ExtraVolumes {
+		podSpec.Volumes = append(podSpec.Volumes, *volume.DeepCopy())
+	}
+}
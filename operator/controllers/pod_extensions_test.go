@@ -0,0 +1,121 @@
+package controllers
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	webserverv1alpha1 "github.com/webserver/webserver-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+func TestDeploymentIncludesPodExtensions(t *testing.T) {
+	webserver := &webserverv1alpha1.Webserver{
+		ObjectMeta: metav1.ObjectMeta{Name: "site", Namespace: "web"},
+		Spec: webserverv1alpha1.WebserverSpec{
+			ExtraContainers: []corev1.Container{{Name: "log-shipper", Image: "fluent/fluent-bit:3.0"}},
+			InitContainers:  []corev1.Container{{Name: "content-sync", Image: "alpine/git:2.45"}},
+			ExtraVolumes: []corev1.Volume{{
+				Name:         "shared-logs",
+				VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}},
+			}},
+			ExtraVolumeMounts: []corev1.VolumeMount{{Name: "shared-logs", MountPath: "/var/log/shared"}},
+			Env:               []corev1.EnvVar{{Name: "TZ", Value: "Europe/Berlin"}},
+			EnvFrom: []corev1.EnvFromSource{{
+				ConfigMapRef: &corev1.ConfigMapEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: "site-env"}},
+			}},
+		},
+	}
+	r := newTestReconciler(t, webserver)
+
+	podSpec := reconcileDeployment(t, r, "site").Spec.Template.Spec
+
+	if len(podSpec.Containers) != 2 || podSpec.Containers[0].Name != "webserver" || podSpec.Containers[1].Name != "log-shipper" {
+		t.Fatalf("Expected web server followed by the sidecar, got %v", podSpec.Containers)
+	}
+	if len(podSpec.InitContainers) != 1 || podSpec.InitContainers[0].Name != "content-sync" {
+		t.Errorf("Expected the init container, got %v", podSpec.InitContainers)
+	}
+	if podSpec.Volumes[len(podSpec.Volumes)-1].Name != "shared-logs" {
+		t.Errorf("Expected the extra volume to be appended, got %v", podSpec.Volumes)
+	}
+	container := podSpec.Containers[0]
+	if mount := container.VolumeMounts[len(container.VolumeMounts)-1]; mount.MountPath != "/var/log/shared" {
+		t.Errorf("Expected the extra mount on the web server, got %v", container.VolumeMounts)
+	}
+	if len(container.Env) != 1 || container.Env[0].Name != "TZ" {
+		t.Errorf("Expected env on the web server, got %v", container.Env)
+	}
+	if len(container.EnvFrom) != 1 || container.EnvFrom[0].ConfigMapRef.Name != "site-env" {
+		t.Errorf("Expected envFrom on the web server, got %v", container.EnvFrom)
+	}
+}
+
+func TestPodExtensionViolations(t *testing.T) {
+	tests := []struct {
+		name string
+		spec webserverv1alpha1.WebserverSpec
+		want string
+	}{
+		{
+			name: "sidecar named webserver",
+			spec: webserverv1alpha1.WebserverSpec{ExtraContainers: []corev1.Container{{Name: "webserver"}}},
+			want: `extra container "webserver" collides with the built-in web server container`,
+		},
+		{
+			name: "init container reusing a sidecar name",
+			spec: webserverv1alpha1.WebserverSpec{
+				ExtraContainers: []corev1.Container{{Name: "proxy"}},
+				InitContainers:  []corev1.Container{{Name: "proxy"}},
+			},
+			want: `init container "proxy" collides with extra container proxy`,
+		},
+		{
+			name: "volume named html-content",
+			spec: webserverv1alpha1.WebserverSpec{ExtraVolumes: []corev1.Volume{{Name: "html-content"}}},
+			want: `extra volume "html-content" collides with a built-in volume`,
+		},
+		{
+			name: "mount over the document root",
+			spec: webserverv1alpha1.WebserverSpec{ExtraVolumeMounts: []corev1.VolumeMount{{Name: "docs", MountPath: "/usr/share/nginx/html"}}},
+			want: `extra volume mount at "/usr/share/nginx/html" collides with a built-in mount`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			violations := podExtensionViolations(&webserverv1alpha1.Webserver{Spec: tt.spec})
+			if len(violations) != 1 || violations[0] != tt.want {
+				t.Errorf("Expected violation %q, got %v", tt.want, violations)
+			}
+		})
+	}
+}
+
+func TestReconcileRejectsNameCollision(t *testing.T) {
+	webserver := &webserverv1alpha1.Webserver{
+		ObjectMeta: metav1.ObjectMeta{Name: "site", Namespace: "web"},
+		Spec: webserverv1alpha1.WebserverSpec{
+			ExtraVolumes: []corev1.Volume{{Name: "html-content"}},
+		},
+	}
+	r := newTestReconciler(t, webserver)
+
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: "site", Namespace: "web"}}
+	if _, err := r.Reconcile(context.Background(), req); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+
+	updated := &webserverv1alpha1.Webserver{}
+	if err := r.Get(context.Background(), req.NamespacedName, updated); err != nil {
+		t.Fatal(err)
+	}
+	condition := meta.FindStatusCondition(updated.Status.Conditions, "Ready")
+	if condition == nil || condition.Reason != "NameCollision" || !strings.Contains(condition.Message, "html-content") {
+		t.Errorf("Expected NameCollision condition, got %+v", condition)
+	}
+}
@@ -0,0 +1,111 @@
+package controllers
+
+import (
+	"context"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	webserverv1alpha1 "github.com/webserver/webserver-operator/api/v1alpha1"
+)
+
+// maxPodIssues caps the number of issues reported in status.podSummary
+const maxPodIssues = 5
+
+// benignWaitingReasons are waiting reasons of containers that are starting normally
+var benignWaitingReasons = map[string]bool{
+	"ContainerCreating": true,
+	"PodInitializing":   true,
+}
+
+// updatePodSummary aggregates the pods of the Webserver into status.podSummary
+func (r *WebserverReconciler) updatePodSummary(ctx context.Context, webserver *webserverv1alpha1.Webserver) error {
+	pods := &corev1.PodList{}
+	if err := r.List(ctx, pods, client.InNamespace(webserver.Namespace), client.MatchingLabels{
+		"app":      "webserver",
+		"instance": webserver.Name,
+	}); err != nil {
+		return err
+	}
+	webserver.Status.PodSummary = summarizePods(pods.Items)
+	return nil
+}
+
+// summarizePods counts pods by phase and collects the most pressing issues,
+// ordered by restart count
+func summarizePods(pods []corev1.Pod) *webserverv1alpha1.PodSummary {
+	summary := &webserverv1alpha1.PodSummary{Total: int32(len(pods))}
+	for _, pod := range pods {
+		switch pod.Status.Phase {
+		case corev1.PodPending:
+			summary.Pending++
+		case corev1.PodRunning:
+			summary.Running++
+		case corev1.PodFailed:
+			summary.Failed++
+		}
+		for _, condition := range pod.Status.Conditions {
+			switch {
+			case condition.Type == corev1.PodReady && condition.Status == corev1.ConditionTrue:
+				summary.Ready++
+			case condition.Type == corev1.PodScheduled && condition.Status == corev1.ConditionFalse:
+				summary.Issues = append(summary.Issues, webserverv1alpha1.PodIssue{
+					Pod:     pod.Name,
+					Reason:  condition.Reason,
+					Message: condition.Message,
+				})
+			}
+		}
+
+		statuses := append(append([]corev1.ContainerStatus{}, pod.Status.InitContainerStatuses...), pod.Status.ContainerStatuses...)
+		for _, status := range statuses {
+			summary.Restarts += status.RestartCount
+			if issue, ok := containerIssue(pod.Name, status); ok {
+				summary.Issues = append(summary.Issues, issue)
+			}
+		}
+	}
+
+	sort.SliceStable(summary.Issues, func(i, j int) bool {
+		if summary.Issues[i].Restarts != summary.Issues[j].Restarts {
+			return summary.Issues[i].Restarts > summary.Issues[j].Restarts
+		}
+		return summary.Issues[i].Pod < summary.Issues[j].Pod
+	})
+	if len(summary.Issues) > maxPodIssues {
+		summary.Issues = summary.Issues[:maxPodIssues]
+	}
+	return summary
+}
+
+// containerIssue reports the current waiting reason of a container, or the
+// reason it last terminated if it has been restarted
+func containerIssue(pod string, status corev1.ContainerStatus) (webserverv1alpha1.PodIssue, bool) {
+	issue := webserverv1alpha1.PodIssue{Pod: pod, Container: status.Name, Restarts: status.RestartCount}
+	if waiting := status.State.Waiting; waiting != nil && !benignWaitingReasons[waiting.Reason] {
+		issue.Reason = waiting.Reason
+		issue.Message = waiting.Message
+		if terminated := status.LastTerminationState.Terminated; terminated != nil && issue.Message == "" {
+			issue.Message = "last terminated: " + terminated.Reason
+		}
+		return issue, true
+	}
+	if terminated := status.LastTerminationState.Terminated; terminated != nil && status.RestartCount > 0 {
+		issue.Reason = terminated.Reason
+		issue.Message = terminated.Message
+		return issue, true
+	}
+	return issue, false
+}
+
+// webserverForPod maps a pod event to the Webserver running it
+func (r *WebserverReconciler) webserverForPod(ctx context.Context, obj client.Object) []reconcile.Request {
+	labels := obj.GetLabels()
+	if labels["app"] != "webserver" || labels["instance"] == "" {
+		return nil
+	}
+	return []reconcile.Request{{NamespacedName: types.NamespacedName{Name: labels["instance"], Namespace: obj.GetNamespace()}}}
+}
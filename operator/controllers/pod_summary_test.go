@@ -0,0 +1,140 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	webserverv1alpha1 "github.com/webserver/webserver-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+func webserverPod(name string, phase corev1.PodPhase, ready bool, statuses ...corev1.ContainerStatus) *corev1.Pod {
+	readyStatus := corev1.ConditionFalse
+	if ready {
+		readyStatus = corev1.ConditionTrue
+	}
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "web",
+			Labels:    map[string]string{"app": "webserver", "instance": "site"},
+		},
+		Status: corev1.PodStatus{
+			Phase:             phase,
+			Conditions:        []corev1.PodCondition{{Type: corev1.PodReady, Status: readyStatus}},
+			ContainerStatuses: statuses,
+		},
+	}
+}
+
+func crashLooping(restarts int32) corev1.ContainerStatus {
+	return corev1.ContainerStatus{
+		Name:         "webserver",
+		RestartCount: restarts,
+		State: corev1.ContainerState{
+			Waiting: &corev1.ContainerStateWaiting{Reason: "CrashLoopBackOff", Message: "back-off 5m0s restarting failed container"},
+		},
+		LastTerminationState: corev1.ContainerState{
+			Terminated: &corev1.ContainerStateTerminated{Reason: "Error", ExitCode: 1},
+		},
+	}
+}
+
+func TestSummarizePods(t *testing.T) {
+	unschedulable := webserverPod("site-3", corev1.PodPending, false)
+	unschedulable.Status.Conditions = append(unschedulable.Status.Conditions, corev1.PodCondition{
+		Type:    corev1.PodScheduled,
+		Status:  corev1.ConditionFalse,
+		Reason:  "Unschedulable",
+		Message: "0/3 nodes are available: 3 Insufficient memory.",
+	})
+	pods := []corev1.Pod{
+		*webserverPod("site-1", corev1.PodRunning, true, corev1.ContainerStatus{Name: "webserver", Ready: true}),
+		*webserverPod("site-2", corev1.PodRunning, false, crashLooping(7)),
+		*unschedulable,
+		*webserverPod("site-4", corev1.PodRunning, true, corev1.ContainerStatus{
+			Name:                 "webserver",
+			Ready:                true,
+			RestartCount:         2,
+			LastTerminationState: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{Reason: "OOMKilled", ExitCode: 137}},
+		}),
+		*webserverPod("site-5", corev1.PodPending, false, corev1.ContainerStatus{
+			Name:  "webserver",
+			State: corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "ContainerCreating"}},
+		}),
+	}
+
+	summary := summarizePods(pods)
+
+	if summary.Total != 5 || summary.Running != 3 || summary.Pending != 2 || summary.Ready != 2 {
+		t.Errorf("Unexpected counts: %+v", summary)
+	}
+	if summary.Restarts != 9 {
+		t.Errorf("Expected 9 restarts, got %d", summary.Restarts)
+	}
+	wantReasons := []string{"CrashLoopBackOff", "OOMKilled", "Unschedulable"}
+	if len(summary.Issues) != len(wantReasons) {
+		t.Fatalf("Expected %d issues, got %+v", len(wantReasons), summary.Issues)
+	}
+	for i, reason := range wantReasons {
+		if summary.Issues[i].Reason != reason {
+			t.Errorf("Expected issue %d to be %s, got %s", i, reason, summary.Issues[i].Reason)
+		}
+	}
+	if summary.Issues[0].Pod != "site-2" || summary.Issues[0].Restarts != 7 {
+		t.Errorf("Expected the crash-looping pod first, got %+v", summary.Issues[0])
+	}
+}
+
+func TestSummarizePodsCapsIssues(t *testing.T) {
+	var pods []corev1.Pod
+	for i := 0; i < 8; i++ {
+		pods = append(pods, *webserverPod(fmt.Sprintf("site-%d", i), corev1.PodRunning, false, crashLooping(int32(i))))
+	}
+
+	summary := summarizePods(pods)
+
+	if len(summary.Issues) != maxPodIssues {
+		t.Fatalf("Expected %d issues, got %d", maxPodIssues, len(summary.Issues))
+	}
+	if summary.Issues[0].Restarts != 7 {
+		t.Errorf("Expected the pod with most restarts first, got %+v", summary.Issues[0])
+	}
+}
+
+func TestWebserverForPod(t *testing.T) {
+	r := &WebserverReconciler{}
+
+	requests := r.webserverForPod(context.Background(), webserverPod("site-1", corev1.PodRunning, true))
+	if len(requests) != 1 || requests[0].NamespacedName != (types.NamespacedName{Name: "site", Namespace: "web"}) {
+		t.Errorf("Expected request for web/site, got %v", requests)
+	}
+
+	other := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Name: "db-0", Namespace: "web", Labels: map[string]string{"app": "postgres"}}}
+	if requests := r.webserverForPod(context.Background(), other); len(requests) != 0 {
+		t.Errorf("Expected no requests for unrelated pods, got %v", requests)
+	}
+}
+
+func TestReconcileReportsPodSummary(t *testing.T) {
+	webserver := &webserverv1alpha1.Webserver{ObjectMeta: metav1.ObjectMeta{Name: "site", Namespace: "web"}}
+	r := newTestReconciler(t, webserver, webserverPod("site-1", corev1.PodRunning, false, crashLooping(3)))
+
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: "site", Namespace: "web"}}
+	if _, err := r.Reconcile(context.Background(), req); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+
+	updated := &webserverv1alpha1.Webserver{}
+	if err := r.Get(context.Background(), req.NamespacedName, updated); err != nil {
+		t.Fatal(err)
+	}
+	summary := updated.Status.PodSummary
+	if summary == nil || summary.Total != 1 || len(summary.Issues) != 1 || summary.Issues[0].Reason != "CrashLoopBackOff" {
+		t.Errorf("Expected a crash loop in the pod summary, got %+v", summary)
+	}
+}
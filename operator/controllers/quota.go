@@ -0,0 +1,218 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/util/workqueue"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	webserverv1alpha1 "github.com/webserver/webserver-operator/api/v1alpha1"
+)
+
+// quotaExceededCondition marks a Webserver held back by a WebserverQuota
+const quotaExceededCondition = "QuotaExceeded"
+
+//+kubebuilder:rbac:groups=webserver.io,resources=webserverquotas,verbs=get;list;watch
+//+kubebuilder:rbac:groups=webserver.io,resources=webserverquotas/status,verbs=get;update;patch
+
+// quotaReplicas is the replica count a Webserver is charged: the larger of
+// spec.replicas and its largest schedule window
+func quotaReplicas(webserver *webserverv1alpha1.Webserver) int32 {
+	replicas := max(webserver.Spec.Replicas, 1)
+	for _, window := range webserver.Spec.Schedule {
+		replicas = max(replicas, window.Replicas)
+	}
+	return replicas
+}
+
+// fitsQuota reports whether usage stays within the limits of the quota
+func fitsQuota(quota *webserverv1alpha1.WebserverQuota, usage webserverv1alpha1.WebserverQuotaUsage) bool {
+	if limit := quota.Spec.MaxWebservers; limit != nil && usage.Webservers > *limit {
+		return false
+	}
+	if limit := quota.Spec.MaxReplicas; limit != nil && usage.Replicas > *limit {
+		return false
+	}
+	return true
+}
+
+// admitWebservers charges the live Webservers of a namespace against the
+// quota, oldest first, and returns the usage of the admitted ones together
+// with the names of those that do not fit. A Webserver that does not fit is
+// skipped, so a smaller, younger one may still be admitted.
+func admitWebservers(quota *webserverv1alpha1.WebserverQuota, webservers []webserverv1alpha1.Webserver) (webserverv1alpha1.WebserverQuotaUsage, []string) {
+	live := make([]*webserverv1alpha1.Webserver, 0, len(webservers))
+	for i := range webservers {
+		if webservers[i].DeletionTimestamp.IsZero() {
+			live = append(live, &webservers[i])
+		}
+	}
+	sort.Slice(live, func(i, j int) bool {
+		if !live[i].CreationTimestamp.Equal(&live[j].CreationTimestamp) {
+			return live[i].CreationTimestamp.Before(&live[j].CreationTimestamp)
+		}
+		return live[i].Name < live[j].Name
+	})
+
+	var used webserverv1alpha1.WebserverQuotaUsage
+	var exceeded []string
+	for _, webserver := range live {
+		next := webserverv1alpha1.WebserverQuotaUsage{
+			Webservers: used.Webservers + 1,
+			Replicas:   used.Replicas + quotaReplicas(webserver),
+		}
+		if !fitsQuota(quota, next) {
+			exceeded = append(exceeded, webserver.Name)
+			continue
+		}
+		used = next
+	}
+	sort.Strings(exceeded)
+	return used, exceeded
+}
+
+// quotaDescription summarizes the limits of a quota for messages
+func quotaDescription(quota *webserverv1alpha1.WebserverQuota) string {
+	var limits []string
+	if quota.Spec.MaxWebservers != nil {
+		limits = append(limits, fmt.Sprintf("%d Webservers", *quota.Spec.MaxWebservers))
+	}
+	if quota.Spec.MaxReplicas != nil {
+		limits = append(limits, fmt.Sprintf("%d replicas", *quota.Spec.MaxReplicas))
+	}
+	if len(limits) == 0 {
+		return fmt.Sprintf("WebserverQuota %q", quota.Name)
+	}
+	return fmt.Sprintf("WebserverQuota %q (%s)", quota.Name, strings.Join(limits, ", "))
+}
+
+// checkQuota returns a message naming the first WebserverQuota of the
+// namespace the Webserver does not fit into, or "" when it fits them all. It
+// also reports whether the namespace has any quota.
+func (r *WebserverReconciler) checkQuota(ctx context.Context, webserver *webserverv1alpha1.Webserver) (string, bool, error) {
+	quotas := &webserverv1alpha1.WebserverQuotaList{}
+	if err := r.List(ctx, quotas, client.InNamespace(webserver.Namespace)); err != nil {
+		return "", false, err
+	}
+	if len(quotas.Items) == 0 {
+		return "", false, nil
+	}
+	webservers := &webserverv1alpha1.WebserverList{}
+	if err := r.List(ctx, webservers, client.InNamespace(webserver.Namespace)); err != nil {
+		return "", false, err
+	}
+
+	sort.Slice(quotas.Items, func(i, j int) bool { return quotas.Items[i].Name < quotas.Items[j].Name })
+	for i := range quotas.Items {
+		quota := &quotas.Items[i]
+		_, exceeded := admitWebservers(quota, webservers.Items)
+		for _, name := range exceeded {
+			if name == webserver.Name {
+				return fmt.Sprintf("Webserver with %d replicas does not fit %s", quotaReplicas(webserver), quotaDescription(quota)), true, nil
+			}
+		}
+	}
+	return "", true, nil
+}
+
+// quotaExceededError holds the Webserver back without touching its children
+// until the quota or the other Webservers of the namespace change
+func quotaExceededError(webserver *webserverv1alpha1.Webserver, message string) error {
+	meta.SetStatusCondition(&webserver.Status.Conditions, metav1.Condition{
+		Type:    quotaExceededCondition,
+		Status:  metav1.ConditionTrue,
+		Reason:  "QuotaExceeded",
+		Message: message,
+	})
+	return dependencyMissingError("QuotaExceeded", message)
+}
+
+// webserversForQuota requeues every Webserver in the namespace of a quota
+func (r *WebserverReconciler) webserversForQuota(ctx context.Context, obj client.Object) []reconcile.Request {
+	webservers := &webserverv1alpha1.WebserverList{}
+	if err := r.List(ctx, webservers, client.InNamespace(obj.GetNamespace())); err != nil {
+		log.FromContext(ctx).Error(err, "Failed to list Webservers for quota", "quota", obj.GetName())
+		return nil
+	}
+	requests := make([]reconcile.Request, 0, len(webservers.Items))
+	for _, webserver := range webservers.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&webserver)})
+	}
+	return requests
+}
+
+// WebserverQuotaReconciler reports the usage of a WebserverQuota
+type WebserverQuotaReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+
+	// MaxConcurrentReconciles is the number of WebserverQuotas reconciled in parallel
+	MaxConcurrentReconciles int
+
+	// RateLimiter paces the retries of failed reconciles; the controller-runtime
+	// default is used when nil
+	RateLimiter workqueue.TypedRateLimiter[reconcile.Request]
+}
+
+// Reconcile recomputes which Webservers of the namespace fit the quota
+func (r *WebserverQuotaReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+
+	quota := &webserverv1alpha1.WebserverQuota{}
+	if err := r.Get(ctx, req.NamespacedName, quota); err != nil {
+		if errors.IsNotFound(err) {
+			return ctrl.Result{}, nil
+		}
+		log.Error(err, "Failed to get WebserverQuota")
+		return ctrl.Result{}, err
+	}
+
+	webservers := &webserverv1alpha1.WebserverList{}
+	if err := r.List(ctx, webservers, client.InNamespace(quota.Namespace)); err != nil {
+		log.Error(err, "Failed to list Webservers")
+		return ctrl.Result{}, err
+	}
+	quota.Status.ObservedGeneration = quota.Generation
+	quota.Status.Used, quota.Status.Exceeded = admitWebservers(quota, webservers.Items)
+
+	if err := r.Status().Update(ctx, quota); err != nil {
+		log.Error(err, "Failed to update WebserverQuota status")
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{}, nil
+}
+
+// quotasForWebserver requeues the quotas of the namespace a Webserver lives in
+func (r *WebserverQuotaReconciler) quotasForWebserver(ctx context.Context, obj client.Object) []reconcile.Request {
+	quotas := &webserverv1alpha1.WebserverQuotaList{}
+	if err := r.List(ctx, quotas, client.InNamespace(obj.GetNamespace())); err != nil {
+		log.FromContext(ctx).Error(err, "Failed to list WebserverQuotas", "namespace", obj.GetNamespace())
+		return nil
+	}
+	requests := make([]reconcile.Request, 0, len(quotas.Items))
+	for _, quota := range quotas.Items {
+		requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&quota)})
+	}
+	return requests
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *WebserverQuotaReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&webserverv1alpha1.WebserverQuota{}).
+		Watches(&webserverv1alpha1.Webserver{}, handler.EnqueueRequestsFromMapFunc(r.quotasForWebserver)).
+		WithOptions(controller.Options{MaxConcurrentReconciles: r.MaxConcurrentReconciles, RateLimiter: r.RateLimiter}).
+		Complete(r)
+}
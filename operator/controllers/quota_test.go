@@ -0,0 +1,160 @@
+package controllers
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	webserverv1alpha1 "github.com/webserver/webserver-operator/api/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// quotaWebserver returns a Webserver in the web namespace created minutes after a fixed time
+func quotaWebserver(name string, replicas int32, minutes int) *webserverv1alpha1.Webserver {
+	return &webserverv1alpha1.Webserver{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name, Namespace: "web",
+			CreationTimestamp: metav1.NewTime(time.Date(2026, 5, 1, 12, minutes, 0, 0, time.UTC)),
+		},
+		Spec: webserverv1alpha1.WebserverSpec{Replicas: replicas},
+	}
+}
+
+func tenantQuota(maxWebservers, maxReplicas int32) *webserverv1alpha1.WebserverQuota {
+	return &webserverv1alpha1.WebserverQuota{
+		ObjectMeta: metav1.ObjectMeta{Name: "tenant", Namespace: "web"},
+		Spec: webserverv1alpha1.WebserverQuotaSpec{
+			MaxWebservers: ptr.To(maxWebservers),
+			MaxReplicas:   ptr.To(maxReplicas),
+		},
+	}
+}
+
+func TestAdmitWebserversOldestFirst(t *testing.T) {
+	scheduled := quotaWebserver("scheduled", 1, 3)
+	scheduled.Spec.Schedule = []webserverv1alpha1.ScheduleWindow{{Name: "peak", Cron: "0 9 * * *", Replicas: 4}}
+	webservers := []webserverv1alpha1.Webserver{
+		*quotaWebserver("young", 1, 4),
+		*quotaWebserver("old", 3, 0),
+		*quotaWebserver("big", 5, 1),
+		*scheduled,
+		*quotaWebserver("defaulted", 0, 2),
+	}
+
+	used, exceeded := admitWebservers(tenantQuota(3, 6), webservers)
+
+	if used.Webservers != 3 || used.Replicas != 5 {
+		t.Errorf("Expected 3 Webservers with 5 replicas admitted, got %+v", used)
+	}
+	if strings.Join(exceeded, ",") != "big,scheduled" {
+		t.Errorf("Expected big and scheduled to exceed the quota, got %v", exceeded)
+	}
+}
+
+func TestReconcileHoldsBackOverQuotaWebserver(t *testing.T) {
+	r := newTestReconciler(t, tenantQuota(5, 4), quotaWebserver("first", 3, 0), quotaWebserver("second", 2, 1))
+
+	reconcileDeployment(t, r, "first")
+	if _, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: types.NamespacedName{Name: "second", Namespace: "web"}}); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+
+	err := r.Get(context.Background(), types.NamespacedName{Name: "second-deployment", Namespace: "web"}, &appsv1.Deployment{})
+	if !apierrors.IsNotFound(err) {
+		t.Errorf("Expected no Deployment for the over-quota Webserver, got %v", err)
+	}
+	second := &webserverv1alpha1.Webserver{}
+	if err := r.Get(context.Background(), types.NamespacedName{Name: "second", Namespace: "web"}, second); err != nil {
+		t.Fatal(err)
+	}
+	condition := meta.FindStatusCondition(second.Status.Conditions, quotaExceededCondition)
+	if condition == nil || condition.Status != metav1.ConditionTrue || !strings.Contains(condition.Message, `"tenant"`) {
+		t.Errorf("Expected QuotaExceeded=True naming the quota, got %+v", condition)
+	}
+	if second.Status.Phase != "Failed" {
+		t.Errorf("Expected phase Failed, got %s", second.Status.Phase)
+	}
+
+	first := &webserverv1alpha1.Webserver{}
+	if err := r.Get(context.Background(), types.NamespacedName{Name: "first", Namespace: "web"}, first); err != nil {
+		t.Fatal(err)
+	}
+	if !meta.IsStatusConditionFalse(first.Status.Conditions, quotaExceededCondition) {
+		t.Errorf("Expected QuotaExceeded=False on the admitted Webserver, got %v", first.Status.Conditions)
+	}
+}
+
+func TestReconcileKeepsScaleOfWebserverOverLoweredQuota(t *testing.T) {
+	quota := tenantQuota(5, 10)
+	r := newTestReconciler(t, quota, quotaWebserver("first", 3, 0), quotaWebserver("second", 2, 1))
+	reconcileDeployment(t, r, "second")
+
+	quota.Spec.MaxReplicas = ptr.To[int32](4)
+	if err := r.Update(context.Background(), quota); err != nil {
+		t.Fatal(err)
+	}
+	second := &webserverv1alpha1.Webserver{}
+	if err := r.Get(context.Background(), types.NamespacedName{Name: "second", Namespace: "web"}, second); err != nil {
+		t.Fatal(err)
+	}
+	second.Spec.Replicas = 3
+	if err := r.Update(context.Background(), second); err != nil {
+		t.Fatal(err)
+	}
+	deployment := reconcileDeployment(t, r, "second")
+
+	if *deployment.Spec.Replicas != 2 {
+		t.Errorf("Expected the Deployment to stay at 2 replicas, got %d", *deployment.Spec.Replicas)
+	}
+}
+
+func TestWebserverQuotaReconcilerReportsUsage(t *testing.T) {
+	base := newTestReconciler(t, tenantQuota(1, 10), quotaWebserver("first", 3, 0), quotaWebserver("second", 2, 1))
+	r := &WebserverQuotaReconciler{Client: base.Client, Scheme: base.Scheme}
+
+	if _, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: types.NamespacedName{Name: "tenant", Namespace: "web"}}); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+
+	quota := &webserverv1alpha1.WebserverQuota{}
+	if err := r.Get(context.Background(), types.NamespacedName{Name: "tenant", Namespace: "web"}, quota); err != nil {
+		t.Fatal(err)
+	}
+	if quota.Status.Used.Webservers != 1 || quota.Status.Used.Replicas != 3 {
+		t.Errorf("Expected the first Webserver to be charged, got %+v", quota.Status.Used)
+	}
+	if strings.Join(quota.Status.Exceeded, ",") != "second" {
+		t.Errorf("Expected second to exceed the quota, got %v", quota.Status.Exceeded)
+	}
+}
+
+func TestWebserverQuotaValidator(t *testing.T) {
+	existing := quotaWebserver("first", 3, 0)
+	r := newTestReconciler(t, tenantQuota(2, 5), existing)
+	v := &WebserverQuotaValidator{Client: r.Client}
+	ctx := context.Background()
+
+	if _, err := v.ValidateCreate(ctx, quotaWebserver("second", 2, 1)); err != nil {
+		t.Errorf("Expected a Webserver filling the quota to be admitted, got %v", err)
+	}
+	_, err := v.ValidateCreate(ctx, quotaWebserver("second", 3, 1))
+	if !apierrors.IsForbidden(err) || !strings.Contains(err.Error(), "6 replicas") {
+		t.Errorf("Expected a forbidden error for 6 replicas, got %v", err)
+	}
+
+	scaled := existing.DeepCopy()
+	scaled.Spec.Replicas = 6
+	if _, err := v.ValidateUpdate(ctx, existing, scaled); !apierrors.IsForbidden(err) {
+		t.Errorf("Expected scaling beyond the quota to be rejected, got %v", err)
+	}
+	if _, err := v.ValidateUpdate(ctx, scaled, existing); err != nil {
+		t.Errorf("Expected scaling down to be admitted, got %v", err)
+	}
+}
@@ -0,0 +1,101 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	webserverv1alpha1 "github.com/webserver/webserver-operator/api/v1alpha1"
+)
+
+//+kubebuilder:webhook:path=/validate-webserver-io-v1alpha1-webserver,mutating=false,failurePolicy=fail,sideEffects=None,groups=webserver.io,resources=webservers,verbs=create;update,versions=v1alpha1,name=vwebserverquota.webserver.io,admissionReviewVersions=v1
+
+// WebserverQuotaValidator rejects Webservers that would take a namespace
+// over one of its WebserverQuotas
+type WebserverQuotaValidator struct {
+	Client client.Reader
+}
+
+var _ admission.CustomValidator = &WebserverQuotaValidator{}
+
+// SetupWebhookWithManager registers the quota admission webhook with the manager
+func (v *WebserverQuotaValidator) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).
+		For(&webserverv1alpha1.Webserver{}).
+		WithValidator(v).
+		Complete()
+}
+
+// ValidateCreate admits a new Webserver only if it fits every quota
+func (v *WebserverQuotaValidator) ValidateCreate(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	webserver, ok := obj.(*webserverv1alpha1.Webserver)
+	if !ok {
+		return nil, fmt.Errorf("expected a Webserver, got %T", obj)
+	}
+	return nil, v.validate(ctx, webserver)
+}
+
+// ValidateUpdate rejects updates that raise the replicas charged beyond a
+// quota. Other updates are admitted, so Webservers held back after a quota
+// was lowered can still be edited or scaled down.
+func (v *WebserverQuotaValidator) ValidateUpdate(ctx context.Context, oldObj, newObj runtime.Object) (admission.Warnings, error) {
+	oldWebserver, ok := oldObj.(*webserverv1alpha1.Webserver)
+	if !ok {
+		return nil, fmt.Errorf("expected a Webserver, got %T", oldObj)
+	}
+	webserver, ok := newObj.(*webserverv1alpha1.Webserver)
+	if !ok {
+		return nil, fmt.Errorf("expected a Webserver, got %T", newObj)
+	}
+	if quotaReplicas(webserver) <= quotaReplicas(oldWebserver) {
+		return nil, nil
+	}
+	return nil, v.validate(ctx, webserver)
+}
+
+// ValidateDelete always admits deletion
+func (v *WebserverQuotaValidator) ValidateDelete(context.Context, runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+// validate charges the Webserver on top of every other live Webserver of its
+// namespace and rejects it when a quota is exceeded
+func (v *WebserverQuotaValidator) validate(ctx context.Context, webserver *webserverv1alpha1.Webserver) error {
+	quotas := &webserverv1alpha1.WebserverQuotaList{}
+	if err := v.Client.List(ctx, quotas, client.InNamespace(webserver.Namespace)); err != nil {
+		return err
+	}
+	if len(quotas.Items) == 0 {
+		return nil
+	}
+	webservers := &webserverv1alpha1.WebserverList{}
+	if err := v.Client.List(ctx, webservers, client.InNamespace(webserver.Namespace)); err != nil {
+		return err
+	}
+
+	usage := webserverv1alpha1.WebserverQuotaUsage{Webservers: 1, Replicas: quotaReplicas(webserver)}
+	for i := range webservers.Items {
+		other := &webservers.Items[i]
+		if other.Name == webserver.Name || !other.DeletionTimestamp.IsZero() {
+			continue
+		}
+		usage.Webservers++
+		usage.Replicas += quotaReplicas(other)
+	}
+
+	sort.Slice(quotas.Items, func(i, j int) bool { return quotas.Items[i].Name < quotas.Items[j].Name })
+	for i := range quotas.Items {
+		quota := &quotas.Items[i]
+		if !fitsQuota(quota, usage) {
+			return apierrors.NewForbidden(webserverv1alpha1.GroupVersion.WithResource("webservers").GroupResource(), webserver.Name,
+				fmt.Errorf("%d Webservers with %d replicas would exceed %s", usage.Webservers, usage.Replicas, quotaDescription(quota)))
+		}
+	}
+	return nil
+}
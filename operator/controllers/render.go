@@ -0,0 +1,81 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+
+	webserverv1alpha1 "github.com/webserver/webserver-operator/api/v1alpha1"
+)
+
+// Render returns the children the reconciler would apply for the Webserver at
+// time now without changing anything. Images are not resolved to digests and
+// content bundles are not downloaded; the status of the Webserver is used for
+// both instead.
+func (r *WebserverReconciler) Render(ctx context.Context, webserver *webserverv1alpha1.Webserver, now time.Time) ([]client.Object, error) {
+	webserver = webserver.DeepCopy()
+
+	class, err := r.resolveClass(ctx, webserver)
+	if err != nil {
+		return nil, err
+	}
+	r.applyDefaults(webserver, class)
+
+	schedule, err := evaluateSchedule(webserver.Spec.Schedule, webserver.Spec.Replicas, now)
+	if err != nil {
+		return nil, err
+	}
+	webserver.Spec.Replicas = schedule.Replicas
+
+	var violations []string
+	violations = append(violations, classPolicyViolations(class, webserver)...)
+	violations = append(violations, podExtensionViolations(webserver)...)
+	violations = append(violations, localeViolations(webserver)...)
+	if len(violations) > 0 {
+		return nil, fmt.Errorf("webserver would fail: %s", strings.Join(violations, "; "))
+	}
+
+	deployment := &appsv1.Deployment{ObjectMeta: childMeta(webserver, "-deployment")}
+	if err := r.mutateDeployment(deployment, webserver, class); err != nil {
+		return nil, err
+	}
+	configmap := &corev1.ConfigMap{ObjectMeta: childMeta(webserver, "-config")}
+	if err := r.mutateConfigMap(configmap, webserver); err != nil {
+		return nil, err
+	}
+	service := &corev1.Service{ObjectMeta: childMeta(webserver, "-service")}
+	if err := r.mutateService(service, webserver); err != nil {
+		return nil, err
+	}
+	objects := []client.Object{deployment, configmap, service}
+
+	if webserver.Spec.Replicas > 1 {
+		pdb := &policyv1.PodDisruptionBudget{ObjectMeta: childMeta(webserver, "-pdb")}
+		if err := r.mutatePodDisruptionBudget(pdb, webserver); err != nil {
+			return nil, err
+		}
+		objects = append(objects, pdb)
+	}
+
+	for _, obj := range objects {
+		gvk, err := apiutil.GVKForObject(obj, r.Scheme)
+		if err != nil {
+			return nil, err
+		}
+		obj.GetObjectKind().SetGroupVersionKind(gvk)
+	}
+	return objects, nil
+}
+
+// childMeta names a child of the Webserver
+func childMeta(webserver *webserverv1alpha1.Webserver, suffix string) metav1.ObjectMeta {
+	return metav1.ObjectMeta{Name: webserver.Name + suffix, Namespace: webserver.Namespace}
+}
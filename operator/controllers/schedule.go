@@ -0,0 +1,115 @@
+package controllers
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	webserverv1alpha1 "github.com/webserver/webserver-operator/api/v1alpha1"
+)
+
+// cronParser parses standard five-field cron expressions
+var cronParser = cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+
+// scheduleState is the outcome of evaluating a Webserver schedule at one instant
+type scheduleState struct {
+	// Replicas is the effective replica count
+	Replicas int32
+
+	// Active is the open window setting Replicas, nil when none is open
+	Active *webserverv1alpha1.ActiveSchedule
+
+	// NextChange is when a window next opens or closes, zero without windows
+	NextChange time.Time
+}
+
+// parsedWindow is a schedule window with its cron expression parsed
+type parsedWindow struct {
+	window   webserverv1alpha1.ScheduleWindow
+	schedule cron.Schedule
+	location *time.Location
+}
+
+// parseSchedule validates and parses the schedule windows
+func parseSchedule(windows []webserverv1alpha1.ScheduleWindow) ([]parsedWindow, error) {
+	parsed := make([]parsedWindow, 0, len(windows))
+	for _, window := range windows {
+		location := time.UTC
+		if window.TimeZone != "" {
+			loc, err := time.LoadLocation(window.TimeZone)
+			if err != nil {
+				return nil, fmt.Errorf("schedule %q: unknown time zone %q", window.Name, window.TimeZone)
+			}
+			location = loc
+		}
+		schedule, err := cronParser.Parse(window.Cron)
+		if err != nil {
+			return nil, fmt.Errorf("schedule %q: invalid cron expression %q: %v", window.Name, window.Cron, err)
+		}
+		if window.Duration.Duration <= 0 {
+			return nil, fmt.Errorf("schedule %q: duration must be positive", window.Name)
+		}
+		parsed = append(parsed, parsedWindow{window: window, schedule: schedule, location: location})
+	}
+	return parsed, nil
+}
+
+// evaluateSchedule computes the replica count in effect at now. Outside any
+// window the base replica count applies; when windows overlap the one with
+// the most replicas wins, earlier windows breaking ties.
+func evaluateSchedule(windows []webserverv1alpha1.ScheduleWindow, base int32, now time.Time) (scheduleState, error) {
+	parsed, err := parseSchedule(windows)
+	if err != nil {
+		return scheduleState{}, err
+	}
+
+	state := scheduleState{Replicas: base}
+	for _, p := range parsed {
+		local := now.In(p.location)
+		if next := p.schedule.Next(local); state.NextChange.IsZero() || next.Before(state.NextChange) {
+			state.NextChange = next
+		}
+
+		until, open := windowEnd(p, local)
+		if !open {
+			continue
+		}
+		if until.Before(state.NextChange) {
+			state.NextChange = until
+		}
+		if state.Active == nil || p.window.Replicas > state.Active.Replicas {
+			state.Active = &webserverv1alpha1.ActiveSchedule{
+				Name:     p.window.Name,
+				Replicas: p.window.Replicas,
+				Until:    metav1.NewTime(until.UTC()),
+			}
+		}
+	}
+	if state.Active != nil {
+		state.Replicas = state.Active.Replicas
+	}
+	if !state.NextChange.IsZero() {
+		state.NextChange = state.NextChange.UTC()
+	}
+	return state, nil
+}
+
+// windowEnd reports whether the window is open at now and when it closes.
+// A window opened by a later activation while still open is extended.
+func windowEnd(p parsedWindow, now time.Time) (time.Time, bool) {
+	duration := p.window.Duration.Duration
+	start := p.schedule.Next(now.Add(-duration))
+	if start.After(now) {
+		return time.Time{}, false
+	}
+	for {
+		next := p.schedule.Next(start)
+		if next.After(now) || next.IsZero() {
+			break
+		}
+		start = next
+	}
+	return start.Add(duration), true
+}
@@ -0,0 +1,189 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	webserverv1alpha1 "github.com/webserver/webserver-operator/api/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	clocktesting "k8s.io/utils/clock/testing"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+func businessHours(replicas int32) webserverv1alpha1.ScheduleWindow {
+	return webserverv1alpha1.ScheduleWindow{
+		Name:     "business-hours",
+		Cron:     "0 9 * * 1-5",
+		Duration: metav1.Duration{Duration: 9 * time.Hour},
+		TimeZone: "Europe/Berlin",
+		Replicas: replicas,
+	}
+}
+
+func TestEvaluateSchedule(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	windows := []webserverv1alpha1.ScheduleWindow{
+		businessHours(5),
+		{
+			Name:     "launch",
+			Cron:     "0 12 * * 3",
+			Duration: metav1.Duration{Duration: time.Hour},
+			TimeZone: "Europe/Berlin",
+			Replicas: 8,
+		},
+	}
+
+	tests := []struct {
+		name         string
+		now          time.Time
+		wantReplicas int32
+		wantActive   string
+		wantNext     time.Time
+	}{
+		{
+			name:         "before opening",
+			now:          time.Date(2026, 10, 19, 8, 30, 0, 0, berlin),
+			wantReplicas: 1,
+			wantNext:     time.Date(2026, 10, 19, 9, 0, 0, 0, berlin),
+		},
+		{
+			name:         "inside window",
+			now:          time.Date(2026, 10, 19, 10, 0, 0, 0, berlin),
+			wantReplicas: 5,
+			wantActive:   "business-hours",
+			wantNext:     time.Date(2026, 10, 19, 18, 0, 0, 0, berlin),
+		},
+		{
+			name:         "overlap takes most replicas",
+			now:          time.Date(2026, 10, 21, 12, 15, 0, 0, berlin),
+			wantReplicas: 8,
+			wantActive:   "launch",
+			wantNext:     time.Date(2026, 10, 21, 13, 0, 0, 0, berlin),
+		},
+		{
+			name:         "after closing",
+			now:          time.Date(2026, 10, 19, 18, 0, 0, 0, berlin),
+			wantReplicas: 1,
+			wantNext:     time.Date(2026, 10, 20, 9, 0, 0, 0, berlin),
+		},
+		{
+			name:         "weekend",
+			now:          time.Date(2026, 10, 24, 12, 0, 0, 0, berlin),
+			wantReplicas: 1,
+			wantNext:     time.Date(2026, 10, 26, 9, 0, 0, 0, berlin),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state, err := evaluateSchedule(windows, 1, tt.now)
+			if err != nil {
+				t.Fatalf("evaluateSchedule() error = %v", err)
+			}
+			if state.Replicas != tt.wantReplicas {
+				t.Errorf("Expected %d replicas, got %d", tt.wantReplicas, state.Replicas)
+			}
+			active := ""
+			if state.Active != nil {
+				active = state.Active.Name
+			}
+			if active != tt.wantActive {
+				t.Errorf("Expected active schedule %q, got %q", tt.wantActive, active)
+			}
+			if !state.NextChange.Equal(tt.wantNext) {
+				t.Errorf("Expected next change at %s, got %s", tt.wantNext, state.NextChange)
+			}
+		})
+	}
+}
+
+func TestEvaluateScheduleRejectsInvalidWindows(t *testing.T) {
+	tests := []struct {
+		name   string
+		window webserverv1alpha1.ScheduleWindow
+	}{
+		{
+			name:   "bad cron",
+			window: webserverv1alpha1.ScheduleWindow{Name: "w", Cron: "every day", Duration: metav1.Duration{Duration: time.Hour}, Replicas: 2},
+		},
+		{
+			name:   "bad time zone",
+			window: webserverv1alpha1.ScheduleWindow{Name: "w", Cron: "0 9 * * *", TimeZone: "Mars/Olympus", Duration: metav1.Duration{Duration: time.Hour}, Replicas: 2},
+		},
+		{
+			name:   "no duration",
+			window: webserverv1alpha1.ScheduleWindow{Name: "w", Cron: "0 9 * * *", Replicas: 2},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := evaluateSchedule([]webserverv1alpha1.ScheduleWindow{tt.window}, 1, time.Now()); err == nil {
+				t.Error("Expected an error")
+			}
+		})
+	}
+}
+
+func TestReconcileAppliesSchedule(t *testing.T) {
+	webserver := &webserverv1alpha1.Webserver{
+		ObjectMeta: metav1.ObjectMeta{Name: "site", Namespace: "web"},
+		Spec: webserverv1alpha1.WebserverSpec{
+			Replicas: 1,
+			Schedule: []webserverv1alpha1.ScheduleWindow{businessHours(4)},
+		},
+	}
+	r := newTestReconciler(t, webserver)
+	// Monday 17:30 in Berlin, half an hour before the window closes
+	fakeClock := clocktesting.NewFakePassiveClock(time.Date(2026, 10, 19, 15, 30, 0, 0, time.UTC))
+	r.Clock = fakeClock
+	r.ResyncPeriod = time.Hour
+
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: "site", Namespace: "web"}}
+	result, err := r.Reconcile(context.Background(), req)
+	if err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	if result.RequeueAfter != 30*time.Minute {
+		t.Errorf("Expected requeue at the window end in 30m, got %s", result.RequeueAfter)
+	}
+
+	deployment := &appsv1.Deployment{}
+	if err := r.Get(context.Background(), types.NamespacedName{Name: "site-deployment", Namespace: "web"}, deployment); err != nil {
+		t.Fatal(err)
+	}
+	if *deployment.Spec.Replicas != 4 {
+		t.Errorf("Expected 4 scheduled replicas, got %d", *deployment.Spec.Replicas)
+	}
+	updated := &webserverv1alpha1.Webserver{}
+	if err := r.Get(context.Background(), req.NamespacedName, updated); err != nil {
+		t.Fatal(err)
+	}
+	if updated.Status.ActiveSchedule == nil || updated.Status.ActiveSchedule.Name != "business-hours" {
+		t.Errorf("Expected business-hours to be active, got %+v", updated.Status.ActiveSchedule)
+	}
+
+	// Once the window closes the base replica count applies again
+	fakeClock.SetTime(time.Date(2026, 10, 19, 16, 0, 0, 0, time.UTC))
+	if _, err := r.Reconcile(context.Background(), req); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	if err := r.Get(context.Background(), types.NamespacedName{Name: "site-deployment", Namespace: "web"}, deployment); err != nil {
+		t.Fatal(err)
+	}
+	if *deployment.Spec.Replicas != 1 {
+		t.Errorf("Expected base replica count after the window, got %d", *deployment.Spec.Replicas)
+	}
+	if err := r.Get(context.Background(), req.NamespacedName, updated); err != nil {
+		t.Fatal(err)
+	}
+	if updated.Status.ActiveSchedule != nil {
+		t.Errorf("Expected no active schedule, got %+v", updated.Status.ActiveSchedule)
+	}
+}
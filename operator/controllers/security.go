@@ -0,0 +1,155 @@
+package controllers
+
+import (
+	"fmt"
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/utils/ptr"
+
+	webserverv1alpha1 "github.com/webserver/webserver-operator/api/v1alpha1"
+)
+
+const (
+	// defaultPort is an unprivileged port nginx can bind without root
+	defaultPort = 8080
+
+	// unprivilegedPortStartSysctl is the lowest port processes without
+	// NET_BIND_SERVICE may bind in the pod network namespace
+	unprivilegedPortStartSysctl = "net.ipv4.ip_unprivileged_port_start"
+
+	// nginxUID is the uid of the nginx user in the official nginx images
+	nginxUID = 101
+
+	// nginxConfigKey is the ConfigMap key holding the nginx server block
+	nginxConfigKey = "default.conf"
+)
+
+// nginxWritablePaths are the directories nginx writes to at runtime; they are
+// backed by emptyDirs so the root filesystem can stay read-only
+var nginxWritablePaths = []struct {
+	Volume string
+	Path   string
+}{
+	{Volume: "nginx-cache", Path: "/var/cache/nginx"},
+	{Volume: "nginx-run", Path: "/var/run"},
+	{Volume: "tmp", Path: "/tmp"},
+}
+
+// defaultPodSecurityContext satisfies the restricted Pod Security Standard at
+// pod level. A port below 1024 is made bindable without root through the
+// namespaced ip_unprivileged_port_start sysctl, which the standard allows.
+func defaultPodSecurityContext(webserver *webserverv1alpha1.Webserver) *corev1.PodSecurityContext {
+	securityContext := &corev1.PodSecurityContext{
+		RunAsNonRoot:   ptr.To(true),
+		SeccompProfile: &corev1.SeccompProfile{Type: corev1.SeccompProfileTypeRuntimeDefault},
+	}
+	if webserver.Spec.Port < 1024 {
+		securityContext.Sysctls = []corev1.Sysctl{{Name: unprivilegedPortStartSysctl, Value: strconv.Itoa(int(webserver.Spec.Port))}}
+	}
+	return securityContext
+}
+
+// defaultSecurityContext runs the web server container unprivileged with a read-only root filesystem
+func defaultSecurityContext() *corev1.SecurityContext {
+	return &corev1.SecurityContext{
+		RunAsNonRoot:             ptr.To(true),
+		RunAsUser:                ptr.To[int64](nginxUID),
+		RunAsGroup:               ptr.To[int64](nginxUID),
+		AllowPrivilegeEscalation: ptr.To(false),
+		ReadOnlyRootFilesystem:   ptr.To(true),
+		Capabilities:             &corev1.Capabilities{Drop: []corev1.Capability{"ALL"}},
+		SeccompProfile:           &corev1.SeccompProfile{Type: corev1.SeccompProfileTypeRuntimeDefault},
+	}
+}
+
+// containerSecurityContext layers the class and then the Webserver security
+// context over the hardened defaults; every field set in an override wins
+func containerSecurityContext(webserver *webserverv1alpha1.Webserver, class *webserverv1alpha1.WebserverClass) *corev1.SecurityContext {
+	securityContext := defaultSecurityContext()
+	if class != nil {
+		mergeSecurityContext(securityContext, class.Spec.SecurityContext)
+	}
+	mergeSecurityContext(securityContext, webserver.Spec.SecurityContext)
+	return securityContext
+}
+
+// mergeSecurityContext copies the fields set in override into dst
+func mergeSecurityContext(dst, override *corev1.SecurityContext) {
+	if override == nil {
+		return
+	}
+	override = override.DeepCopy()
+	if override.Capabilities != nil {
+		dst.Capabilities = override.Capabilities
+	}
+	if override.Privileged != nil {
+		dst.Privileged = override.Privileged
+	}
+	if override.SELinuxOptions != nil {
+		dst.SELinuxOptions = override.SELinuxOptions
+	}
+	if override.WindowsOptions != nil {
+		dst.WindowsOptions = override.WindowsOptions
+	}
+	if override.RunAsUser != nil {
+		dst.RunAsUser = override.RunAsUser
+	}
+	if override.RunAsGroup != nil {
+		dst.RunAsGroup = override.RunAsGroup
+	}
+	if override.RunAsNonRoot != nil {
+		dst.RunAsNonRoot = override.RunAsNonRoot
+	}
+	if override.ReadOnlyRootFilesystem != nil {
+		dst.ReadOnlyRootFilesystem = override.ReadOnlyRootFilesystem
+	}
+	if override.AllowPrivilegeEscalation != nil {
+		dst.AllowPrivilegeEscalation = override.AllowPrivilegeEscalation
+	}
+	if override.ProcMount != nil {
+		dst.ProcMount = override.ProcMount
+	}
+	if override.SeccompProfile != nil {
+		dst.SeccompProfile = override.SeccompProfile
+	}
+	if override.AppArmorProfile != nil {
+		dst.AppArmorProfile = override.AppArmorProfile
+	}
+}
+
+// nginxServerConfig renders the nginx server block listening on the Webserver
+// port. With metrics enabled it also serves stub_status to the exporter sidecar.
+func nginxServerConfig(webserver *webserverv1alpha1.Webserver) string {
+	stubStatus := ""
+	if metricsEnabled(webserver) {
+		stubStatus = fmt.Sprintf(`
+    location = %s {
+        stub_status;
+        allow  127.0.0.1;
+        deny   all;
+    }
+`, stubStatusPath)
+	}
+	localeMap, localeRoot := "", ""
+	if len(webserver.Spec.Config.Locales) > 0 {
+		localeMap = nginxLocaleMap(webserver)
+		localeRoot = `
+    location = / {
+        root   /usr/share/nginx/html;
+        add_header  Vary Accept-Language;
+        try_files  $webserver_locale_page /index.html;
+    }
+`
+	}
+	return fmt.Sprintf(`%sserver {
+    listen       %d;
+    server_name  localhost;
+
+    location / {
+        root   /usr/share/nginx/html;
+        index  index.html;
+    }
+%s%s}
+`, localeMap, webserver.Spec.Port, localeRoot, stubStatus)
+}
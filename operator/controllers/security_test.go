@@ -0,0 +1,147 @@
+package controllers
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	webserverv1alpha1 "github.com/webserver/webserver-operator/api/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/pod-security-admission/api"
+	"k8s.io/pod-security-admission/policy"
+	"k8s.io/utils/ptr"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// restrictedViolations evaluates a pod template against the restricted Pod Security Standard
+func restrictedViolations(t *testing.T, template *corev1.PodTemplateSpec) []string {
+	t.Helper()
+	evaluator, err := policy.NewEvaluator(policy.DefaultChecks())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var violations []string
+	for _, result := range evaluator.EvaluatePod(api.LevelVersion{Level: api.LevelRestricted, Version: api.LatestVersion()}, &template.ObjectMeta, &template.Spec) {
+		if !result.Allowed {
+			violations = append(violations, result.ForbiddenReason+": "+result.ForbiddenDetail)
+		}
+	}
+	return violations
+}
+
+func reconcileDeployment(t *testing.T, r *WebserverReconciler, name string) *appsv1.Deployment {
+	t.Helper()
+	if _, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: types.NamespacedName{Name: name, Namespace: "web"}}); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	deployment := &appsv1.Deployment{}
+	if err := r.Get(context.Background(), types.NamespacedName{Name: name + "-deployment", Namespace: "web"}, deployment); err != nil {
+		t.Fatal(err)
+	}
+	return deployment
+}
+
+func TestDeploymentMeetsRestrictedProfile(t *testing.T) {
+	webserver := &webserverv1alpha1.Webserver{ObjectMeta: metav1.ObjectMeta{Name: "site", Namespace: "web"}}
+	r := newTestReconciler(t, webserver)
+
+	deployment := reconcileDeployment(t, r, "site")
+
+	if violations := restrictedViolations(t, &deployment.Spec.Template); len(violations) > 0 {
+		t.Errorf("Expected the pod to meet the restricted profile, got %v", violations)
+	}
+	container := deployment.Spec.Template.Spec.Containers[0]
+	if container.Ports[0].ContainerPort != defaultPort {
+		t.Errorf("Expected unprivileged container port %d, got %d", defaultPort, container.Ports[0].ContainerPort)
+	}
+	if !*container.SecurityContext.ReadOnlyRootFilesystem {
+		t.Error("Expected a read-only root filesystem")
+	}
+	mounts := map[string]bool{}
+	for _, mount := range container.VolumeMounts {
+		mounts[mount.MountPath] = true
+	}
+	for _, path := range []string{"/var/cache/nginx", "/var/run", "/tmp", "/etc/nginx/conf.d"} {
+		if !mounts[path] {
+			t.Errorf("Expected a volume mounted at %s, got %v", path, container.VolumeMounts)
+		}
+	}
+
+	configmap := &corev1.ConfigMap{}
+	if err := r.Get(context.Background(), types.NamespacedName{Name: "site-config", Namespace: "web"}, configmap); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(configmap.Data[nginxConfigKey], "listen       8080;") {
+		t.Errorf("Expected nginx to listen on 8080, got %q", configmap.Data[nginxConfigKey])
+	}
+}
+
+func TestSecurityContextOverrides(t *testing.T) {
+	class := &webserverv1alpha1.WebserverClass{
+		ObjectMeta: metav1.ObjectMeta{Name: "standard"},
+		Spec: webserverv1alpha1.WebserverClassSpec{
+			SecurityContext: &corev1.SecurityContext{RunAsUser: ptr.To[int64](2000)},
+		},
+	}
+	webserver := &webserverv1alpha1.Webserver{
+		ObjectMeta: metav1.ObjectMeta{Name: "site", Namespace: "web"},
+		Spec: webserverv1alpha1.WebserverSpec{
+			ClassName:       "standard",
+			SecurityContext: &corev1.SecurityContext{ReadOnlyRootFilesystem: ptr.To(false)},
+		},
+	}
+	r := newTestReconciler(t, class, webserver)
+
+	deployment := reconcileDeployment(t, r, "site")
+
+	securityContext := deployment.Spec.Template.Spec.Containers[0].SecurityContext
+	if *securityContext.ReadOnlyRootFilesystem {
+		t.Error("Expected spec.securityContext to make the root filesystem writable")
+	}
+	if *securityContext.RunAsUser != 2000 {
+		t.Errorf("Expected the class to set the user, got %d", *securityContext.RunAsUser)
+	}
+	if securityContext.Capabilities == nil || len(securityContext.Capabilities.Drop) != 1 {
+		t.Errorf("Expected untouched defaults to remain, got %+v", securityContext.Capabilities)
+	}
+	if violations := restrictedViolations(t, &deployment.Spec.Template); len(violations) > 0 {
+		t.Errorf("Expected the pod to still meet the restricted profile, got %v", violations)
+	}
+}
+
+func TestReconcileAllowsPrivilegedPort(t *testing.T) {
+	// Webservers created before the restricted defaults commonly listen on port 80
+	webserver := &webserverv1alpha1.Webserver{
+		ObjectMeta: metav1.ObjectMeta{Name: "site", Namespace: "web"},
+		Spec:       webserverv1alpha1.WebserverSpec{Port: 80},
+	}
+	r := newTestReconciler(t, webserver)
+
+	deployment := reconcileDeployment(t, r, "site")
+
+	updated := &webserverv1alpha1.Webserver{}
+	if err := r.Get(context.Background(), types.NamespacedName{Name: "site", Namespace: "web"}, updated); err != nil {
+		t.Fatal(err)
+	}
+	if updated.Status.Phase != "Ready" {
+		t.Errorf("Expected a Webserver on port 80 to keep reconciling, got phase %q and %v", updated.Status.Phase, updated.Status.Conditions)
+	}
+	pod := deployment.Spec.Template.Spec
+	if pod.Containers[0].Ports[0].ContainerPort != 80 || !*pod.Containers[0].SecurityContext.RunAsNonRoot {
+		t.Errorf("Expected nginx to listen on port 80 as non-root, got %+v", pod.Containers[0])
+	}
+	if len(pod.SecurityContext.Sysctls) != 1 || pod.SecurityContext.Sysctls[0] != (corev1.Sysctl{Name: unprivilegedPortStartSysctl, Value: "80"}) {
+		t.Errorf("Expected port 80 to be made unprivileged, got %v", pod.SecurityContext.Sysctls)
+	}
+	if violations := restrictedViolations(t, &deployment.Spec.Template); len(violations) > 0 {
+		t.Errorf("Expected the pod to still meet the restricted profile, got %v", violations)
+	}
+
+	// Unprivileged ports leave the sysctl alone
+	if sysctls := defaultPodSecurityContext(&webserverv1alpha1.Webserver{Spec: webserverv1alpha1.WebserverSpec{Port: 8080}}).Sysctls; len(sysctls) != 0 {
+		t.Errorf("Expected no sysctl for port 8080, got %v", sysctls)
+	}
+}
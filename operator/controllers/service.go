@@ -0,0 +1,205 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	webserverv1alpha1 "github.com/webserver/webserver-operator/api/v1alpha1"
+)
+
+//+kubebuilder:rbac:groups=core,resources=events,verbs=create;patch
+
+const (
+	// serviceAnnotationsAnnotation lists the Service annotations set from the spec
+	serviceAnnotationsAnnotation = "webserver.io/service-annotations"
+
+	// serviceRecreateRetry is how soon a Service is created again while its predecessor is deleted
+	serviceRecreateRetry = 2 * time.Second
+)
+
+// serviceViolations lists the spec.service options that do not apply to the
+// Service type and the ports targeting a container port no container exposes
+func serviceViolations(webserver *webserverv1alpha1.Webserver) []string {
+	options := webserver.Spec.Service
+	if options == nil {
+		return nil
+	}
+	serviceType := corev1.ServiceType(webserver.Spec.ServiceType)
+
+	var violations []string
+	if options.Headless && serviceType != corev1.ServiceTypeClusterIP {
+		violations = append(violations, fmt.Sprintf("a headless Service must be of type ClusterIP, not %s", serviceType))
+	}
+	exposed := containerPortNames(webserver)
+	for _, port := range options.Ports {
+		if port.NodePort != 0 && serviceType == corev1.ServiceTypeClusterIP {
+			violations = append(violations, fmt.Sprintf("port %q sets a nodePort, which needs a NodePort or LoadBalancer Service", port.Name))
+		}
+		target := port.Name
+		if port.TargetPort != nil {
+			target = port.TargetPort.StrVal
+			if port.TargetPort.Type == intstr.Int {
+				continue
+			}
+		}
+		if !exposed[target] {
+			violations = append(violations, fmt.Sprintf("port %q targets the container port %q, which no container exposes; set a numeric targetPort", port.Name, target))
+		}
+	}
+	if len(options.LoadBalancerSourceRanges) > 0 && serviceType != corev1.ServiceTypeLoadBalancer {
+		violations = append(violations, "loadBalancerSourceRanges needs a LoadBalancer Service")
+	}
+	for _, cidr := range options.LoadBalancerSourceRanges {
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			violations = append(violations, fmt.Sprintf("loadBalancerSourceRanges entry %q is not a CIDR", cidr))
+		}
+	}
+	if options.ExternalTrafficPolicy != "" && serviceType == corev1.ServiceTypeClusterIP {
+		violations = append(violations, "externalTrafficPolicy needs a NodePort or LoadBalancer Service")
+	}
+	return violations
+}
+
+// containerPortNames returns the names of the container ports of the pod: the
+// web server http port, the metrics exporter port and those of extra containers
+func containerPortNames(webserver *webserverv1alpha1.Webserver) map[string]bool {
+	names := map[string]bool{"http": true}
+	if metricsEnabled(webserver) {
+		names["metrics"] = true
+	}
+	for _, container := range webserver.Spec.ExtraContainers {
+		for _, port := range container.Ports {
+			if port.Name != "" {
+				names[port.Name] = true
+			}
+		}
+	}
+	return names
+}
+
+// servicePorts returns the ports of the Service: spec.service.ports or a
+// single http port 80, plus the metrics port while metrics are enabled. Node
+// ports the API server allocated to existing ports are kept.
+func servicePorts(webserver *webserverv1alpha1.Webserver, existing []corev1.ServicePort) []corev1.ServicePort {
+	allocated := map[string]int32{}
+	if webserver.Spec.ServiceType != string(corev1.ServiceTypeClusterIP) {
+		for _, port := range existing {
+			allocated[port.Name] = port.NodePort
+		}
+	}
+
+	specs := []webserverv1alpha1.ServicePort{{Name: "http", Port: 80}}
+	if webserver.Spec.Service != nil && len(webserver.Spec.Service.Ports) > 0 {
+		specs = webserver.Spec.Service.Ports
+	}
+
+	var ports []corev1.ServicePort
+	hasMetrics := false
+	for _, spec := range specs {
+		port := corev1.ServicePort{
+			Name:       spec.Name,
+			Protocol:   corev1.ProtocolTCP,
+			Port:       spec.Port,
+			TargetPort: intstr.FromString(spec.Name),
+			NodePort:   spec.NodePort,
+		}
+		if spec.Name == "http" {
+			port.TargetPort = intstr.FromInt32(webserver.Spec.Port)
+		}
+		if spec.TargetPort != nil {
+			port.TargetPort = *spec.TargetPort
+		}
+		if port.NodePort == 0 {
+			port.NodePort = allocated[spec.Name]
+		}
+		hasMetrics = hasMetrics || spec.Name == "metrics"
+		ports = append(ports, port)
+	}
+	if metricsEnabled(webserver) && !hasMetrics {
+		port := metricsServicePort()
+		port.Protocol = corev1.ProtocolTCP
+		port.NodePort = allocated[port.Name]
+		ports = append(ports, port)
+	}
+	return ports
+}
+
+// setServiceAnnotations applies spec.commonAnnotations overridden by
+// spec.service.annotations and removes the ones an earlier spec set, leaving
+// annotations of other controllers alone
+func setServiceAnnotations(service *corev1.Service, webserver *webserverv1alpha1.Webserver) {
+	desired := map[string]string{}
+	for key, value := range webserver.Spec.CommonAnnotations {
+		desired[key] = value
+	}
+	if webserver.Spec.Service != nil {
+		for key, value := range webserver.Spec.Service.Annotations {
+			desired[key] = value
+		}
+	}
+	setTrackedAnnotations(service, serviceAnnotationsAnnotation, desired)
+}
+
+// reconcileService creates or updates the Service. A Service whose cluster IP
+// has to change is deleted first, guarded by its UID and resource version so
+// a newer object is never removed. It returns nil while the old Service is
+// still being deleted.
+func (r *WebserverReconciler) reconcileService(ctx context.Context, webserver *webserverv1alpha1.Webserver) (*corev1.Service, error) {
+	log := log.FromContext(ctx)
+	service := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      webserver.Name + "-service",
+			Namespace: webserver.Namespace,
+		},
+	}
+
+	existing := &corev1.Service{}
+	err := r.Get(ctx, client.ObjectKeyFromObject(service), existing)
+	switch {
+	case errors.IsNotFound(err):
+	case err != nil:
+		return nil, err
+	case !existing.DeletionTimestamp.IsZero():
+		return nil, nil
+	case metav1.IsControlledBy(existing, webserver) && serviceNeedsRecreate(existing, webserver):
+		if err := r.Delete(ctx, existing, client.Preconditions{UID: &existing.UID, ResourceVersion: &existing.ResourceVersion}); client.IgnoreNotFound(err) != nil {
+			return nil, err
+		}
+		log.Info("Recreating Service to change its cluster IP", "service", existing.Name)
+		r.event(webserver, corev1.EventTypeNormal, "ServiceRecreated",
+			fmt.Sprintf("Recreated Service %s to change the immutable field spec.clusterIP", existing.Name))
+		if err := r.Get(ctx, client.ObjectKeyFromObject(service), existing); err == nil {
+			return nil, nil
+		} else if !errors.IsNotFound(err) {
+			return nil, err
+		}
+	}
+
+	op, err := r.createOrUpdate(ctx, "mutateService", webserver, service, func() error {
+		return r.mutateService(service, webserver)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if op != controllerutil.OperationResultNone {
+		log.Info("Service operation", "operation", op)
+	}
+	return service, nil
+}
+
+// serviceNeedsRecreate reports whether the Service must be replaced because
+// it is headless and should not be, or the other way round
+func serviceNeedsRecreate(service *corev1.Service, webserver *webserverv1alpha1.Webserver) bool {
+	headless := webserver.Spec.Service != nil && webserver.Spec.Service.Headless
+	return headless != (service.Spec.ClusterIP == corev1.ClusterIPNone)
+}
@@ -0,0 +1,218 @@
+package controllers
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	webserverv1alpha1 "github.com/webserver/webserver-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/ptr"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+func getService(t *testing.T, r *WebserverReconciler) *corev1.Service {
+	t.Helper()
+	service := &corev1.Service{}
+	if err := r.Get(context.Background(), types.NamespacedName{Name: "site-service", Namespace: "web"}, service); err != nil {
+		t.Fatal(err)
+	}
+	return service
+}
+
+func TestServiceOptions(t *testing.T) {
+	webserver := &webserverv1alpha1.Webserver{
+		ObjectMeta: metav1.ObjectMeta{Name: "site", Namespace: "web"},
+		Spec: webserverv1alpha1.WebserverSpec{
+			Port:        8080,
+			ServiceType: "LoadBalancer",
+			Config:      webserverv1alpha1.WebserverConfig{Features: map[string]bool{"metrics": true}},
+			Service: &webserverv1alpha1.ServiceSpec{
+				Ports: []webserverv1alpha1.ServicePort{
+					{Name: "http", Port: 80, NodePort: 30080},
+					{Name: "https", Port: 443, TargetPort: ptr.To(intstr.FromInt32(8443))},
+				},
+				Annotations:              map[string]string{"service.beta.kubernetes.io/aws-load-balancer-type": "nlb"},
+				LoadBalancerSourceRanges: []string{"10.0.0.0/8"},
+				ExternalTrafficPolicy:    corev1.ServiceExternalTrafficPolicyLocal,
+				SessionAffinity:          corev1.ServiceAffinityClientIP,
+			},
+		},
+	}
+	r := newTestReconciler(t, webserver)
+
+	reconcileDeployment(t, r, "site")
+
+	service := getService(t, r)
+	var ports []string
+	for _, port := range service.Spec.Ports {
+		ports = append(ports, port.Name+":"+port.TargetPort.String())
+	}
+	if strings.Join(ports, ",") != "http:8080,https:8443,metrics:metrics" {
+		t.Errorf("Expected http, https and metrics ports, got %v", ports)
+	}
+	if service.Spec.Ports[0].NodePort != 30080 {
+		t.Errorf("Expected the fixed node port 30080, got %d", service.Spec.Ports[0].NodePort)
+	}
+	if service.Annotations["service.beta.kubernetes.io/aws-load-balancer-type"] != "nlb" {
+		t.Errorf("Expected the load balancer annotation, got %v", service.Annotations)
+	}
+	if strings.Join(service.Spec.LoadBalancerSourceRanges, ",") != "10.0.0.0/8" {
+		t.Errorf("Expected the source ranges, got %v", service.Spec.LoadBalancerSourceRanges)
+	}
+	if service.Spec.ExternalTrafficPolicy != corev1.ServiceExternalTrafficPolicyLocal || service.Spec.SessionAffinity != corev1.ServiceAffinityClientIP {
+		t.Errorf("Expected Local traffic policy and ClientIP affinity, got %s and %s",
+			service.Spec.ExternalTrafficPolicy, service.Spec.SessionAffinity)
+	}
+}
+
+func TestServiceKeepsAllocatedValues(t *testing.T) {
+	webserver := &webserverv1alpha1.Webserver{
+		ObjectMeta: metav1.ObjectMeta{Name: "site", Namespace: "web"},
+		Spec: webserverv1alpha1.WebserverSpec{
+			ServiceType: "NodePort",
+			Service: &webserverv1alpha1.ServiceSpec{
+				Annotations: map[string]string{"example.com/old": "x"},
+			},
+		},
+	}
+	r := newTestReconciler(t, webserver)
+	reconcileDeployment(t, r, "site")
+
+	// Simulate the API server allocating values and another controller annotating the Service
+	service := getService(t, r)
+	service.Spec.ClusterIP = "10.96.0.10"
+	service.Spec.Ports[0].NodePort = 31234
+	service.Annotations["example.com/foreign"] = "y"
+	if err := r.Update(context.Background(), service); err != nil {
+		t.Fatal(err)
+	}
+	current := &webserverv1alpha1.Webserver{}
+	if err := r.Get(context.Background(), types.NamespacedName{Name: "site", Namespace: "web"}, current); err != nil {
+		t.Fatal(err)
+	}
+	current.Spec.Service.Annotations = map[string]string{"example.com/new": "z"}
+	if err := r.Update(context.Background(), current); err != nil {
+		t.Fatal(err)
+	}
+	reconcileDeployment(t, r, "site")
+
+	service = getService(t, r)
+	if service.Spec.ClusterIP != "10.96.0.10" || service.Spec.Ports[0].NodePort != 31234 {
+		t.Errorf("Expected the allocated cluster IP and node port to be kept, got %s and %d", service.Spec.ClusterIP, service.Spec.Ports[0].NodePort)
+	}
+	if _, ok := service.Annotations["example.com/old"]; ok {
+		t.Errorf("Expected the annotation dropped from the spec to be removed, got %v", service.Annotations)
+	}
+	if service.Annotations["example.com/new"] != "z" || service.Annotations["example.com/foreign"] != "y" {
+		t.Errorf("Expected the new and the foreign annotation, got %v", service.Annotations)
+	}
+}
+
+func TestHeadlessServiceRecreated(t *testing.T) {
+	webserver := &webserverv1alpha1.Webserver{
+		ObjectMeta: metav1.ObjectMeta{Name: "site", Namespace: "web"},
+		Spec:       webserverv1alpha1.WebserverSpec{Port: 8080},
+	}
+	r := newTestReconciler(t, webserver)
+	recorder := record.NewFakeRecorder(10)
+	r.Recorder = recorder
+	reconcileDeployment(t, r, "site")
+	old := getService(t, r)
+	old.Annotations = map[string]string{"example.com/generation": "old"}
+	if err := r.Update(context.Background(), old); err != nil {
+		t.Fatal(err)
+	}
+
+	current := &webserverv1alpha1.Webserver{}
+	if err := r.Get(context.Background(), types.NamespacedName{Name: "site", Namespace: "web"}, current); err != nil {
+		t.Fatal(err)
+	}
+	current.Spec.Service = &webserverv1alpha1.ServiceSpec{Headless: true}
+	if err := r.Update(context.Background(), current); err != nil {
+		t.Fatal(err)
+	}
+	reconcileDeployment(t, r, "site")
+
+	service := getService(t, r)
+	if service.Spec.ClusterIP != corev1.ClusterIPNone || service.Annotations["example.com/generation"] == "old" {
+		t.Errorf("Expected a new headless Service, got cluster IP %q and annotations %v", service.Spec.ClusterIP, service.Annotations)
+	}
+	select {
+	case event := <-recorder.Events:
+		if !strings.Contains(event, "ServiceRecreated") {
+			t.Errorf("Expected a ServiceRecreated event, got %q", event)
+		}
+	default:
+		t.Error("Expected a ServiceRecreated event")
+	}
+
+	if err := r.Get(context.Background(), types.NamespacedName{Name: "site", Namespace: "web"}, current); err != nil {
+		t.Fatal(err)
+	}
+	if current.Status.InternalURL != "http://site-service.web.svc:8080" {
+		t.Errorf("Expected the internal URL to use the pod port, got %s", current.Status.InternalURL)
+	}
+}
+
+func TestServiceViolations(t *testing.T) {
+	webserver := &webserverv1alpha1.Webserver{
+		ObjectMeta: metav1.ObjectMeta{Name: "site", Namespace: "web"},
+		Spec: webserverv1alpha1.WebserverSpec{
+			Service: &webserverv1alpha1.ServiceSpec{
+				Ports:                    []webserverv1alpha1.ServicePort{{Name: "http", Port: 80, NodePort: 30080}},
+				LoadBalancerSourceRanges: []string{"10.0.0.0/8"},
+			},
+		},
+	}
+	r := newTestReconciler(t, webserver)
+
+	if _, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: types.NamespacedName{Name: "site", Namespace: "web"}}); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+
+	current := &webserverv1alpha1.Webserver{}
+	if err := r.Get(context.Background(), types.NamespacedName{Name: "site", Namespace: "web"}, current); err != nil {
+		t.Fatal(err)
+	}
+	if current.Status.Phase != "Failed" || len(current.Status.Conditions) == 0 || current.Status.Conditions[0].Reason != "InvalidService" {
+		t.Fatalf("Expected the Webserver to fail with InvalidService, got %s %v", current.Status.Phase, current.Status.Conditions)
+	}
+	message := current.Status.Conditions[0].Message
+	if !strings.Contains(message, "nodePort") || !strings.Contains(message, "loadBalancerSourceRanges") {
+		t.Errorf("Expected both violations in the message, got %q", message)
+	}
+}
+
+func TestServiceNamedTargetPorts(t *testing.T) {
+	sidecar := corev1.Container{Name: "tls-proxy", Ports: []corev1.ContainerPort{{Name: "https", ContainerPort: 8443}}}
+	tests := []struct {
+		port       webserverv1alpha1.ServicePort
+		containers []corev1.Container
+		valid      bool
+	}{
+		{webserverv1alpha1.ServicePort{Name: "http", Port: 80}, nil, true},
+		{webserverv1alpha1.ServicePort{Name: "https", Port: 443}, nil, false},
+		{webserverv1alpha1.ServicePort{Name: "https", Port: 443}, []corev1.Container{sidecar}, true},
+		{webserverv1alpha1.ServicePort{Name: "tls", Port: 443, TargetPort: ptr.To(intstr.FromString("https"))}, []corev1.Container{sidecar}, true},
+		{webserverv1alpha1.ServicePort{Name: "https", Port: 443, TargetPort: ptr.To(intstr.FromInt32(8443))}, nil, true},
+		{webserverv1alpha1.ServicePort{Name: "metrics", Port: 9113}, nil, false},
+	}
+	for _, tt := range tests {
+		webserver := &webserverv1alpha1.Webserver{Spec: webserverv1alpha1.WebserverSpec{
+			ExtraContainers: tt.containers,
+			Service:         &webserverv1alpha1.ServiceSpec{Ports: []webserverv1alpha1.ServicePort{tt.port}},
+		}}
+		violations := serviceViolations(webserver)
+		if tt.valid && len(violations) > 0 {
+			t.Errorf("Expected port %+v with %d extra containers to be valid, got %v", tt.port, len(tt.containers), violations)
+		}
+		if !tt.valid && (len(violations) != 1 || !strings.Contains(violations[0], "no container exposes")) {
+			t.Errorf("Expected port %+v with %d extra containers to be rejected, got %v", tt.port, len(tt.containers), violations)
+		}
+	}
+}
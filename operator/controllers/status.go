@@ -0,0 +1,63 @@
+package controllers
+
+import (
+	"context"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	webserverv1alpha1 "github.com/webserver/webserver-operator/api/v1alpha1"
+)
+
+// Results of a status write, reported in the result label of statusWrites
+const (
+	statusPatched   = "patched"
+	statusUnchanged = "unchanged"
+	statusFailed    = "failed"
+)
+
+// statusWrites counts the Webserver status writes by result
+var statusWrites = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "webserver_status_writes_total",
+	Help: "Webserver status writes by result: patched, unchanged (skipped) or failed.",
+}, []string{"result"})
+
+func init() {
+	metrics.Registry.MustRegister(statusWrites)
+}
+
+// writeStatus persists the Webserver status in a span. The status is sent as
+// a merge patch against the stored object, so only changed fields are written
+// and concurrent writers of other fields do not conflict; nothing is written
+// when the status is unchanged. original tracks the stored status afterwards.
+func (r *WebserverReconciler) writeStatus(ctx context.Context, original, webserver *webserverv1alpha1.Webserver) (err error) {
+	result := statusUnchanged
+	ctx, span := r.tracer().Start(ctx, "updateStatus", trace.WithAttributes(webserverAttributes(webserver)...))
+	defer func() {
+		if err != nil {
+			result = statusFailed
+		}
+		statusWrites.WithLabelValues(result).Inc()
+		span.SetAttributes(attribute.String("webserver.phase", webserver.Status.Phase), attrResult.String(result))
+		endSpan(span, err)
+	}()
+
+	if equality.Semantic.DeepEqual(original.Status, webserver.Status) {
+		return nil
+	}
+	// Patch a copy of the stored object so the defaulted spec stays out of the patch
+	patched := original.DeepCopy()
+	patched.Status = *webserver.Status.DeepCopy()
+	if err := r.Status().Patch(ctx, patched, client.MergeFrom(original)); err != nil {
+		return err
+	}
+	result = statusPatched
+	webserver.ResourceVersion = patched.ResourceVersion
+	original.Status = *webserver.Status.DeepCopy()
+	original.ResourceVersion = patched.ResourceVersion
+	return nil
+}
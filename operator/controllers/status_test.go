@@ -0,0 +1,98 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	webserverv1alpha1 "github.com/webserver/webserver-operator/api/v1alpha1"
+)
+
+func TestStatusWriteSkippedWhenUnchanged(t *testing.T) {
+	r := newTestReconciler(t, &webserverv1alpha1.Webserver{ObjectMeta: metav1.ObjectMeta{Name: "site", Namespace: "web"}})
+	verifier := &stubVerifier{}
+	r.Verifier = verifier
+	ctx := context.Background()
+	key := types.NamespacedName{Name: "site", Namespace: "web"}
+
+	// Settle a verified Webserver as in a default deployment
+	if _, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: key}); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	completeRollout(t, r)
+	if _, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: key}); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	first := &webserverv1alpha1.Webserver{}
+	if err := r.Get(ctx, key, first); err != nil {
+		t.Fatal(err)
+	}
+	if first.Status.Phase != "Ready" {
+		t.Fatalf("Expected the status to be written, got phase %q", first.Status.Phase)
+	}
+	if first.Status.ContentVerification == nil {
+		t.Fatalf("Expected the content to be verified, got %+v", first.Status)
+	}
+	if first.Spec.Replicas != 0 || first.Spec.Image != "" {
+		t.Errorf("Expected the defaults to stay out of the stored spec, got %+v", first.Spec)
+	}
+
+	patched := testutil.ToFloat64(statusWrites.WithLabelValues(statusPatched))
+	unchanged := testutil.ToFloat64(statusWrites.WithLabelValues(statusUnchanged))
+	if _, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: key}); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	second := &webserverv1alpha1.Webserver{}
+	if err := r.Get(ctx, key, second); err != nil {
+		t.Fatal(err)
+	}
+	if second.ResourceVersion != first.ResourceVersion {
+		t.Errorf("Expected no write for an unchanged status, resourceVersion went from %s to %s", first.ResourceVersion, second.ResourceVersion)
+	}
+	if verifier.calls != 2 {
+		t.Errorf("Expected the content to be checked again, got %d fetches", verifier.calls)
+	}
+	if got := testutil.ToFloat64(statusWrites.WithLabelValues(statusPatched)); got != patched {
+		t.Errorf("Expected no patch to be counted, got %v more", got-patched)
+	}
+	if got := testutil.ToFloat64(statusWrites.WithLabelValues(statusUnchanged)); got != unchanged+1 {
+		t.Errorf("Expected one skipped write to be counted, got %v more", got-unchanged)
+	}
+}
+
+func TestStatusPatchIgnoresStaleObject(t *testing.T) {
+	r := newTestReconciler(t, &webserverv1alpha1.Webserver{ObjectMeta: metav1.ObjectMeta{Name: "site", Namespace: "web"}})
+	ctx := context.Background()
+	key := types.NamespacedName{Name: "site", Namespace: "web"}
+
+	original := &webserverv1alpha1.Webserver{}
+	if err := r.Get(ctx, key, original); err != nil {
+		t.Fatal(err)
+	}
+	// Another writer changes the Webserver after it was read
+	current := original.DeepCopy()
+	current.Labels = map[string]string{"team": "web"}
+	if err := r.Update(ctx, current); err != nil {
+		t.Fatal(err)
+	}
+
+	webserver := original.DeepCopy()
+	webserver.Spec.Replicas = 3
+	webserver.Status.Phase = "Ready"
+	if err := r.writeStatus(ctx, original, webserver); err != nil {
+		t.Fatalf("Expected the patch not to conflict, got %v", err)
+	}
+	if err := r.Get(ctx, key, current); err != nil {
+		t.Fatal(err)
+	}
+	if current.Status.Phase != "Ready" || current.Labels["team"] != "web" || current.Spec.Replicas != 0 {
+		t.Errorf("Expected only the status to be patched, got %+v", current)
+	}
+	if original.Status.Phase != "Ready" {
+		t.Errorf("Expected the original to track the written status, got %q", original.Status.Phase)
+	}
+}
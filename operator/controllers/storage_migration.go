@@ -0,0 +1,132 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+//+kubebuilder:rbac:groups=apiextensions.k8s.io,resources=customresourcedefinitions,verbs=get;list;watch
+//+kubebuilder:rbac:groups=apiextensions.k8s.io,resources=customresourcedefinitions/status,verbs=update;patch
+
+// StorageVersionMigrator rewrites every object of a CRD in the current
+// storage version and then drops older versions from the CRD's
+// status.storedVersions, so they can later be removed from the CRD. It runs
+// once when the manager starts and only on the leader. Failed attempts are
+// retried with backoff until the manager stops; they never stop the manager.
+type StorageVersionMigrator struct {
+	// Client writes the migrated objects and the CRD status
+	Client client.Client
+
+	// Reader lists objects directly from the API server
+	Reader client.Reader
+
+	// CRDName is the name of the CustomResourceDefinition to migrate
+	CRDName string
+
+	// RetryPeriod is the first delay after a failed attempt; it doubles up to
+	// maxMigrationRetryPeriod and defaults to one second
+	RetryPeriod time.Duration
+}
+
+// maxMigrationRetryPeriod caps the delay between failed migration attempts
+const maxMigrationRetryPeriod = 5 * time.Minute
+
+// Start migrates the stored objects. It implements manager.Runnable.
+func (m *StorageVersionMigrator) Start(ctx context.Context) error {
+	log := log.FromContext(ctx).WithValues("crd", m.CRDName)
+
+	backoff := wait.Backoff{Duration: m.RetryPeriod, Factor: 2, Jitter: 0.1, Steps: math.MaxInt32, Cap: maxMigrationRetryPeriod}
+	if backoff.Duration <= 0 {
+		backoff.Duration = time.Second
+	}
+	err := wait.ExponentialBackoffWithContext(ctx, backoff, func(ctx context.Context) (bool, error) {
+		if err := m.migrate(ctx); err != nil {
+			log.Error(err, "Storage version migration failed, retrying")
+			return false, nil
+		}
+		return true, nil
+	})
+	if err != nil && ctx.Err() == nil {
+		log.Error(err, "Storage version migration gave up")
+	}
+	return nil
+}
+
+// migrate makes one attempt at the migration
+func (m *StorageVersionMigrator) migrate(ctx context.Context) error {
+	log := log.FromContext(ctx).WithValues("crd", m.CRDName)
+
+	crd := &apiextensionsv1.CustomResourceDefinition{}
+	if err := m.Reader.Get(ctx, types.NamespacedName{Name: m.CRDName}, crd); err != nil {
+		return fmt.Errorf("getting CRD %s: %w", m.CRDName, err)
+	}
+
+	storageVersion := ""
+	for _, version := range crd.Spec.Versions {
+		if version.Storage {
+			storageVersion = version.Name
+		}
+	}
+	if storageVersion == "" {
+		return fmt.Errorf("CRD %s has no storage version", m.CRDName)
+	}
+	if len(crd.Status.StoredVersions) == 1 && crd.Status.StoredVersions[0] == storageVersion {
+		log.Info("Storage version migration not needed", "storageVersion", storageVersion)
+		return nil
+	}
+
+	list := &unstructured.UnstructuredList{}
+	list.SetAPIVersion(crd.Spec.Group + "/" + storageVersion)
+	list.SetKind(crd.Spec.Names.ListKind)
+	if err := m.Reader.List(ctx, list); err != nil {
+		return fmt.Errorf("listing %s: %w", crd.Spec.Names.Plural, err)
+	}
+
+	// An unchanged update makes the API server re-encode the object in the storage version
+	for i := range list.Items {
+		obj := &list.Items[i]
+		err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+			err := m.Client.Update(ctx, obj)
+			if errors.IsConflict(err) {
+				if getErr := m.Reader.Get(ctx, client.ObjectKeyFromObject(obj), obj); getErr != nil {
+					return getErr
+				}
+			}
+			return err
+		})
+		if client.IgnoreNotFound(err) != nil {
+			return fmt.Errorf("migrating %s %s: %w", crd.Spec.Names.Kind, client.ObjectKeyFromObject(obj), err)
+		}
+	}
+
+	// The CRD may have changed during the migration, so its status is written on a fresh copy
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		if err := m.Reader.Get(ctx, types.NamespacedName{Name: m.CRDName}, crd); err != nil {
+			return err
+		}
+		crd.Status.StoredVersions = []string{storageVersion}
+		return m.Client.Status().Update(ctx, crd)
+	})
+	if err != nil {
+		return fmt.Errorf("updating stored versions of CRD %s: %w", m.CRDName, err)
+	}
+
+	log.Info("Migrated objects to storage version", "storageVersion", storageVersion, "objects", len(list.Items))
+	return nil
+}
+
+// NeedLeaderElection makes the migration run on the leader only
+func (m *StorageVersionMigrator) NeedLeaderElection() bool {
+	return true
+}
@@ -0,0 +1,124 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	webserverv1beta1 "github.com/webserver/webserver-operator/api/v1beta1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+)
+
+// newMigrationClient returns a client holding a Webserver CRD with v1alpha1
+// still in its stored versions and two Webservers, passing calls through funcs
+func newMigrationClient(t *testing.T, funcs interceptor.Funcs) client.Client {
+	t.Helper()
+	scheme := newTestScheme(t)
+	if err := apiextensionsv1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := webserverv1beta1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	crd := &apiextensionsv1.CustomResourceDefinition{
+		ObjectMeta: metav1.ObjectMeta{Name: "webservers.webserver.io"},
+		Spec: apiextensionsv1.CustomResourceDefinitionSpec{
+			Group: "webserver.io",
+			Names: apiextensionsv1.CustomResourceDefinitionNames{Kind: "Webserver", ListKind: "WebserverList", Plural: "webservers"},
+			Versions: []apiextensionsv1.CustomResourceDefinitionVersion{
+				{Name: "v1alpha1", Served: true},
+				{Name: "v1beta1", Served: true, Storage: true},
+			},
+		},
+		Status: apiextensionsv1.CustomResourceDefinitionStatus{StoredVersions: []string{"v1alpha1", "v1beta1"}},
+	}
+	return fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(
+			crd,
+			&webserverv1beta1.Webserver{ObjectMeta: metav1.ObjectMeta{Name: "a", Namespace: "web"}},
+			&webserverv1beta1.Webserver{ObjectMeta: metav1.ObjectMeta{Name: "b", Namespace: "web"}},
+		).
+		WithStatusSubresource(crd).
+		WithInterceptorFuncs(funcs).
+		Build()
+}
+
+func storedVersions(t *testing.T, c client.Client) []string {
+	t.Helper()
+	crd := &apiextensionsv1.CustomResourceDefinition{}
+	if err := c.Get(context.Background(), types.NamespacedName{Name: "webservers.webserver.io"}, crd); err != nil {
+		t.Fatal(err)
+	}
+	return crd.Status.StoredVersions
+}
+
+func TestStorageVersionMigrator(t *testing.T) {
+	updated := map[string]bool{}
+	c := newMigrationClient(t, interceptor.Funcs{
+		Update: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.UpdateOption) error {
+			updated[obj.GetName()] = true
+			return c.Update(ctx, obj, opts...)
+		},
+	})
+
+	migrator := &StorageVersionMigrator{Client: c, Reader: c, CRDName: "webservers.webserver.io"}
+	if err := migrator.Start(context.Background()); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+
+	if !updated["a"] || !updated["b"] {
+		t.Errorf("Expected every Webserver to be rewritten, got %v", updated)
+	}
+	if got := storedVersions(t, c); len(got) != 1 || got[0] != "v1beta1" {
+		t.Errorf("Expected stored versions [v1beta1], got %v", got)
+	}
+}
+
+func TestStorageVersionMigratorRetries(t *testing.T) {
+	lists := 0
+	c := newMigrationClient(t, interceptor.Funcs{
+		List: func(ctx context.Context, c client.WithWatch, list client.ObjectList, opts ...client.ListOption) error {
+			lists++
+			if lists == 1 {
+				return apierrors.NewServiceUnavailable("etcd is unavailable")
+			}
+			// Another writer changes the CRD while the objects are migrated
+			crd := &apiextensionsv1.CustomResourceDefinition{}
+			if err := c.Get(ctx, types.NamespacedName{Name: "webservers.webserver.io"}, crd); err != nil {
+				return err
+			}
+			crd.Labels = map[string]string{"changed": "true"}
+			if err := c.Update(ctx, crd); err != nil {
+				return err
+			}
+			return c.List(ctx, list, opts...)
+		},
+	})
+
+	migrator := &StorageVersionMigrator{Client: c, Reader: c, CRDName: "webservers.webserver.io", RetryPeriod: time.Millisecond}
+	if err := migrator.Start(context.Background()); err != nil {
+		t.Fatalf("Expected failures to be retried, got %v", err)
+	}
+	if lists != 2 {
+		t.Errorf("Expected one retry after the failed list, got %d lists", lists)
+	}
+	if got := storedVersions(t, c); len(got) != 1 || got[0] != "v1beta1" {
+		t.Errorf("Expected stored versions [v1beta1] despite the concurrent change, got %v", got)
+	}
+
+	// A manager stopping during the retries is not an error
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	failing := &StorageVersionMigrator{Client: c, Reader: c, CRDName: "missing.webserver.io", RetryPeriod: time.Millisecond}
+	if err := failing.Start(ctx); err != nil {
+		t.Errorf("Expected no error once the manager stops, got %v", err)
+	}
+}
@@ -0,0 +1,79 @@
+package controllers
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	webserverv1alpha1 "github.com/webserver/webserver-operator/api/v1alpha1"
+)
+
+// TracerName is the instrumentation scope of the reconciler spans
+const TracerName = "github.com/webserver/webserver-operator/controllers"
+
+// Span attributes describing the Webserver and the outcome of a step
+const (
+	attrName       = attribute.Key("webserver.name")
+	attrNamespace  = attribute.Key("webserver.namespace")
+	attrGeneration = attribute.Key("webserver.generation")
+	attrResult     = attribute.Key("operation.result")
+)
+
+// tracer returns the configured tracer or a no-op one
+func (r *WebserverReconciler) tracer() trace.Tracer {
+	if r.Tracer == nil {
+		return noop.NewTracerProvider().Tracer(TracerName)
+	}
+	return r.Tracer
+}
+
+// webserverAttributes identifies the Webserver a span works on
+func webserverAttributes(webserver *webserverv1alpha1.Webserver) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attrName.String(webserver.Name),
+		attrNamespace.String(webserver.Namespace),
+		attrGeneration.Int64(webserver.Generation),
+	}
+}
+
+// endSpan records err on the span and ends it
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// createOrUpdate runs ctrl.CreateOrUpdate for a child of the Webserver in a
+// span named after the mutate step, recording whether the object changed
+func (r *WebserverReconciler) createOrUpdate(ctx context.Context, step string, webserver *webserverv1alpha1.Webserver, obj client.Object, mutate controllerutil.MutateFn) (op controllerutil.OperationResult, err error) {
+	ctx, span := r.tracer().Start(ctx, step, trace.WithAttributes(webserverAttributes(webserver)...))
+	defer func() {
+		span.SetAttributes(attribute.String("k8s.object.name", obj.GetName()))
+		if err == nil {
+			span.SetAttributes(attrResult.String(string(op)))
+		}
+		endSpan(span, err)
+	}()
+	return ctrl.CreateOrUpdate(ctx, r.Client, obj, mutate)
+}
+
+// reconcileOutcome is the operation result of a reconcile: Error, an explicit
+// outcome such as NotFound, or else the phase the Webserver ended up in
+func reconcileOutcome(outcome string, webserver *webserverv1alpha1.Webserver, err error) string {
+	switch {
+	case err != nil:
+		return "Error"
+	case outcome != "":
+		return outcome
+	default:
+		return webserver.Status.Phase
+	}
+}
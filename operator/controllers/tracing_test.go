@@ -0,0 +1,101 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	webserverv1alpha1 "github.com/webserver/webserver-operator/api/v1alpha1"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// newTracedReconciler returns a test reconciler recording its spans in memory
+func newTracedReconciler(t *testing.T, webserver *webserverv1alpha1.Webserver) (*WebserverReconciler, *tracetest.InMemoryExporter) {
+	t.Helper()
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	t.Cleanup(func() { _ = provider.Shutdown(context.Background()) })
+	r := newTestReconciler(t, webserver)
+	r.Tracer = provider.Tracer(TracerName)
+	return r, exporter
+}
+
+// spanAttribute returns the value of an attribute of a recorded span
+func spanAttribute(span tracetest.SpanStub, key attribute.Key) attribute.Value {
+	for _, kv := range span.Attributes {
+		if kv.Key == key {
+			return kv.Value
+		}
+	}
+	return attribute.Value{}
+}
+
+func TestReconcileSpans(t *testing.T) {
+	webserver := &webserverv1alpha1.Webserver{
+		ObjectMeta: metav1.ObjectMeta{Name: "site", Namespace: "web", Generation: 3},
+		Spec:       webserverv1alpha1.WebserverSpec{Replicas: 2},
+	}
+	r, exporter := newTracedReconciler(t, webserver)
+
+	reconcileDeployment(t, r, "site")
+
+	spans := map[string]tracetest.SpanStub{}
+	for _, span := range exporter.GetSpans() {
+		spans[span.Name] = span
+	}
+	root, ok := spans["Reconcile"]
+	if !ok {
+		t.Fatalf("Expected a Reconcile span, got %v", exporter.GetSpans())
+	}
+	if got := spanAttribute(root, attrResult).AsString(); got != "Ready" {
+		t.Errorf("Expected the Reconcile result Ready, got %q", got)
+	}
+
+	for _, name := range []string{"mutateDeployment", "mutatePodDisruptionBudget", "mutateConfigMap", "mutateService", "updateStatus"} {
+		span, ok := spans[name]
+		if !ok {
+			t.Errorf("Expected a %s span", name)
+			continue
+		}
+		if span.Parent.SpanID() != root.SpanContext.SpanID() {
+			t.Errorf("Expected %s to be a child of Reconcile", name)
+		}
+		if spanAttribute(span, attrName).AsString() != "site" || spanAttribute(span, attrNamespace).AsString() != "web" ||
+			spanAttribute(span, attrGeneration).AsInt64() != 3 {
+			t.Errorf("Expected %s to identify web/site at generation 3, got %v", name, span.Attributes)
+		}
+	}
+	if got := spanAttribute(spans["mutateDeployment"], attrResult).AsString(); got != "created" {
+		t.Errorf("Expected the Deployment to be created, got %q", got)
+	}
+
+	exporter.Reset()
+	reconcileDeployment(t, r, "site")
+	for _, span := range exporter.GetSpans() {
+		if span.Name == "mutateService" {
+			if got := spanAttribute(span, attrResult).AsString(); got != "unchanged" {
+				t.Errorf("Expected the Service to be unchanged on the second reconcile, got %q", got)
+			}
+		}
+	}
+}
+
+func TestReconcileSpanNotFound(t *testing.T) {
+	r, exporter := newTracedReconciler(t, &webserverv1alpha1.Webserver{ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "web"}})
+
+	if _, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: types.NamespacedName{Name: "site", Namespace: "web"}}); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+
+	spans := exporter.GetSpans()
+	if len(spans) != 1 || spans[0].Name != "Reconcile" {
+		t.Fatalf("Expected only the Reconcile span, got %v", spans)
+	}
+	if got := spanAttribute(spans[0], attrResult).AsString(); got != "NotFound" {
+		t.Errorf("Expected the Reconcile result NotFound, got %q", got)
+	}
+}
@@ -3,84 +3,305 @@ package controllers
 import (
 	"context"
 	"fmt"
+	"strings"
 	"time"
 
+	"go.opentelemetry.io/otel/trace"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
-	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/utils/clock"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	webserverv1alpha1 "github.com/webserver/webserver-operator/api/v1alpha1"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	policyv1 "k8s.io/api/policy/v1"
 )
 
 // WebserverReconciler reconciles a Webserver object
 type WebserverReconciler struct {
 	client.Client
 	Scheme *runtime.Scheme
+
+	// Verifier checks the served page after a rollout; verification is skipped when nil
+	Verifier ContentVerifier
+
+	// ImagePolicy is the operator-wide image policy, combined with each class policy
+	ImagePolicy ImagePolicy
+
+	// Resolver pins image tags to digests when the image policy asks for it
+	Resolver DigestResolver
+
+	// Fetcher downloads content bundles; an HTTP fetcher is used when nil
+	Fetcher ContentFetcher
+
+	// DefaultImage is used when neither the Webserver nor its class sets an image
+	DefaultImage string
+
+	// ResyncPeriod is how often a healthy Webserver is reconciled again
+	ResyncPeriod time.Duration
+
+	// MaxConcurrentReconciles is the number of Webservers reconciled in parallel
+	MaxConcurrentReconciles int
+
+	// RateLimiter paces the retries of failed reconciles; the controller-runtime
+	// default is used when nil
+	RateLimiter workqueue.TypedRateLimiter[reconcile.Request]
+
+	// Clock tells the time for schedules; the real clock is used when nil
+	Clock clock.PassiveClock
+
+	// MetricsExporterImage is the image of the metrics exporter sidecar
+	MetricsExporterImage string
+
+	// ServiceMonitors is set when the ServiceMonitor CRD is installed
+	ServiceMonitors bool
+
+	// InheritedLabelPrefixes lists the prefixes of Webserver labels copied to its children
+	InheritedLabelPrefixes []string
+
+	// Recorder emits events on Webservers; no events are emitted when nil
+	Recorder record.EventRecorder
+
+	// Tracer records a span per reconcile and per child object; spans are dropped when nil
+	Tracer trace.Tracer
+
+	// History keeps the last reconcile of every Webserver; nothing is kept when nil
+	History *ReconcileHistory
+
+	// forced receives the Webservers queued by ForceReconcile
+	forced chan event.GenericEvent
 }
 
+const (
+	// defaultImage is used when no image is configured anywhere
+	defaultImage = "nginx:1.25"
+
+	// defaultResyncPeriod is used when no resync period is configured
+	defaultResyncPeriod = time.Minute * 5
+)
+
 //+kubebuilder:rbac:groups=webserver.io,resources=webservers,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=webserver.io,resources=webservers/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=webserver.io,resources=webservers/finalizers,verbs=update
+//+kubebuilder:rbac:groups=webserver.io,resources=webserverclasses,verbs=get;list;watch
 //+kubebuilder:rbac:groups=apps,resources=deployments,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=core,resources=pods,verbs=get;list;watch
 //+kubebuilder:rbac:groups=core,resources=services,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=core,resources=configmaps,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=networking.k8s.io,resources=ingresses,verbs=get;list;watch
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
-func (r *WebserverReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+func (r *WebserverReconciler) Reconcile(ctx context.Context, req ctrl.Request) (result ctrl.Result, err error) {
 	log := log.FromContext(ctx)
 
-	// Fetch the Webserver instance
+	ctx, span := r.tracer().Start(ctx, "Reconcile", trace.WithAttributes(
+		attrName.String(req.Name),
+		attrNamespace.String(req.Namespace),
+	))
 	webserver := &webserverv1alpha1.Webserver{}
-	err := r.Get(ctx, req.NamespacedName, webserver)
+	outcome := ""
+	start := time.Now()
+	defer func() {
+		outcome = reconcileOutcome(outcome, webserver, err)
+		span.SetAttributes(attrGeneration.Int64(webserver.Generation), attrResult.String(outcome))
+		endSpan(span, err)
+		r.History.record(req.NamespacedName, start, time.Since(start), outcome, result, err)
+	}()
+
+	// Fetch the Webserver instance
+	err = r.Get(ctx, req.NamespacedName, webserver)
 	if err != nil {
 		if errors.IsNotFound(err) {
 			// Request object not found, could have been deleted after reconcile request.
 			// Return and don't requeue
 			log.Info("Webserver resource not found. Ignoring since object must be deleted")
+			outcome = "NotFound"
 			return ctrl.Result{}, nil
 		}
 		// Error reading the object - requeue the request.
 		log.Error(err, "Failed to get Webserver")
 		return ctrl.Result{}, err
 	}
+	// The stored object is the base of the status patch, so neither defaults
+	// applied to the spec nor a stale resourceVersion are sent back
+	original := webserver.DeepCopy()
+
+	// The Webserver is being deleted; its finalizers are left to their owners
+	if !webserver.DeletionTimestamp.IsZero() {
+		outcome = "Deleting"
+		return ctrl.Result{}, nil
+	}
+
+	// Delete the Webserver once it has expired
+	now := r.now()
+	recordActivity(webserver, now)
+	expiresAt, err := expiryFor(webserver)
+	if err != nil {
+		return ctrl.Result{}, r.setFailed(ctx, original, webserver, "InvalidExpiry", err.Error())
+	}
+	webserver.Status.ExpiresAt = nil
+	if !expiresAt.IsZero() {
+		if !now.Before(expiresAt) {
+			log.Info("Deleting expired Webserver", "expiresAt", expiresAt)
+			if err := r.Delete(ctx, webserver, client.PropagationPolicy(metav1.DeletePropagationBackground)); client.IgnoreNotFound(err) != nil {
+				log.Error(err, "Failed to delete expired Webserver")
+				return ctrl.Result{}, err
+			}
+			outcome = "Expired"
+			return ctrl.Result{}, nil
+		}
+		expiry := metav1.NewTime(expiresAt)
+		webserver.Status.ExpiresAt = &expiry
+	}
+
+	// Leave a suspended Webserver and its children alone until it is resumed;
+	// it still expires on time
+	if webserver.Annotations[webserverv1alpha1.SuspendAnnotation] == "true" {
+		return requeueBefore(ctrl.Result{}, expiresAt, now), r.setSuspended(ctx, original, webserver)
+	}
+
+	result, err = r.reconcileWebserver(ctx, original, webserver, now)
+	if err != nil {
+		if classifyError(err) == ErrorConflict {
+			outcome = string(ErrorConflict)
+		}
+		// A Webserver that cannot be reconciled still expires on time
+		result, err = r.handleError(ctx, original, webserver, err)
+		if err != nil {
+			return result, err
+		}
+		return requeueBefore(result, expiresAt, now), nil
+	}
+	return requeueBefore(result, expiresAt, now), nil
+}
+
+// reconcileWebserver drives the child objects of a live Webserver towards its spec
+func (r *WebserverReconciler) reconcileWebserver(ctx context.Context, original, webserver *webserverv1alpha1.Webserver, now time.Time) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+
+	// A spec that failed permanently is not retried until it changes
+	if stalled(webserver) {
+		return ctrl.Result{}, nil
+	}
+	meta.RemoveStatusCondition(&webserver.Status.Conditions, stalledCondition)
+
+	// Resolve the WebserverClass supplying defaults and policy
+	class, err := r.resolveClass(ctx, webserver)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			// Wait for the class to be created; the class watch requeues us
+			return ctrl.Result{}, dependencyMissingError("ClassNotFound",
+				fmt.Sprintf("WebserverClass %q not found", webserver.Spec.ClassName))
+		}
+		log.Error(err, "Failed to resolve WebserverClass")
+		return ctrl.Result{}, err
+	}
+	webserver.Status.ClassName = ""
+	if class != nil {
+		webserver.Status.ClassName = class.Name
+	}
 
 	// Set default values
-	if webserver.Spec.Replicas == 0 {
-		webserver.Spec.Replicas = 1
+	r.applyDefaults(webserver, class)
+
+	// Apply the schedule window that is open now, if any
+	schedule, err := evaluateSchedule(webserver.Spec.Schedule, webserver.Spec.Replicas, now)
+	if err != nil {
+		return ctrl.Result{}, permanentError("InvalidSchedule", err.Error())
 	}
-	if webserver.Spec.Image == "" {
-		webserver.Spec.Image = "nginx:1.25"
+	webserver.Spec.Replicas = schedule.Replicas
+	webserver.Status.ActiveSchedule = schedule.Active
+	webserver.Status.NextScheduleTime = nil
+	if !schedule.NextChange.IsZero() {
+		next := metav1.NewTime(schedule.NextChange)
+		webserver.Status.NextScheduleTime = &next
 	}
-	if webserver.Spec.Port == 0 {
-		webserver.Spec.Port = 80
+
+	// Enforce the class policy before touching any child objects; the class
+	// watch requeues the Webserver when the policy changes
+	if violations := classPolicyViolations(class, webserver); len(violations) > 0 {
+		return ctrl.Result{}, dependencyMissingError("PolicyViolation",
+			fmt.Sprintf("WebserverClass %q policy violated: %s", class.Name, strings.Join(violations, "; ")))
 	}
-	if webserver.Spec.ServiceType == "" {
-		webserver.Spec.ServiceType = "ClusterIP"
+
+	// Enforce the image policy and pin the image to a digest if requested. The
+	// policy is operator configuration, so a violation waits rather than stalls.
+	violations, err := r.resolveImage(ctx, webserver, class)
+	if err != nil {
+		log.Error(err, "Failed to resolve image")
+		return ctrl.Result{}, err
 	}
-	if webserver.Spec.Config.Title == "" {
-		webserver.Spec.Config.Title = "Webserver Operator Demo"
+	if len(violations) > 0 {
+		return ctrl.Result{}, dependencyMissingError("ImagePolicyViolation",
+			fmt.Sprintf("Image policy violated: %s", strings.Join(violations, "; ")))
 	}
-	if webserver.Spec.Config.Message == "" {
-		webserver.Spec.Config.Message = "Welcome to the Webserver Operator Demo!"
+
+	// Extra containers and volumes must not replace the built-in ones
+	if violations := podExtensionViolations(webserver); len(violations) > 0 {
+		return ctrl.Result{}, permanentError("NameCollision", strings.Join(violations, "; "))
 	}
-	if webserver.Spec.Config.Color == "" {
-		webserver.Spec.Config.Color = "#f0f0f0"
+
+	// Service options must suit the Service type
+	if violations := serviceViolations(webserver); len(violations) > 0 {
+		return ctrl.Result{}, permanentError("InvalidService", strings.Join(violations, "; "))
+	}
+
+	// Locale templates must render before they replace the page
+	if violations := localeViolations(webserver); len(violations) > 0 {
+		return ctrl.Result{}, permanentError("InvalidLocale", strings.Join(violations, "; "))
+	}
+
+	// Hold back Webservers that do not fit a namespace quota instead of scaling them
+	exceeded, quotaApplies, err := r.checkQuota(ctx, webserver)
+	if err != nil {
+		log.Error(err, "Failed to check WebserverQuotas")
+		return ctrl.Result{}, err
+	}
+	if exceeded != "" {
+		return ctrl.Result{}, quotaExceededError(webserver, exceeded)
+	}
+	if quotaApplies {
+		meta.SetStatusCondition(&webserver.Status.Conditions, metav1.Condition{
+			Type:    quotaExceededCondition,
+			Status:  metav1.ConditionFalse,
+			Reason:  "WithinQuota",
+			Message: "The Webserver fits every WebserverQuota of its namespace",
+		})
+	} else {
+		meta.RemoveStatusCondition(&webserver.Status.Conditions, quotaExceededCondition)
 	}
 
 	// Update the status
 	webserver.Status.ObservedGeneration = webserver.Generation
 	webserver.Status.Phase = "Reconciling"
 
+	// Store the content bundle in shards before pods mount them
+	invalid, err := r.reconcileContentBundle(ctx, webserver)
+	if err != nil {
+		log.Error(err, "Failed to reconcile content bundle")
+		return ctrl.Result{}, err
+	}
+	if invalid != "" {
+		return ctrl.Result{}, permanentError("InvalidContentBundle", invalid)
+	}
+
 	// Create or update the deployment
 	deployment := &appsv1.Deployment{
 		ObjectMeta: metav1.ObjectMeta{
@@ -89,8 +310,8 @@ func (r *WebserverReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 		},
 	}
 
-	op, err := ctrl.CreateOrUpdate(ctx, r.Client, deployment, func() error {
-		return r.mutateDeployment(deployment, webserver)
+	op, err := r.createOrUpdate(ctx, "mutateDeployment", webserver, deployment, func() error {
+		return r.mutateDeployment(deployment, webserver, class)
 	})
 	if err != nil {
 		log.Error(err, "Failed to create or update deployment")
@@ -101,6 +322,18 @@ func (r *WebserverReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 		log.Info("Deployment operation", "operation", op)
 	}
 
+	// Drop shards the new pod template no longer mounts
+	if err := r.deleteStaleContentShards(ctx, webserver); err != nil {
+		log.Error(err, "Failed to delete stale content shards")
+		return ctrl.Result{}, err
+	}
+
+	// Keep node drains from taking down every replica at once
+	if err := r.reconcilePodDisruptionBudget(ctx, webserver); err != nil {
+		log.Error(err, "Failed to reconcile PodDisruptionBudget")
+		return ctrl.Result{}, err
+	}
+
 	// Create or update the configmap
 	configmap := &corev1.ConfigMap{
 		ObjectMeta: metav1.ObjectMeta{
@@ -109,7 +342,7 @@ func (r *WebserverReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 		},
 	}
 
-	op, err = ctrl.CreateOrUpdate(ctx, r.Client, configmap, func() error {
+	op, err = r.createOrUpdate(ctx, "mutateConfigMap", webserver, configmap, func() error {
 		return r.mutateConfigMap(configmap, webserver)
 	})
 	if err != nil {
@@ -121,24 +354,27 @@ func (r *WebserverReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 		log.Info("ConfigMap operation", "operation", op)
 	}
 
-	// Create or update the service
-	service := &corev1.Service{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      webserver.Name + "-service",
-			Namespace: webserver.Namespace,
-		},
-	}
-
-	op, err = ctrl.CreateOrUpdate(ctx, r.Client, service, func() error {
-		return r.mutateService(service, webserver)
-	})
+	// Create or update the service, recreating it when an immutable field changes
+	service, err := r.reconcileService(ctx, webserver)
 	if err != nil {
 		log.Error(err, "Failed to create or update service")
 		return ctrl.Result{}, err
 	}
+	if service == nil {
+		// The replaced Service is still being deleted
+		return ctrl.Result{RequeueAfter: serviceRecreateRetry}, nil
+	}
 
-	if op != controllerutil.OperationResultNone {
-		log.Info("Service operation", "operation", op)
+	// Let Prometheus scrape the exporter
+	if err := r.reconcileServiceMonitor(ctx, webserver); err != nil {
+		log.Error(err, "Failed to reconcile ServiceMonitor")
+		return ctrl.Result{}, err
+	}
+
+	// Report where the web server can be reached
+	if err := r.updateEndpoints(ctx, webserver, service); err != nil {
+		log.Error(err, "Failed to update endpoints")
+		return ctrl.Result{}, err
 	}
 
 	// Update status with deployment information
@@ -147,51 +383,105 @@ func (r *WebserverReconciler) Reconcile(ctx context.Context, req ctrl.Request) (
 		return ctrl.Result{}, err
 	}
 
+	// Summarize the pods so crash loops and scheduling problems show up in status
+	if err := r.updatePodSummary(ctx, webserver); err != nil {
+		log.Error(err, "Failed to summarize pods")
+		return ctrl.Result{}, err
+	}
+
+	requeueAfter := resyncPeriod(r.ResyncPeriod)
+
+	// Check that the page served through the Service is the one we rendered
+	retryAfter, err := r.verifyContent(ctx, webserver)
+	if err != nil {
+		log.Error(err, "Failed to verify content")
+		return ctrl.Result{}, err
+	}
+	if retryAfter > 0 && retryAfter < requeueAfter {
+		requeueAfter = retryAfter
+	}
+
+	// Wake up exactly when the scheduled replica count changes
+	if !schedule.NextChange.IsZero() {
+		if untilChange := schedule.NextChange.Sub(now); untilChange < requeueAfter {
+			requeueAfter = max(untilChange, time.Second)
+		}
+	}
+
 	// Update the final status
 	webserver.Status.Phase = "Ready"
-	if err := r.Status().Update(ctx, webserver); err != nil {
+	if err := r.writeStatus(ctx, original, webserver); err != nil {
 		log.Error(err, "Failed to update Webserver status")
 		return ctrl.Result{}, err
 	}
 
-	return ctrl.Result{RequeueAfter: time.Minute * 5}, nil
+	return ctrl.Result{RequeueAfter: requeueAfter}, nil
+}
+
+// applyDefaults fills in the fields the Webserver leaves empty from the class
+// and the operator defaults
+func (r *WebserverReconciler) applyDefaults(webserver *webserverv1alpha1.Webserver, class *webserverv1alpha1.WebserverClass) {
+	if webserver.Spec.Replicas == 0 {
+		webserver.Spec.Replicas = 1
+	}
+	if webserver.Spec.Image == "" {
+		webserver.Spec.Image = r.DefaultImage
+		if webserver.Spec.Image == "" {
+			webserver.Spec.Image = defaultImage
+		}
+		if class != nil && class.Spec.Image != "" {
+			webserver.Spec.Image = class.Spec.Image
+		}
+	}
+	if webserver.Spec.Port == 0 {
+		webserver.Spec.Port = defaultPort
+		if class != nil && class.Spec.Port != 0 {
+			webserver.Spec.Port = class.Spec.Port
+		}
+	}
+	if webserver.Spec.ServiceType == "" {
+		webserver.Spec.ServiceType = "ClusterIP"
+	}
+	if webserver.Spec.Config.Title == "" {
+		webserver.Spec.Config.Title = "Webserver Operator Demo"
+	}
+	if webserver.Spec.Config.Message == "" {
+		webserver.Spec.Config.Message = "Welcome to the Webserver Operator Demo!"
+	}
+	if webserver.Spec.Config.Color == "" {
+		webserver.Spec.Config.Color = "#f0f0f0"
+	}
+	if webserver.Spec.Config.DefaultLocale == "" {
+		webserver.Spec.Config.DefaultLocale = defaultLocale
+	}
 }
 
 // mutateDeployment creates or updates the deployment
-func (r *WebserverReconciler) mutateDeployment(deployment *appsv1.Deployment, webserver *webserverv1alpha1.Webserver) error {
+func (r *WebserverReconciler) mutateDeployment(deployment *appsv1.Deployment, webserver *webserverv1alpha1.Webserver, class *webserverv1alpha1.WebserverClass) error {
 	// Set the owner reference
 	if err := ctrl.SetControllerReference(webserver, deployment, r.Scheme); err != nil {
 		return err
 	}
 
-	// Set labels
-	deployment.Labels = map[string]string{
-		"app":        "webserver",
-		"instance":   webserver.Name,
-		"managed-by": "webserver-operator",
-	}
+	// Set labels and annotations
+	r.setChildMetadata(deployment, webserver)
 
 	// Set spec
 	deployment.Spec = appsv1.DeploymentSpec{
 		Replicas: &webserver.Spec.Replicas,
 		Selector: &metav1.LabelSelector{
-			MatchLabels: map[string]string{
-				"app":      "webserver",
-				"instance": webserver.Name,
-			},
+			MatchLabels: selectorLabels(webserver),
 		},
 		Template: corev1.PodTemplateSpec{
 			ObjectMeta: metav1.ObjectMeta{
-				Labels: map[string]string{
-					"app":      "webserver",
-					"instance": webserver.Name,
-				},
+				Labels:      r.childLabels(webserver),
+				Annotations: podAnnotations(webserver),
 			},
 			Spec: corev1.PodSpec{
 				Containers: []corev1.Container{
 					{
-						Name:  "webserver",
-						Image: webserver.Spec.Image,
+						Name:  webserverContainerName,
+						Image: deployedImage(webserver),
 						Ports: []corev1.ContainerPort{
 							{
 								ContainerPort: webserver.Spec.Port,
@@ -204,17 +494,29 @@ func (r *WebserverReconciler) mutateDeployment(deployment *appsv1.Deployment, we
 								MountPath: "/usr/share/nginx/html",
 								ReadOnly:  true,
 							},
+							{
+								Name:      "nginx-config",
+								MountPath: "/etc/nginx/conf.d",
+								ReadOnly:  true,
+							},
 						},
+						SecurityContext: containerSecurityContext(webserver, class),
 					},
 				},
+				SecurityContext: defaultPodSecurityContext(webserver),
 				Volumes: []corev1.Volume{
 					{
-						Name: "html-content",
+						Name:         "html-content",
+						VolumeSource: contentVolumeSource(webserver),
+					},
+					{
+						Name: "nginx-config",
 						VolumeSource: corev1.VolumeSource{
 							ConfigMap: &corev1.ConfigMapVolumeSource{
 								LocalObjectReference: corev1.LocalObjectReference{
 									Name: webserver.Name + "-config",
 								},
+								Items: []corev1.KeyToPath{{Key: nginxConfigKey, Path: nginxConfigKey}},
 							},
 						},
 					},
@@ -223,6 +525,32 @@ func (r *WebserverReconciler) mutateDeployment(deployment *appsv1.Deployment, we
 		},
 	}
 
+	// Back the paths nginx writes to with emptyDirs, as the root filesystem is read-only
+	podSpec := &deployment.Spec.Template.Spec
+	for _, writable := range nginxWritablePaths {
+		podSpec.Containers[0].VolumeMounts = append(podSpec.Containers[0].VolumeMounts, corev1.VolumeMount{
+			Name:      writable.Volume,
+			MountPath: writable.Path,
+		})
+		podSpec.Volumes = append(podSpec.Volumes, corev1.Volume{
+			Name:         writable.Volume,
+			VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}},
+		})
+	}
+
+	// Apply the class resources
+	if class != nil && class.Spec.Resources != nil {
+		podSpec.Containers[0].Resources = *class.Spec.Resources.DeepCopy()
+	}
+
+	// Export request metrics from the nginx stub_status page
+	if metricsEnabled(webserver) {
+		podSpec.Containers = append(podSpec.Containers, r.metricsExporterContainer(webserver))
+	}
+
+	// Add the sidecars, init containers, volumes and environment of the Webserver
+	applyPodExtensions(podSpec, webserver)
+
 	return nil
 }
 
@@ -234,26 +562,46 @@ func (r *WebserverReconciler) mutateService(service *corev1.Service, webserver *
 	}
 
 	// Set labels
-	service.Labels = map[string]string{
-		"app":        "webserver",
-		"instance":   webserver.Name,
-		"managed-by": "webserver-operator",
-	}
+	service.Labels = r.childLabels(webserver)
 
-	// Set spec
-	service.Spec = corev1.ServiceSpec{
-		Selector: map[string]string{
-			"app":      "webserver",
-			"instance": webserver.Name,
-		},
-		Ports: []corev1.ServicePort{
-			{
-				Port:       80,
-				TargetPort: intstr.FromInt(int(webserver.Spec.Port)),
-				Name:       "http",
-			},
-		},
-		Type: corev1.ServiceType(webserver.Spec.ServiceType),
+	// Set annotations, dropping the ones an earlier spec set
+	setServiceAnnotations(service, webserver)
+
+	// Set spec, keeping the cluster IP and node ports the API server allocated
+	serviceType := corev1.ServiceType(webserver.Spec.ServiceType)
+	options := webserver.Spec.Service
+	if options == nil {
+		options = &webserverv1alpha1.ServiceSpec{}
+	}
+	service.Spec.Selector = map[string]string{
+		"app":      "webserver",
+		"instance": webserver.Name,
+	}
+	service.Spec.Type = serviceType
+	service.Spec.Ports = servicePorts(webserver, service.Spec.Ports)
+	if options.Headless {
+		service.Spec.ClusterIP = corev1.ClusterIPNone
+	}
+	service.Spec.LoadBalancerSourceRanges = nil
+	if serviceType == corev1.ServiceTypeLoadBalancer {
+		service.Spec.LoadBalancerSourceRanges = options.LoadBalancerSourceRanges
+	}
+	service.Spec.ExternalTrafficPolicy = ""
+	if serviceType != corev1.ServiceTypeClusterIP {
+		service.Spec.ExternalTrafficPolicy = options.ExternalTrafficPolicy
+		if service.Spec.ExternalTrafficPolicy == "" {
+			service.Spec.ExternalTrafficPolicy = corev1.ServiceExternalTrafficPolicyCluster
+		}
+	}
+	if serviceType != corev1.ServiceTypeLoadBalancer || service.Spec.ExternalTrafficPolicy != corev1.ServiceExternalTrafficPolicyLocal {
+		service.Spec.HealthCheckNodePort = 0
+	}
+	service.Spec.SessionAffinity = options.SessionAffinity
+	if service.Spec.SessionAffinity == "" {
+		service.Spec.SessionAffinity = corev1.ServiceAffinityNone
+	}
+	if service.Spec.SessionAffinity == corev1.ServiceAffinityNone {
+		service.Spec.SessionAffinityConfig = nil
 	}
 
 	return nil
@@ -266,19 +614,35 @@ func (r *WebserverReconciler) mutateConfigMap(configmap *corev1.ConfigMap, webse
 		return err
 	}
 
-	// Set labels
-	configmap.Labels = map[string]string{
-		"app":        "webserver",
-		"instance":   webserver.Name,
-		"managed-by": "webserver-operator",
+	// Set labels and annotations
+	r.setChildMetadata(configmap, webserver)
+
+	// The marker lets the content verifier recognise this rendering in the served page
+	marker := contentMarker(webserver)
+	if configmap.Annotations == nil {
+		configmap.Annotations = map[string]string{}
+	}
+	configmap.Annotations[contentMarkerAnnotation] = marker
+
+	// Generate the HTML content, one page per locale
+	pages, err := localePages(webserver, marker)
+	if err != nil {
+		return err
 	}
+	configmap.Data = pages
+	configmap.Data[nginxConfigKey] = nginxServerConfig(webserver)
+
+	return nil
+}
 
-	// Generate HTML content
-	htmlContent := fmt.Sprintf(`<!DOCTYPE html>
-<html lang="en">
+// generatedPage renders the demo page in the given locale
+func generatedPage(webserver *webserverv1alpha1.Webserver, marker, locale, title, message string) string {
+	return fmt.Sprintf(`<!DOCTYPE html>
+<html lang="%s">
 <head>
     <meta charset="UTF-8">
     <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <meta name="%s" content="%s">
     <title>%s</title>
     <style>
         body {
@@ -356,10 +720,13 @@ func (r *WebserverReconciler) mutateConfigMap(configmap *corev1.ConfigMap, webse
     </div>
 </body>
 </html>`,
-		webserver.Spec.Config.Title,
+		locale,
+		contentMarkerMeta,
+		marker,
+		title,
 		webserver.Spec.Config.Color,
-		webserver.Spec.Config.Title,
-		webserver.Spec.Config.Message,
+		title,
+		message,
 		webserver.Name,
 		webserver.Namespace,
 		webserver.Spec.Replicas,
@@ -367,13 +734,6 @@ func (r *WebserverReconciler) mutateConfigMap(configmap *corev1.ConfigMap, webse
 		webserver.Spec.Port,
 		webserver.Spec.ServiceType,
 		time.Now().Format("2006-01-02 15:04:05 MST"))
-
-	// Set the HTML content
-	configmap.Data = map[string]string{
-		"index.html": htmlContent,
-	}
-
-	return nil
 }
 
 // updateStatus updates the status of the Webserver resource
@@ -407,17 +767,93 @@ func (r *WebserverReconciler) updateStatus(ctx context.Context, webserver *webse
 	}
 
 	// Update conditions
-	webserver.Status.Conditions = []metav1.Condition{condition}
+	meta.SetStatusCondition(&webserver.Status.Conditions, condition)
 
 	return nil
 }
 
+// setFailed marks the Webserver as Failed with a Ready=False condition and
+// persists the status. It is used for problems that retrying cannot fix.
+func (r *WebserverReconciler) setFailed(ctx context.Context, original, webserver *webserverv1alpha1.Webserver, reason, message string) error {
+	r.markFailed(ctx, webserver, reason, message)
+	return r.writeStatus(ctx, original, webserver)
+}
+
+// markFailed marks the Webserver as Failed with a Ready=False condition
+func (r *WebserverReconciler) markFailed(ctx context.Context, webserver *webserverv1alpha1.Webserver, reason, message string) {
+	webserver.Status.ObservedGeneration = webserver.Generation
+	webserver.Status.Phase = "Failed"
+	meta.SetStatusCondition(&webserver.Status.Conditions, metav1.Condition{
+		Type:    "Ready",
+		Status:  metav1.ConditionFalse,
+		Reason:  reason,
+		Message: message,
+	})
+	log.FromContext(ctx).Info("Webserver cannot be reconciled", "reason", reason, "message", message)
+}
+
+// setSuspended reports that the Webserver is not being reconciled
+func (r *WebserverReconciler) setSuspended(ctx context.Context, original, webserver *webserverv1alpha1.Webserver) error {
+	webserver.Status.ObservedGeneration = webserver.Generation
+	webserver.Status.Phase = "Suspended"
+	meta.SetStatusCondition(&webserver.Status.Conditions, metav1.Condition{
+		Type:    "Ready",
+		Status:  metav1.ConditionUnknown,
+		Reason:  "Suspended",
+		Message: fmt.Sprintf("Reconciliation is suspended by the %s annotation", webserverv1alpha1.SuspendAnnotation),
+	})
+	return r.writeStatus(ctx, original, webserver)
+}
+
 // SetupWithManager sets up the controller with the Manager.
 func (r *WebserverReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
+	// Index Webservers by class so class changes requeue their dependents
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(), &webserverv1alpha1.Webserver{}, classNameField, func(obj client.Object) []string {
+		return []string{obj.(*webserverv1alpha1.Webserver).Spec.ClassName}
+	}); err != nil {
+		return err
+	}
+
+	r.forced = make(chan event.GenericEvent, forcedReconcileBuffer)
+	builder := ctrl.NewControllerManagedBy(mgr).
 		For(&webserverv1alpha1.Webserver{}).
 		Owns(&appsv1.Deployment{}).
 		Owns(&corev1.Service{}).
 		Owns(&corev1.ConfigMap{}).
-		Complete(r)
+		Owns(&policyv1.PodDisruptionBudget{}).
+		Owns(&networkingv1.Ingress{}).
+		Watches(&webserverv1alpha1.WebserverClass{}, handler.EnqueueRequestsFromMapFunc(r.webserversForClass)).
+		Watches(&webserverv1alpha1.WebserverQuota{}, handler.EnqueueRequestsFromMapFunc(r.webserversForQuota)).
+		Watches(&corev1.Pod{}, handler.EnqueueRequestsFromMapFunc(r.webserverForPod)).
+		WatchesRawSource(source.Channel(r.forced, &handler.EnqueueRequestForObject{})).
+		WithOptions(controller.Options{MaxConcurrentReconciles: r.MaxConcurrentReconciles, RateLimiter: r.RateLimiter})
+	if r.ServiceMonitors {
+		serviceMonitor := &unstructured.Unstructured{}
+		serviceMonitor.SetGroupVersionKind(serviceMonitorGVK)
+		builder = builder.Owns(serviceMonitor)
+	}
+	return builder.Complete(r)
+}
+
+// now returns the current time from the injected clock
+func (r *WebserverReconciler) now() time.Time {
+	if r.Clock == nil {
+		return time.Now()
+	}
+	return r.Clock.Now()
+}
+
+// event records an event on the Webserver when a recorder is configured
+func (r *WebserverReconciler) event(webserver *webserverv1alpha1.Webserver, eventType, reason, message string) {
+	if r.Recorder != nil {
+		r.Recorder.Event(webserver, eventType, reason, message)
+	}
+}
+
+// resyncPeriod returns the configured resync period or the default
+func resyncPeriod(configured time.Duration) time.Duration {
+	if configured <= 0 {
+		return defaultResyncPeriod
+	}
+	return configured
 }
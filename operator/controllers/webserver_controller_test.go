@@ -0,0 +1,76 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	webserverv1alpha1 "github.com/webserver/webserver-operator/api/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+func TestReconcileUsesConfiguredDefaults(t *testing.T) {
+	webserver := &webserverv1alpha1.Webserver{ObjectMeta: metav1.ObjectMeta{Name: "site", Namespace: "web"}}
+	r := newTestReconciler(t, webserver)
+	r.DefaultImage = "registry.example.com/nginx:1.27"
+	r.ResyncPeriod = 30 * time.Minute
+
+	result, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: types.NamespacedName{Name: "site", Namespace: "web"}})
+	if err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	if result.RequeueAfter != 30*time.Minute {
+		t.Errorf("Expected requeue after the resync period, got %s", result.RequeueAfter)
+	}
+
+	deployment := &appsv1.Deployment{}
+	if err := r.Get(context.Background(), types.NamespacedName{Name: "site-deployment", Namespace: "web"}, deployment); err != nil {
+		t.Fatal(err)
+	}
+	if image := deployment.Spec.Template.Spec.Containers[0].Image; image != "registry.example.com/nginx:1.27" {
+		t.Errorf("Expected configured default image, got %q", image)
+	}
+}
+
+func TestResyncPeriod(t *testing.T) {
+	if got := resyncPeriod(0); got != defaultResyncPeriod {
+		t.Errorf("Expected default resync period, got %s", got)
+	}
+	if got := resyncPeriod(time.Minute); got != time.Minute {
+		t.Errorf("Expected configured resync period, got %s", got)
+	}
+}
+
+func TestReconcileSkipsSuspendedWebserver(t *testing.T) {
+	webserver := &webserverv1alpha1.Webserver{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "site",
+			Namespace:   "web",
+			Annotations: map[string]string{webserverv1alpha1.SuspendAnnotation: "true"},
+		},
+	}
+	r := newTestReconciler(t, webserver)
+
+	req := ctrl.Request{NamespacedName: types.NamespacedName{Name: "site", Namespace: "web"}}
+	if _, err := r.Reconcile(context.Background(), req); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+
+	err := r.Get(context.Background(), types.NamespacedName{Name: "site-deployment", Namespace: "web"}, &appsv1.Deployment{})
+	if !errors.IsNotFound(err) {
+		t.Errorf("Expected no Deployment while suspended, got %v", err)
+	}
+	updated := &webserverv1alpha1.Webserver{}
+	if err := r.Get(context.Background(), req.NamespacedName, updated); err != nil {
+		t.Fatal(err)
+	}
+	condition := meta.FindStatusCondition(updated.Status.Conditions, "Ready")
+	if updated.Status.Phase != "Suspended" || condition == nil || condition.Reason != "Suspended" {
+		t.Errorf("Expected a Suspended phase and condition, got %q and %+v", updated.Status.Phase, condition)
+	}
+}
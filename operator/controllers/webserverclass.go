@@ -0,0 +1,100 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	webserverv1alpha1 "github.com/webserver/webserver-operator/api/v1alpha1"
+)
+
+// classNameField indexes Webservers by spec.className
+const classNameField = ".spec.className"
+
+// resolveClass returns the WebserverClass referenced by the Webserver, or the
+// default class when spec.className is empty. It returns nil when no class
+// applies, in which case the built-in defaults are used.
+func (r *WebserverReconciler) resolveClass(ctx context.Context, webserver *webserverv1alpha1.Webserver) (*webserverv1alpha1.WebserverClass, error) {
+	if webserver.Spec.ClassName != "" {
+		class := &webserverv1alpha1.WebserverClass{}
+		if err := r.Get(ctx, types.NamespacedName{Name: webserver.Spec.ClassName}, class); err != nil {
+			return nil, err
+		}
+		return class, nil
+	}
+
+	classes := &webserverv1alpha1.WebserverClassList{}
+	if err := r.List(ctx, classes); err != nil {
+		return nil, err
+	}
+
+	var defaults []webserverv1alpha1.WebserverClass
+	for _, class := range classes.Items {
+		if class.Annotations[webserverv1alpha1.DefaultClassAnnotation] == "true" {
+			defaults = append(defaults, class)
+		}
+	}
+	switch len(defaults) {
+	case 0:
+		return nil, nil
+	case 1:
+		return &defaults[0], nil
+	default:
+		return nil, fmt.Errorf("%d WebserverClasses are marked as default", len(defaults))
+	}
+}
+
+// classPolicyViolations lists the ways the Webserver breaks its class policy
+func classPolicyViolations(class *webserverv1alpha1.WebserverClass, webserver *webserverv1alpha1.Webserver) []string {
+	if class == nil {
+		return nil
+	}
+
+	var violations []string
+	if !imageAllowed(webserver.Spec.Image, class.Spec.AllowedRegistries) {
+		violations = append(violations, fmt.Sprintf("image %q is not from an allowed registry (%s)",
+			webserver.Spec.Image, strings.Join(class.Spec.AllowedRegistries, ", ")))
+	}
+	if class.Spec.MaxReplicas > 0 && webserver.Spec.Replicas > class.Spec.MaxReplicas {
+		violations = append(violations, fmt.Sprintf("replicas %d exceed the class maximum of %d",
+			webserver.Spec.Replicas, class.Spec.MaxReplicas))
+	}
+	if len(class.Spec.AllowedServiceTypes) > 0 {
+		allowed := false
+		for _, serviceType := range class.Spec.AllowedServiceTypes {
+			if serviceType == webserver.Spec.ServiceType {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			violations = append(violations, fmt.Sprintf("service type %q is not allowed (%s)",
+				webserver.Spec.ServiceType, strings.Join(class.Spec.AllowedServiceTypes, ", ")))
+		}
+	}
+
+	return violations
+}
+
+// webserversForClass maps a WebserverClass event to the Webservers that
+// depend on it. Webservers without a className are always included since the
+// class may have gained or lost the default annotation.
+func (r *WebserverReconciler) webserversForClass(ctx context.Context, obj client.Object) []reconcile.Request {
+	var requests []reconcile.Request
+	for _, name := range []string{obj.GetName(), ""} {
+		webservers := &webserverv1alpha1.WebserverList{}
+		if err := r.List(ctx, webservers, client.MatchingFields{classNameField: name}); err != nil {
+			ctrl.LoggerFrom(ctx).Error(err, "Failed to list Webservers for class", "class", obj.GetName())
+			return nil
+		}
+		for _, webserver := range webservers.Items {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&webserver)})
+		}
+	}
+	return requests
+}
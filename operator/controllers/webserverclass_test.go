@@ -0,0 +1,129 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	webserverv1alpha1 "github.com/webserver/webserver-operator/api/v1alpha1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func newTestReconciler(t *testing.T, objs ...client.Object) *WebserverReconciler {
+	t.Helper()
+	scheme := newTestScheme(t)
+	c := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(objs...).
+		WithStatusSubresource(&webserverv1alpha1.Webserver{}, &webserverv1alpha1.WebserverQuota{}).
+		Build()
+	return &WebserverReconciler{Client: c, Scheme: scheme}
+}
+
+func TestReconcileAppliesDefaultClass(t *testing.T) {
+	class := &webserverv1alpha1.WebserverClass{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "standard",
+			Annotations: map[string]string{webserverv1alpha1.DefaultClassAnnotation: "true"},
+		},
+		Spec: webserverv1alpha1.WebserverClassSpec{
+			Image: "registry.example.com/nginx:1.27",
+			Port:  8080,
+			Resources: &corev1.ResourceRequirements{
+				Limits: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("128Mi")},
+			},
+		},
+	}
+	webserver := &webserverv1alpha1.Webserver{ObjectMeta: metav1.ObjectMeta{Name: "site", Namespace: "web"}}
+	r := newTestReconciler(t, class, webserver)
+
+	if _, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: types.NamespacedName{Name: "site", Namespace: "web"}}); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+
+	deployment := &appsv1.Deployment{}
+	if err := r.Get(context.Background(), types.NamespacedName{Name: "site-deployment", Namespace: "web"}, deployment); err != nil {
+		t.Fatal(err)
+	}
+	container := deployment.Spec.Template.Spec.Containers[0]
+	if container.Image != "registry.example.com/nginx:1.27" {
+		t.Errorf("Expected class image, got %q", container.Image)
+	}
+	if container.Ports[0].ContainerPort != 8080 {
+		t.Errorf("Expected class port 8080, got %d", container.Ports[0].ContainerPort)
+	}
+	if container.Resources.Limits.Memory().String() != "128Mi" {
+		t.Errorf("Expected class memory limit, got %v", container.Resources.Limits)
+	}
+
+	updated := &webserverv1alpha1.Webserver{}
+	if err := r.Get(context.Background(), types.NamespacedName{Name: "site", Namespace: "web"}, updated); err != nil {
+		t.Fatal(err)
+	}
+	if updated.Status.ClassName != "standard" {
+		t.Errorf("Expected status class standard, got %q", updated.Status.ClassName)
+	}
+}
+
+func TestReconcileRejectsClassPolicyViolation(t *testing.T) {
+	class := &webserverv1alpha1.WebserverClass{
+		ObjectMeta: metav1.ObjectMeta{Name: "restricted"},
+		Spec: webserverv1alpha1.WebserverClassSpec{
+			AllowedRegistries:   []string{"docker.io/library/"},
+			MaxReplicas:         2,
+			AllowedServiceTypes: []string{"ClusterIP"},
+		},
+	}
+	webserver := &webserverv1alpha1.Webserver{
+		ObjectMeta: metav1.ObjectMeta{Name: "site", Namespace: "web"},
+		Spec: webserverv1alpha1.WebserverSpec{
+			ClassName:   "restricted",
+			Image:       "ghcr.io/acme/site:latest",
+			Replicas:    3,
+			ServiceType: "LoadBalancer",
+		},
+	}
+	r := newTestReconciler(t, class, webserver)
+
+	if _, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: types.NamespacedName{Name: "site", Namespace: "web"}}); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+
+	updated := &webserverv1alpha1.Webserver{}
+	if err := r.Get(context.Background(), types.NamespacedName{Name: "site", Namespace: "web"}, updated); err != nil {
+		t.Fatal(err)
+	}
+	if updated.Status.Phase != "Failed" {
+		t.Errorf("Expected phase Failed, got %q", updated.Status.Phase)
+	}
+	if violations := classPolicyViolations(class, updated); len(violations) != 3 {
+		t.Errorf("Expected 3 violations, got %v", violations)
+	}
+
+	deployment := &appsv1.Deployment{}
+	err := r.Get(context.Background(), types.NamespacedName{Name: "site-deployment", Namespace: "web"}, deployment)
+	if err == nil {
+		t.Error("Expected no deployment for a Webserver violating its class policy")
+	}
+}
+
+func TestNormalizeImage(t *testing.T) {
+	tests := map[string]string{
+		"nginx:1.25":                      "docker.io/library/nginx:1.25",
+		"bitnami/nginx":                   "docker.io/bitnami/nginx",
+		"ghcr.io/acme/site:v1":            "ghcr.io/acme/site:v1",
+		"localhost/site":                  "localhost/site",
+		"registry.local:5000/site@sha256": "registry.local:5000/site@sha256",
+	}
+	for image, want := range tests {
+		if got := normalizeImage(image); got != want {
+			t.Errorf("normalizeImage(%q) = %q, want %q", image, got, want)
+		}
+	}
+}
@@ -0,0 +1,511 @@
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/util/workqueue"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	webserverv1alpha1 "github.com/webserver/webserver-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// setLabel links a child Webserver to the WebserverSet that created it
+	setLabel = "webserver.io/set"
+
+	// setTemplateHashAnnotation records the template revision a child was rendered from
+	setTemplateHashAnnotation = "webserver.io/set-template-hash"
+
+	// maxChildNameLength leaves room in a DNS label for the suffixes of the
+	// objects created for a child Webserver
+	maxChildNameLength = 52
+)
+
+// invalidNameChars matches the runs of characters a child name cannot hold
+var invalidNameChars = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// WebserverSetReconciler reconciles a WebserverSet object
+type WebserverSetReconciler struct {
+	client.Client
+	Scheme *runtime.Scheme
+
+	// ResyncPeriod is how often a settled WebserverSet is reconciled again
+	ResyncPeriod time.Duration
+
+	// MaxConcurrentReconciles is the number of WebserverSets reconciled in parallel
+	MaxConcurrentReconciles int
+
+	// RateLimiter paces the retries of failed reconciles; the controller-runtime
+	// default is used when nil
+	RateLimiter workqueue.TypedRateLimiter[reconcile.Request]
+}
+
+//+kubebuilder:rbac:groups=webserver.io,resources=webserversets,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=webserver.io,resources=webserversets/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=webserver.io,resources=webserversets/finalizers,verbs=update
+//+kubebuilder:rbac:groups=core,resources=namespaces,verbs=get;list;watch
+
+// setTarget is one child Webserver produced by the generators
+type setTarget struct {
+	Namespace string
+	Name      string
+	Values    map[string]string
+}
+
+// Reconcile stamps out, updates and prunes the child Webservers of a
+// WebserverSet, rolling template changes out in waves.
+func (r *WebserverSetReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+
+	// Fetch the WebserverSet instance
+	set := &webserverv1alpha1.WebserverSet{}
+	if err := r.Get(ctx, req.NamespacedName, set); err != nil {
+		if errors.IsNotFound(err) {
+			log.Info("WebserverSet resource not found. Ignoring since object must be deleted")
+			return ctrl.Result{}, nil
+		}
+		log.Error(err, "Failed to get WebserverSet")
+		return ctrl.Result{}, err
+	}
+
+	targets, err := r.generateTargets(ctx, set)
+	if err != nil {
+		log.Error(err, "Failed to generate targets")
+		return ctrl.Result{}, err
+	}
+
+	// Targets that cannot become Webservers are reported instead of failing the set
+	desired := map[types.NamespacedName]*webserverv1alpha1.Webserver{}
+	var keys []types.NamespacedName
+	var invalid []string
+	for _, target := range targets {
+		key := types.NamespacedName{Namespace: target.Namespace, Name: target.Name}
+		if _, ok := desired[key]; ok {
+			continue
+		}
+		if violations := targetViolations(target); len(violations) > 0 {
+			invalid = append(invalid, fmt.Sprintf("%s: %s", key, strings.Join(violations, "; ")))
+			continue
+		}
+		child, err := renderSetChild(set, target)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		desired[key] = child
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+
+	// Fetch the children we own
+	children := &webserverv1alpha1.WebserverList{}
+	if err := r.List(ctx, children, client.MatchingLabels{setLabel: set.Name}); err != nil {
+		log.Error(err, "Failed to list child Webservers")
+		return ctrl.Result{}, err
+	}
+	existing := map[types.NamespacedName]*webserverv1alpha1.Webserver{}
+	for i := range children.Items {
+		child := &children.Items[i]
+		if metav1.IsControlledBy(child, set) {
+			existing[client.ObjectKeyFromObject(child)] = child
+		}
+	}
+
+	// Prune children that are no longer generated
+	for key, child := range existing {
+		if _, ok := desired[key]; ok {
+			continue
+		}
+		if err := r.Delete(ctx, child); client.IgnoreNotFound(err) != nil {
+			log.Error(err, "Failed to prune child Webserver", "webserver", key)
+			return ctrl.Result{}, err
+		}
+		log.Info("Pruned child Webserver", "webserver", key)
+		delete(existing, key)
+	}
+
+	// Create missing children right away; they cannot disrupt anything
+	var conflicts []string
+	for _, key := range keys {
+		if _, ok := existing[key]; ok {
+			continue
+		}
+		child := desired[key]
+		if err := ctrl.SetControllerReference(set, child, r.Scheme); err != nil {
+			return ctrl.Result{}, err
+		}
+		if err := r.Create(ctx, child); err != nil {
+			if errors.IsAlreadyExists(err) {
+				conflicts = append(conflicts, key.String())
+				continue
+			}
+			// A missing or terminating namespace only fails its own target
+			if errors.IsInvalid(err) || errors.IsNotFound(err) || errors.IsForbidden(err) {
+				invalid = append(invalid, fmt.Sprintf("%s: %v", key, err))
+				continue
+			}
+			log.Error(err, "Failed to create child Webserver", "webserver", key)
+			return ctrl.Result{}, err
+		}
+		log.Info("Created child Webserver", "webserver", key)
+		existing[key] = child
+	}
+
+	// Roll template changes out wave by wave within the unavailability budget
+	currentWave, err := r.rollout(ctx, set, keys, desired, existing)
+	if err != nil {
+		log.Error(err, "Failed to roll out child Webservers")
+		return ctrl.Result{}, err
+	}
+
+	// Aggregate child readiness
+	set.Status.ObservedGeneration = set.Generation
+	set.Status.Desired = int32(len(keys))
+	set.Status.Updated = 0
+	set.Status.Ready = 0
+	set.Status.CurrentWave = int32(currentWave)
+	for _, key := range keys {
+		child, ok := existing[key]
+		if !ok {
+			continue
+		}
+		if child.Annotations[setTemplateHashAnnotation] == desired[key].Annotations[setTemplateHashAnnotation] {
+			set.Status.Updated++
+		}
+		if childReady(child) {
+			set.Status.Ready++
+		}
+	}
+
+	condition := metav1.Condition{
+		Type:               "Ready",
+		Status:             metav1.ConditionTrue,
+		ObservedGeneration: set.Generation,
+		Reason:             "AllChildrenReady",
+		Message:            fmt.Sprintf("%d of %d Webservers ready", set.Status.Ready, set.Status.Desired),
+	}
+	switch {
+	case len(conflicts) > 0:
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = "NameConflict"
+		condition.Message = fmt.Sprintf("Webservers not owned by this set already exist: %s", strings.Join(conflicts, ", "))
+	case len(invalid) > 0:
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = "InvalidTarget"
+		condition.Message = fmt.Sprintf("Targets cannot be created: %s", strings.Join(invalid, ", "))
+	case set.Status.Updated < set.Status.Desired:
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = "RollingOut"
+		condition.Message = fmt.Sprintf("%d of %d Webservers updated, rolling out wave %d",
+			set.Status.Updated, set.Status.Desired, currentWave)
+	case set.Status.Ready < set.Status.Desired:
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = "ChildrenNotReady"
+	}
+	meta.SetStatusCondition(&set.Status.Conditions, condition)
+
+	if err := r.Status().Update(ctx, set); err != nil {
+		log.Error(err, "Failed to update WebserverSet status")
+		return ctrl.Result{}, err
+	}
+
+	return ctrl.Result{RequeueAfter: resyncPeriod(r.ResyncPeriod)}, nil
+}
+
+// rollout updates outdated children in name order. Only children of the
+// earliest unfinished wave are touched, and a ready child is only updated
+// while fewer than maxUnavailable children are unavailable. It returns the
+// wave being rolled out.
+func (r *WebserverSetReconciler) rollout(ctx context.Context, set *webserverv1alpha1.WebserverSet, keys []types.NamespacedName,
+	desired, existing map[types.NamespacedName]*webserverv1alpha1.Webserver) (int, error) {
+	waveSize := int(set.Spec.Rollout.WaveSize)
+	if waveSize <= 0 {
+		waveSize = len(keys)
+	}
+	maxUnavailable := intstr.FromInt32(1)
+	if set.Spec.Rollout.MaxUnavailable != nil {
+		maxUnavailable = *set.Spec.Rollout.MaxUnavailable
+	}
+	budget, err := intstr.GetScaledValueFromIntOrPercent(&maxUnavailable, len(keys), false)
+	if err != nil {
+		return 0, err
+	}
+	if budget < 1 {
+		budget = 1
+	}
+
+	unavailable := 0
+	for _, key := range keys {
+		if child, ok := existing[key]; ok && !childReady(child) {
+			unavailable++
+		}
+	}
+
+	currentWave := -1
+	for i, key := range keys {
+		child, ok := existing[key]
+		if !ok {
+			continue
+		}
+		want := desired[key]
+		updated := child.Annotations[setTemplateHashAnnotation] == want.Annotations[setTemplateHashAnnotation]
+		if updated && childReady(child) {
+			continue
+		}
+
+		wave := i / waveSize
+		if currentWave < 0 {
+			currentWave = wave
+		}
+		if wave != currentWave {
+			break
+		}
+		if updated {
+			// Waiting for this child to become ready
+			continue
+		}
+
+		ready := childReady(child)
+		if ready && unavailable >= budget {
+			continue
+		}
+
+		if child.Labels == nil {
+			child.Labels = map[string]string{}
+		}
+		for k, v := range want.Labels {
+			child.Labels[k] = v
+		}
+		if child.Annotations == nil {
+			child.Annotations = map[string]string{}
+		}
+		for k, v := range want.Annotations {
+			child.Annotations[k] = v
+		}
+		child.Spec = want.Spec
+		if err := r.Update(ctx, child); err != nil {
+			return currentWave, err
+		}
+		log.FromContext(ctx).Info("Updated child Webserver", "webserver", key, "wave", wave)
+		if ready {
+			unavailable++
+		}
+	}
+
+	if currentWave < 0 {
+		currentWave = 0
+		if len(keys) > 0 {
+			currentWave = (len(keys) - 1) / waveSize
+		}
+	}
+	return currentWave, nil
+}
+
+// generateTargets expands all generators into a list of targets
+func (r *WebserverSetReconciler) generateTargets(ctx context.Context, set *webserverv1alpha1.WebserverSet) ([]setTarget, error) {
+	var targets []setTarget
+	for _, generator := range set.Spec.Generators {
+		for _, element := range generator.List {
+			name := element.Name
+			if name == "" {
+				name = set.Name
+			}
+			targets = append(targets, setTarget{Namespace: element.Namespace, Name: name, Values: element.Values})
+		}
+
+		if generator.NamespaceSelector != nil {
+			selector, err := metav1.LabelSelectorAsSelector(generator.NamespaceSelector)
+			if err != nil {
+				return nil, err
+			}
+			namespaces := &corev1.NamespaceList{}
+			if err := r.List(ctx, namespaces, client.MatchingLabelsSelector{Selector: selector}); err != nil {
+				return nil, err
+			}
+			for _, namespace := range namespaces.Items {
+				targets = append(targets, setTarget{Namespace: namespace.Name, Name: set.Name})
+			}
+		}
+
+		if len(generator.Matrix) > 0 {
+			targets = append(targets, matrixTargets(set.Name, generator.Matrix)...)
+		}
+	}
+
+	for i := range targets {
+		values := map[string]string{}
+		for k, v := range targets[i].Values {
+			values[k] = v
+		}
+		values["namespace"] = targets[i].Namespace
+		values["name"] = targets[i].Name
+		targets[i].Values = values
+	}
+	return targets, nil
+}
+
+// matrixTargets builds one target per combination of matrix values. Children
+// are named after the set followed by the values of every key except
+// "namespace", in key order, made DNS-safe by matrixChildName.
+func matrixTargets(setName string, matrix map[string][]string) []setTarget {
+	keys := make([]string, 0, len(matrix))
+	for key := range matrix {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	combinations := []map[string]string{{}}
+	for _, key := range keys {
+		var next []map[string]string
+		for _, combination := range combinations {
+			for _, value := range matrix[key] {
+				values := map[string]string{key: value}
+				for k, v := range combination {
+					values[k] = v
+				}
+				next = append(next, values)
+			}
+		}
+		combinations = next
+	}
+
+	var targets []setTarget
+	for _, values := range combinations {
+		parts := []string{setName}
+		for _, key := range keys {
+			if key != "namespace" {
+				parts = append(parts, values[key])
+			}
+		}
+		name := matrixChildName(parts)
+		namespace := values["namespace"]
+		if namespace == "" {
+			namespace = metav1.NamespaceDefault
+		}
+		targets = append(targets, setTarget{Namespace: namespace, Name: name, Values: values})
+	}
+	return targets
+}
+
+// matrixChildName joins the parts of a child name with dashes. Characters a
+// DNS label cannot hold become dashes; such names and names longer than
+// maxChildNameLength get a hash of the original parts so that different
+// values never share a child.
+func matrixChildName(parts []string) string {
+	raw := strings.ToLower(strings.Join(parts, "-"))
+	name := strings.Trim(invalidNameChars.ReplaceAllString(raw, "-"), "-")
+	if name == raw && len(name) <= maxChildNameLength {
+		return name
+	}
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\x00")))
+	suffix := hex.EncodeToString(sum[:4])
+	if limit := maxChildNameLength - len(suffix) - 1; len(name) > limit {
+		name = strings.TrimRight(name[:limit], "-")
+	}
+	return name + "-" + suffix
+}
+
+// targetViolations explains why a target cannot be created as a Webserver
+func targetViolations(target setTarget) []string {
+	var violations []string
+	for _, msg := range validation.IsDNS1123Label(target.Namespace) {
+		violations = append(violations, fmt.Sprintf("namespace %q: %s", target.Namespace, msg))
+	}
+	for _, msg := range validation.IsDNS1123Label(target.Name) {
+		violations = append(violations, fmt.Sprintf("name %q: %s", target.Name, msg))
+	}
+	return violations
+}
+
+// renderSetChild renders the template for a target and stamps the template
+// hash used to detect outdated children
+func renderSetChild(set *webserverv1alpha1.WebserverSet, target setTarget) (*webserverv1alpha1.Webserver, error) {
+	template := set.Spec.Template.DeepCopy()
+	for key, value := range target.Values {
+		placeholder := "{{" + key + "}}"
+		template.Spec.Config.Title = strings.ReplaceAll(template.Spec.Config.Title, placeholder, value)
+		template.Spec.Config.Message = strings.ReplaceAll(template.Spec.Config.Message, placeholder, value)
+	}
+
+	data, err := json.Marshal(template)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(data)
+
+	child := &webserverv1alpha1.Webserver{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        target.Name,
+			Namespace:   target.Namespace,
+			Labels:      map[string]string{},
+			Annotations: map[string]string{},
+		},
+		Spec: template.Spec,
+	}
+	for k, v := range template.Labels {
+		child.Labels[k] = v
+	}
+	child.Labels[setLabel] = set.Name
+	for k, v := range template.Annotations {
+		child.Annotations[k] = v
+	}
+	child.Annotations[setTemplateHashAnnotation] = hex.EncodeToString(sum[:5])
+	return child, nil
+}
+
+// childReady reports whether a child Webserver has observed its latest spec and is Ready
+func childReady(child *webserverv1alpha1.Webserver) bool {
+	return child.Status.ObservedGeneration == child.Generation &&
+		meta.IsStatusConditionTrue(child.Status.Conditions, "Ready")
+}
+
+// setsForNamespace requeues every WebserverSet using a namespace selector
+// when a namespace changes
+func (r *WebserverSetReconciler) setsForNamespace(ctx context.Context, _ client.Object) []reconcile.Request {
+	sets := &webserverv1alpha1.WebserverSetList{}
+	if err := r.List(ctx, sets); err != nil {
+		log.FromContext(ctx).Error(err, "Failed to list WebserverSets")
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for _, set := range sets.Items {
+		for _, generator := range set.Spec.Generators {
+			if generator.NamespaceSelector != nil {
+				requests = append(requests, reconcile.Request{NamespacedName: types.NamespacedName{Name: set.Name}})
+				break
+			}
+		}
+	}
+	return requests
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *WebserverSetReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&webserverv1alpha1.WebserverSet{}).
+		Owns(&webserverv1alpha1.Webserver{}).
+		Watches(&corev1.Namespace{}, handler.EnqueueRequestsFromMapFunc(r.setsForNamespace)).
+		WithOptions(controller.Options{MaxConcurrentReconciles: r.MaxConcurrentReconciles, RateLimiter: r.RateLimiter}).
+		Complete(r)
+}
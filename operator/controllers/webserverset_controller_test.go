@@ -0,0 +1,275 @@
+package controllers
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	webserverv1alpha1 "github.com/webserver/webserver-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+)
+
+func newTestSetReconciler(t *testing.T, objs ...client.Object) *WebserverSetReconciler {
+	t.Helper()
+	scheme := newTestScheme(t)
+	c := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(objs...).
+		WithStatusSubresource(&webserverv1alpha1.Webserver{}, &webserverv1alpha1.WebserverSet{}).
+		WithInterceptorFuncs(interceptor.Funcs{
+			// The fake client does not bump the generation on spec updates like the API server does
+			Update: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.UpdateOption) error {
+				if webserver, ok := obj.(*webserverv1alpha1.Webserver); ok {
+					webserver.Generation++
+				}
+				return c.Update(ctx, obj, opts...)
+			},
+		}).
+		Build()
+	return &WebserverSetReconciler{Client: c, Scheme: scheme}
+}
+
+func reconcileSet(t *testing.T, r *WebserverSetReconciler, name string) *webserverv1alpha1.WebserverSet {
+	t.Helper()
+	if _, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: types.NamespacedName{Name: name}}); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	set := &webserverv1alpha1.WebserverSet{}
+	if err := r.Get(context.Background(), types.NamespacedName{Name: name}, set); err != nil {
+		t.Fatal(err)
+	}
+	return set
+}
+
+func markChildReady(t *testing.T, r *WebserverSetReconciler, key types.NamespacedName) {
+	t.Helper()
+	child := &webserverv1alpha1.Webserver{}
+	if err := r.Get(context.Background(), key, child); err != nil {
+		t.Fatal(err)
+	}
+	child.Status.ObservedGeneration = child.Generation
+	meta.SetStatusCondition(&child.Status.Conditions, metav1.Condition{Type: "Ready", Status: metav1.ConditionTrue, Reason: "Test"})
+	if err := r.Status().Update(context.Background(), child); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func childTitles(t *testing.T, r *WebserverSetReconciler) map[string]string {
+	t.Helper()
+	children := &webserverv1alpha1.WebserverList{}
+	if err := r.List(context.Background(), children); err != nil {
+		t.Fatal(err)
+	}
+	titles := map[string]string{}
+	for _, child := range children.Items {
+		titles[child.Namespace+"/"+child.Name] = child.Spec.Config.Title
+	}
+	return titles
+}
+
+func TestWebserverSetGeneratesChildren(t *testing.T) {
+	set := &webserverv1alpha1.WebserverSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "site"},
+		Spec: webserverv1alpha1.WebserverSetSpec{
+			Template: webserverv1alpha1.WebserverTemplateSpec{
+				Spec: webserverv1alpha1.WebserverSpec{
+					Config: webserverv1alpha1.WebserverConfig{Title: "{{tenant}} in {{namespace}}"},
+				},
+			},
+			Generators: []webserverv1alpha1.WebserverSetGenerator{
+				{List: []webserverv1alpha1.WebserverSetElement{{Namespace: "acme", Values: map[string]string{"tenant": "Acme"}}}},
+				{NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"tenant": "true"}}},
+				{Matrix: map[string][]string{"namespace": {"shared"}, "tenant": {"globex", "initech"}}},
+			},
+		},
+	}
+	tenant := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "umbrella", Labels: map[string]string{"tenant": "true"}}}
+	other := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "kube-system"}}
+	r := newTestSetReconciler(t, set, tenant, other)
+
+	status := reconcileSet(t, r, "site").Status
+	if status.Desired != 4 {
+		t.Errorf("Expected 4 desired children, got %d", status.Desired)
+	}
+
+	want := map[string]string{
+		"acme/site":           "Acme in acme",
+		"umbrella/site":       "{{tenant}} in umbrella",
+		"shared/site-globex":  "globex in shared",
+		"shared/site-initech": "initech in shared",
+	}
+	titles := childTitles(t, r)
+	if len(titles) != len(want) {
+		t.Fatalf("Expected children %v, got %v", want, titles)
+	}
+	for key, title := range want {
+		if titles[key] != title {
+			t.Errorf("Expected %s to have title %q, got %q", key, title, titles[key])
+		}
+	}
+
+	// Dropping the list generator prunes its child
+	set = reconcileSet(t, r, "site")
+	set.Spec.Generators = set.Spec.Generators[1:]
+	if err := r.Update(context.Background(), set); err != nil {
+		t.Fatal(err)
+	}
+	reconcileSet(t, r, "site")
+	if _, ok := childTitles(t, r)["acme/site"]; ok {
+		t.Error("Expected acme/site to be pruned")
+	}
+}
+
+func TestWebserverSetRollsOutInWaves(t *testing.T) {
+	set := &webserverv1alpha1.WebserverSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "site"},
+		Spec: webserverv1alpha1.WebserverSetSpec{
+			Template: webserverv1alpha1.WebserverTemplateSpec{
+				Spec: webserverv1alpha1.WebserverSpec{Config: webserverv1alpha1.WebserverConfig{Title: "v1"}},
+			},
+			Generators: []webserverv1alpha1.WebserverSetGenerator{
+				{Matrix: map[string][]string{"namespace": {"web"}, "tenant": {"a", "b", "c", "d"}}},
+			},
+			Rollout: webserverv1alpha1.WebserverSetRollout{WaveSize: 2},
+		},
+	}
+	r := newTestSetReconciler(t, set)
+	set = reconcileSet(t, r, "site")
+
+	keys := []types.NamespacedName{
+		{Namespace: "web", Name: "site-a"},
+		{Namespace: "web", Name: "site-b"},
+		{Namespace: "web", Name: "site-c"},
+		{Namespace: "web", Name: "site-d"},
+	}
+	for _, key := range keys {
+		markChildReady(t, r, key)
+	}
+
+	set.Spec.Template.Spec.Config.Title = "v2"
+	if err := r.Update(context.Background(), set); err != nil {
+		t.Fatal(err)
+	}
+
+	steps := []struct {
+		wantUpdated []string
+		wantWave    int32
+	}{
+		{wantUpdated: []string{"site-a"}, wantWave: 0},
+		{wantUpdated: []string{"site-a", "site-b"}, wantWave: 0},
+		{wantUpdated: []string{"site-a", "site-b", "site-c"}, wantWave: 1},
+		{wantUpdated: []string{"site-a", "site-b", "site-c", "site-d"}, wantWave: 1},
+	}
+	for i, step := range steps {
+		reconcileSet(t, r, "site")
+
+		// A second pass must not exceed maxUnavailable while the updated child is not ready
+		status := reconcileSet(t, r, "site").Status
+
+		titles := childTitles(t, r)
+		updated := 0
+		for _, key := range keys {
+			if titles[key.String()] == "v2" {
+				updated++
+			}
+		}
+		if updated != len(step.wantUpdated) {
+			t.Fatalf("Step %d: expected %v updated, got titles %v", i, step.wantUpdated, titles)
+		}
+		for _, name := range step.wantUpdated {
+			if titles["web/"+name] != "v2" {
+				t.Errorf("Step %d: expected %s to be updated", i, name)
+			}
+		}
+		if status.CurrentWave != step.wantWave {
+			t.Errorf("Step %d: expected wave %d, got %d", i, step.wantWave, status.CurrentWave)
+		}
+
+		for _, name := range step.wantUpdated {
+			markChildReady(t, r, types.NamespacedName{Namespace: "web", Name: name})
+		}
+	}
+
+	status := reconcileSet(t, r, "site").Status
+	if status.Updated != 4 || status.Ready != 4 {
+		t.Errorf("Expected all children updated and ready, got %+v", status)
+	}
+	if !meta.IsStatusConditionTrue(status.Conditions, "Ready") {
+		t.Errorf("Expected Ready condition, got %v", status.Conditions)
+	}
+}
+
+func TestWebserverSetReportsInvalidTargets(t *testing.T) {
+	set := &webserverv1alpha1.WebserverSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "site"},
+		Spec: webserverv1alpha1.WebserverSetSpec{
+			Generators: []webserverv1alpha1.WebserverSetGenerator{
+				{Matrix: map[string][]string{"namespace": {"web"}, "release": {"v1.2_beta", "EU West", "stable"}}},
+				{List: []webserverv1alpha1.WebserverSetElement{{Namespace: "Not_A_Namespace"}}},
+			},
+		},
+	}
+	r := newTestSetReconciler(t, set)
+
+	status := reconcileSet(t, r, "site").Status
+	titles := childTitles(t, r)
+	if len(titles) != 3 {
+		t.Fatalf("Expected the matrix children despite the invalid target, got %v", titles)
+	}
+	if _, ok := titles["web/site-stable"]; !ok {
+		t.Errorf("Expected valid values to keep their name, got %v", titles)
+	}
+	for key := range titles {
+		name := key[len("web/"):]
+		if violations := targetViolations(setTarget{Namespace: "web", Name: name}); len(violations) > 0 {
+			t.Errorf("Expected a valid child name, got %q: %v", name, violations)
+		}
+	}
+	if matrixChildName([]string{"site", "eu-west"}) == matrixChildName([]string{"site", "EU West"}) {
+		t.Error("Expected sanitized values to get a distinct name")
+	}
+
+	ready := meta.FindStatusCondition(status.Conditions, "Ready")
+	if ready == nil || ready.Reason != "InvalidTarget" || !strings.Contains(ready.Message, "Not_A_Namespace/site") {
+		t.Errorf("Expected the invalid target to be reported, got %v", status.Conditions)
+	}
+}
+
+func TestWebserverSetReportsMissingNamespace(t *testing.T) {
+	set := &webserverv1alpha1.WebserverSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "site"},
+		Spec: webserverv1alpha1.WebserverSetSpec{
+			Generators: []webserverv1alpha1.WebserverSetGenerator{
+				{List: []webserverv1alpha1.WebserverSetElement{{Namespace: "acme"}, {Namespace: "gone"}, {Namespace: "zeta"}}},
+			},
+		},
+	}
+	r := newTestSetReconciler(t, set)
+	// The fake client does not check that namespaces exist like the API server does
+	r.Client = interceptor.NewClient(r.Client.(client.WithWatch), interceptor.Funcs{
+		Create: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+			if obj.GetNamespace() == "gone" {
+				return apierrors.NewNotFound(corev1.Resource("namespaces"), "gone")
+			}
+			return c.Create(ctx, obj, opts...)
+		},
+	})
+
+	status := reconcileSet(t, r, "site").Status
+	titles := childTitles(t, r)
+	if _, ok := titles["zeta/site"]; !ok || len(titles) != 2 {
+		t.Errorf("Expected the children after the missing namespace to be created, got %v", titles)
+	}
+	ready := meta.FindStatusCondition(status.Conditions, "Ready")
+	if ready == nil || ready.Reason != "InvalidTarget" || !strings.Contains(ready.Message, "gone/site") {
+		t.Errorf("Expected the missing namespace to be reported, got %v", status.Conditions)
+	}
+}
@@ -0,0 +1,480 @@
+// Package config loads and validates the operator configuration file
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// APIVersion is the version of the configuration file format
+	APIVersion = "config.webserver.io/v1alpha1"
+
+	// Kind is the kind of the configuration file
+	Kind = "OperatorConfig"
+)
+
+// Feature gates understood by the operator
+const (
+	// ContentVerification fetches the served page after each rollout
+	ContentVerification = "ContentVerification"
+
+	// StorageVersionMigration rewrites stored Webservers in the storage version at startup
+	StorageVersionMigration = "StorageVersionMigration"
+)
+
+// defaultFeatureGates lists every known feature gate with its default
+var defaultFeatureGates = map[string]bool{
+	ContentVerification:     true,
+	StorageVersionMigration: true,
+}
+
+// OperatorConfig is the operator configuration file
+type OperatorConfig struct {
+	metav1.TypeMeta `json:",inline"`
+
+	// MetricsBindAddress is the address the metric endpoint binds to
+	MetricsBindAddress string `json:"metricsBindAddress,omitempty"`
+
+	// HealthProbeBindAddress is the address the probe endpoint binds to
+	HealthProbeBindAddress string `json:"healthProbeBindAddress,omitempty"`
+
+	// LeaderElection configures leader election between manager replicas
+	LeaderElection LeaderElectionConfig `json:"leaderElection,omitempty"`
+
+	// Cache restricts which Webservers the manager watches
+	Cache CacheConfig `json:"cache,omitempty"`
+
+	// Controller tunes the reconcilers
+	Controller ControllerConfig `json:"controller,omitempty"`
+
+	// Defaults are applied to Webservers that leave fields empty
+	Defaults DefaultsConfig `json:"defaults,omitempty"`
+
+	// ImagePolicy restricts which images Webservers may run
+	ImagePolicy ImagePolicyConfig `json:"imagePolicy,omitempty"`
+
+	// Propagation controls which metadata Webserver children inherit
+	Propagation PropagationConfig `json:"propagation,omitempty"`
+
+	// Tracing exports reconcile spans over OTLP
+	Tracing TracingConfig `json:"tracing,omitempty"`
+
+	// Debug serves pprof and reconcile introspection endpoints
+	Debug DebugConfig `json:"debug,omitempty"`
+
+	// FeatureGates turns optional behaviour on or off
+	FeatureGates map[string]bool `json:"featureGates,omitempty"`
+}
+
+// LeaderElectionConfig configures leader election
+type LeaderElectionConfig struct {
+	// Enabled ensures only one manager replica is active
+	Enabled bool `json:"enabled,omitempty"`
+
+	// ID is the name of the lease used for leader election
+	ID string `json:"id,omitempty"`
+
+	// LeaseDuration is how long non-leaders wait before trying to take over
+	LeaseDuration metav1.Duration `json:"leaseDuration,omitempty"`
+
+	// RenewDeadline is how long the leader retries refreshing the lease
+	RenewDeadline metav1.Duration `json:"renewDeadline,omitempty"`
+
+	// RetryPeriod is how long clients wait between leader election attempts
+	RetryPeriod metav1.Duration `json:"retryPeriod,omitempty"`
+}
+
+// CacheConfig restricts the objects held in the manager cache
+type CacheConfig struct {
+	// Namespaces limits the watched namespaces; all namespaces are watched when empty
+	Namespaces []string `json:"namespaces,omitempty"`
+
+	// LabelSelector limits the watched Webservers to those matching the selector
+	LabelSelector string `json:"labelSelector,omitempty"`
+}
+
+// ControllerConfig tunes the reconcilers
+type ControllerConfig struct {
+	// MaxConcurrentReconciles is the number of objects reconciled in parallel
+	MaxConcurrentReconciles int `json:"maxConcurrentReconciles,omitempty"`
+
+	// ResyncPeriod is how often healthy objects are reconciled again
+	ResyncPeriod metav1.Duration `json:"resyncPeriod,omitempty"`
+
+	// RateLimiter paces the retries of failed reconciles
+	RateLimiter RateLimiterConfig `json:"rateLimiter,omitempty"`
+}
+
+// RateLimiterConfig paces retries with a per-object exponential backoff
+// capped by an overall token bucket
+type RateLimiterConfig struct {
+	// BaseDelay is the backoff after the first failure; it doubles with each
+	// further failure of the same object
+	BaseDelay metav1.Duration `json:"baseDelay,omitempty"`
+
+	// MaxDelay caps the backoff of a single object
+	MaxDelay metav1.Duration `json:"maxDelay,omitempty"`
+
+	// QPS is the overall rate of retries per second
+	QPS float64 `json:"qps,omitempty"`
+
+	// Burst is the number of retries allowed above QPS
+	Burst int `json:"burst,omitempty"`
+}
+
+// DefaultsConfig holds defaults for Webserver fields
+type DefaultsConfig struct {
+	// Image is used when neither the Webserver nor its class sets one
+	Image string `json:"image,omitempty"`
+
+	// MetricsExporterImage runs the metrics sidecar of Webservers with metrics enabled
+	MetricsExporterImage string `json:"metricsExporterImage,omitempty"`
+}
+
+// ImagePolicyConfig is the operator-wide image policy
+type ImagePolicyConfig struct {
+	// AllowedRegistries lists the registry prefixes images may be pulled from
+	AllowedRegistries []string `json:"allowedRegistries,omitempty"`
+
+	// ForbidLatestTag rejects images using the latest tag or no tag
+	ForbidLatestTag bool `json:"forbidLatestTag,omitempty"`
+
+	// ResolveDigests pins image tags to their immutable digests
+	ResolveDigests bool `json:"resolveDigests,omitempty"`
+
+	// InsecureRegistries are contacted over plain HTTP when resolving digests
+	InsecureRegistries []string `json:"insecureRegistries,omitempty"`
+}
+
+// PropagationConfig controls the metadata copied from Webservers to their children
+type PropagationConfig struct {
+	// LabelPrefixes lists the prefixes of Webserver labels copied to every
+	// child, e.g. example.com/ or team; no labels are copied when empty
+	LabelPrefixes []string `json:"labelPrefixes,omitempty"`
+}
+
+// TracingConfig configures the OTLP trace exporter
+type TracingConfig struct {
+	// Endpoint is the host:port of the OTLP/HTTP collector; tracing is off when empty
+	Endpoint string `json:"endpoint,omitempty"`
+
+	// Insecure sends spans over plain HTTP instead of HTTPS
+	Insecure bool `json:"insecure,omitempty"`
+
+	// SamplingRatio is the fraction of reconciles traced, between 0 and 1
+	SamplingRatio float64 `json:"samplingRatio,omitempty"`
+
+	// ServiceName is reported as the service.name resource attribute
+	ServiceName string `json:"serviceName,omitempty"`
+}
+
+// DebugConfig configures the debug server
+type DebugConfig struct {
+	// BindAddress is the address the debug server binds to; it is off when empty
+	BindAddress string `json:"bindAddress,omitempty"`
+}
+
+// Default returns the configuration used when no file is given
+func Default() *OperatorConfig {
+	return &OperatorConfig{
+		TypeMeta:               metav1.TypeMeta{APIVersion: APIVersion, Kind: Kind},
+		MetricsBindAddress:     ":8080",
+		HealthProbeBindAddress: ":8081",
+		LeaderElection: LeaderElectionConfig{
+			ID:            "f1c5ece8.webserver.io",
+			LeaseDuration: metav1.Duration{Duration: 15 * time.Second},
+			RenewDeadline: metav1.Duration{Duration: 10 * time.Second},
+			RetryPeriod:   metav1.Duration{Duration: 2 * time.Second},
+		},
+		Controller: ControllerConfig{
+			MaxConcurrentReconciles: 1,
+			ResyncPeriod:            metav1.Duration{Duration: 5 * time.Minute},
+			RateLimiter: RateLimiterConfig{
+				BaseDelay: metav1.Duration{Duration: 5 * time.Millisecond},
+				MaxDelay:  metav1.Duration{Duration: 1000 * time.Second},
+				QPS:       10,
+				Burst:     100,
+			},
+		},
+		Defaults: DefaultsConfig{
+			Image:                "nginx:1.25",
+			MetricsExporterImage: "nginx/nginx-prometheus-exporter:1.3.0",
+		},
+		Tracing: TracingConfig{
+			SamplingRatio: 1,
+			ServiceName:   "webserver-operator",
+		},
+		// Gates left out keep the defaults reported by Enabled, so a
+		// --feature-gates flag only overrides the gates it names
+		FeatureGates: map[string]bool{},
+	}
+}
+
+// BindFlags registers the command-line flags that override fields of cfg
+func BindFlags(fs *flag.FlagSet, cfg *OperatorConfig) {
+	fs.StringVar(&cfg.MetricsBindAddress, "metrics-bind-address", cfg.MetricsBindAddress, "The address the metric endpoint binds to.")
+	fs.StringVar(&cfg.HealthProbeBindAddress, "health-probe-bind-address", cfg.HealthProbeBindAddress, "The address the probe endpoint binds to.")
+	fs.BoolVar(&cfg.LeaderElection.Enabled, "leader-elect", cfg.LeaderElection.Enabled,
+		"Enable leader election for controller manager. "+
+			"Enabling this will ensure there is only one active controller manager.")
+	fs.DurationVar(&cfg.LeaderElection.LeaseDuration.Duration, "leader-elect-lease-duration", cfg.LeaderElection.LeaseDuration.Duration,
+		"How long non-leaders wait before trying to acquire leadership.")
+	fs.DurationVar(&cfg.LeaderElection.RenewDeadline.Duration, "leader-elect-renew-deadline", cfg.LeaderElection.RenewDeadline.Duration,
+		"How long the leader retries refreshing leadership before giving up.")
+	fs.DurationVar(&cfg.LeaderElection.RetryPeriod.Duration, "leader-elect-retry-period", cfg.LeaderElection.RetryPeriod.Duration,
+		"How long clients wait between leader election attempts.")
+	fs.Var((*listValue)(&cfg.Cache.Namespaces), "namespaces", "Comma-separated namespaces to watch. All namespaces are watched when empty.")
+	fs.StringVar(&cfg.Cache.LabelSelector, "watch-label-selector", cfg.Cache.LabelSelector, "Only watch Webservers matching this label selector.")
+	fs.IntVar(&cfg.Controller.MaxConcurrentReconciles, "max-concurrent-reconciles", cfg.Controller.MaxConcurrentReconciles,
+		"The number of objects each controller reconciles in parallel.")
+	fs.DurationVar(&cfg.Controller.ResyncPeriod.Duration, "resync-period", cfg.Controller.ResyncPeriod.Duration,
+		"How often healthy objects are reconciled again.")
+	fs.DurationVar(&cfg.Controller.RateLimiter.BaseDelay.Duration, "rate-limiter-base-delay", cfg.Controller.RateLimiter.BaseDelay.Duration,
+		"The retry backoff after the first failed reconcile of an object. It doubles with each further failure.")
+	fs.DurationVar(&cfg.Controller.RateLimiter.MaxDelay.Duration, "rate-limiter-max-delay", cfg.Controller.RateLimiter.MaxDelay.Duration,
+		"The longest retry backoff of an object.")
+	fs.Float64Var(&cfg.Controller.RateLimiter.QPS, "rate-limiter-qps", cfg.Controller.RateLimiter.QPS,
+		"The overall number of retries per second of each controller.")
+	fs.IntVar(&cfg.Controller.RateLimiter.Burst, "rate-limiter-burst", cfg.Controller.RateLimiter.Burst,
+		"The number of retries each controller may make above the QPS.")
+	fs.StringVar(&cfg.Defaults.Image, "default-image", cfg.Defaults.Image, "The image used when neither a Webserver nor its class sets one.")
+	fs.StringVar(&cfg.Defaults.MetricsExporterImage, "metrics-exporter-image", cfg.Defaults.MetricsExporterImage,
+		"The image of the metrics exporter sidecar.")
+	fs.Var((*listValue)(&cfg.ImagePolicy.AllowedRegistries), "allowed-registries",
+		"Comma-separated registry prefixes Webserver images may come from, e.g. docker.io/library/. "+
+			"All registries are allowed when empty.")
+	fs.BoolVar(&cfg.ImagePolicy.ForbidLatestTag, "forbid-latest-tag", cfg.ImagePolicy.ForbidLatestTag,
+		"Reject Webserver images using the latest tag or no tag.")
+	fs.BoolVar(&cfg.ImagePolicy.ResolveDigests, "resolve-image-digests", cfg.ImagePolicy.ResolveDigests,
+		"Pin Webserver image tags to their immutable digests.")
+	fs.Var((*listValue)(&cfg.ImagePolicy.InsecureRegistries), "insecure-registries",
+		"Comma-separated registries contacted over plain HTTP when resolving digests.")
+	fs.Var((*listValue)(&cfg.Propagation.LabelPrefixes), "inherited-label-prefixes",
+		"Comma-separated prefixes of Webserver labels copied to the objects created for it, e.g. example.com/,team.")
+	fs.StringVar(&cfg.Tracing.Endpoint, "tracing-endpoint", cfg.Tracing.Endpoint,
+		"The host:port of an OTLP/HTTP collector to send reconcile spans to. Tracing is off when empty.")
+	fs.BoolVar(&cfg.Tracing.Insecure, "tracing-insecure", cfg.Tracing.Insecure, "Send spans over plain HTTP.")
+	fs.Float64Var(&cfg.Tracing.SamplingRatio, "tracing-sampling-ratio", cfg.Tracing.SamplingRatio,
+		"The fraction of reconciles traced, between 0 and 1.")
+	fs.StringVar(&cfg.Debug.BindAddress, "debug-bind-address", cfg.Debug.BindAddress,
+		"The address the pprof and reconcile debug endpoints bind to, e.g. 127.0.0.1:8082. The debug server is off when empty.")
+	fs.Var((*gatesValue)(&cfg.FeatureGates), "feature-gates",
+		"Comma-separated feature gates, e.g. ContentVerification=false. Known gates: "+strings.Join(knownFeatureGates(), ", ")+".")
+}
+
+// Load builds the configuration from the defaults, the file at path when
+// path is set, and finally every flag explicitly set on fs. The result is
+// validated.
+func Load(path string, fs *flag.FlagSet) (*OperatorConfig, error) {
+	cfg := Default()
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("reading config file: %w", err)
+		}
+		if err := yaml.UnmarshalStrict(data, cfg); err != nil {
+			return nil, fmt.Errorf("parsing config file %s: %w", path, err)
+		}
+	}
+
+	// Replay explicitly set flags on top of the file
+	overrides := flag.NewFlagSet("overrides", flag.ContinueOnError)
+	BindFlags(overrides, cfg)
+	var errs []error
+	fs.Visit(func(f *flag.Flag) {
+		if overrides.Lookup(f.Name) == nil {
+			return
+		}
+		if err := overrides.Set(f.Name, f.Value.String()); err != nil {
+			errs = append(errs, fmt.Errorf("flag --%s: %w", f.Name, err))
+		}
+	})
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// Validate reports every problem with the configuration
+func (c *OperatorConfig) Validate() error {
+	var errs []error
+	invalid := func(field, format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf("%s: %s", field, fmt.Sprintf(format, args...)))
+	}
+
+	if c.APIVersion != APIVersion {
+		invalid("apiVersion", "must be %q, got %q", APIVersion, c.APIVersion)
+	}
+	if c.Kind != Kind {
+		invalid("kind", "must be %q, got %q", Kind, c.Kind)
+	}
+
+	le := c.LeaderElection
+	if le.ID == "" {
+		invalid("leaderElection.id", "must not be empty")
+	}
+	if le.LeaseDuration.Duration <= 0 || le.RenewDeadline.Duration <= 0 || le.RetryPeriod.Duration <= 0 {
+		invalid("leaderElection", "leaseDuration, renewDeadline and retryPeriod must be positive")
+	} else {
+		if le.LeaseDuration.Duration <= le.RenewDeadline.Duration {
+			invalid("leaderElection.leaseDuration", "must be greater than renewDeadline (%s), got %s", le.RenewDeadline.Duration, le.LeaseDuration.Duration)
+		}
+		if le.RenewDeadline.Duration <= le.RetryPeriod.Duration {
+			invalid("leaderElection.renewDeadline", "must be greater than retryPeriod (%s), got %s", le.RetryPeriod.Duration, le.RenewDeadline.Duration)
+		}
+	}
+
+	for _, namespace := range c.Cache.Namespaces {
+		if msgs := validation.IsDNS1123Label(namespace); len(msgs) > 0 {
+			invalid("cache.namespaces", "invalid namespace %q: %s", namespace, strings.Join(msgs, "; "))
+		}
+	}
+	if _, err := labels.Parse(c.Cache.LabelSelector); err != nil {
+		invalid("cache.labelSelector", "%v", err)
+	}
+
+	if c.Controller.MaxConcurrentReconciles < 1 {
+		invalid("controller.maxConcurrentReconciles", "must be at least 1, got %d", c.Controller.MaxConcurrentReconciles)
+	}
+	if c.Controller.ResyncPeriod.Duration < time.Second {
+		invalid("controller.resyncPeriod", "must be at least 1s, got %s", c.Controller.ResyncPeriod.Duration)
+	}
+	if limiter := c.Controller.RateLimiter; limiter.BaseDelay.Duration <= 0 {
+		invalid("controller.rateLimiter.baseDelay", "must be positive, got %s", limiter.BaseDelay.Duration)
+	} else if limiter.MaxDelay.Duration < limiter.BaseDelay.Duration {
+		invalid("controller.rateLimiter.maxDelay", "must not be shorter than baseDelay %s, got %s", limiter.BaseDelay.Duration, limiter.MaxDelay.Duration)
+	}
+	if c.Controller.RateLimiter.QPS <= 0 {
+		invalid("controller.rateLimiter.qps", "must be positive, got %g", c.Controller.RateLimiter.QPS)
+	}
+	if c.Controller.RateLimiter.Burst < 1 {
+		invalid("controller.rateLimiter.burst", "must be at least 1, got %d", c.Controller.RateLimiter.Burst)
+	}
+
+	if c.Defaults.Image == "" {
+		invalid("defaults.image", "must not be empty")
+	}
+	if c.Defaults.MetricsExporterImage == "" {
+		invalid("defaults.metricsExporterImage", "must not be empty")
+	}
+
+	for _, registry := range c.ImagePolicy.AllowedRegistries {
+		if strings.TrimSpace(registry) == "" {
+			invalid("imagePolicy.allowedRegistries", "must not contain empty entries")
+		}
+	}
+
+	for _, prefix := range c.Propagation.LabelPrefixes {
+		if strings.TrimSpace(prefix) == "" {
+			invalid("propagation.labelPrefixes", "must not contain empty entries")
+		}
+	}
+
+	if c.Tracing.SamplingRatio < 0 || c.Tracing.SamplingRatio > 1 {
+		invalid("tracing.samplingRatio", "must be between 0 and 1, got %g", c.Tracing.SamplingRatio)
+	}
+	if c.Tracing.Endpoint != "" && c.Tracing.ServiceName == "" {
+		invalid("tracing.serviceName", "must not be empty when tracing is enabled")
+	}
+
+	if c.Debug.BindAddress != "" {
+		if _, _, err := net.SplitHostPort(c.Debug.BindAddress); err != nil {
+			invalid("debug.bindAddress", "%v", err)
+		}
+	}
+
+	for name := range c.FeatureGates {
+		if _, ok := defaultFeatureGates[name]; !ok {
+			invalid("featureGates", "unknown feature gate %q, known gates are %s", name, strings.Join(knownFeatureGates(), ", "))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Enabled reports whether a feature gate is on
+func (c *OperatorConfig) Enabled(gate string) bool {
+	if enabled, ok := c.FeatureGates[gate]; ok {
+		return enabled
+	}
+	return defaultFeatureGates[gate]
+}
+
+// knownFeatureGates returns the sorted names of all feature gates
+func knownFeatureGates() []string {
+	names := make([]string, 0, len(defaultFeatureGates))
+	for name := range defaultFeatureGates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// listValue is a comma-separated list flag
+type listValue []string
+
+func (l *listValue) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *listValue) Set(value string) error {
+	*l = nil
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			*l = append(*l, item)
+		}
+	}
+	return nil
+}
+
+// gatesValue is a comma-separated list of name=bool feature gate flags
+type gatesValue map[string]bool
+
+func (g *gatesValue) String() string {
+	var pairs []string
+	for name, enabled := range *g {
+		pairs = append(pairs, fmt.Sprintf("%s=%t", name, enabled))
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func (g *gatesValue) Set(value string) error {
+	if *g == nil {
+		*g = map[string]bool{}
+	}
+	for _, pair := range strings.Split(value, ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		name, enabled, ok := strings.Cut(pair, "=")
+		if !ok {
+			return fmt.Errorf("feature gate %q must have the form Name=true|false", pair)
+		}
+		switch enabled {
+		case "true":
+			(*g)[name] = true
+		case "false":
+			(*g)[name] = false
+		default:
+			return fmt.Errorf("feature gate %q must be true or false, got %q", name, enabled)
+		}
+	}
+	return nil
+}
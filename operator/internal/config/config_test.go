@@ -0,0 +1,240 @@
+package config
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func newFlagSet(args ...string) (*flag.FlagSet, error) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	BindFlags(fs, Default())
+	return fs, fs.Parse(args)
+}
+
+func TestLoadDefaults(t *testing.T) {
+	fs, err := newFlagSet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := Load("", fs)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.Controller.ResyncPeriod.Duration != 5*time.Minute {
+		t.Errorf("Expected default resync period 5m, got %s", cfg.Controller.ResyncPeriod.Duration)
+	}
+	if cfg.Defaults.Image != "nginx:1.25" {
+		t.Errorf("Expected default image nginx:1.25, got %q", cfg.Defaults.Image)
+	}
+	if !cfg.Enabled(ContentVerification) || !cfg.Enabled(StorageVersionMigration) {
+		t.Errorf("Expected feature gates enabled by default, got %v", cfg.FeatureGates)
+	}
+}
+
+func TestLoadFileWithFlagOverrides(t *testing.T) {
+	path := writeConfig(t, `
+apiVersion: config.webserver.io/v1alpha1
+kind: OperatorConfig
+cache:
+  namespaces: [web, staging]
+  labelSelector: team=web
+controller:
+  maxConcurrentReconciles: 4
+  resyncPeriod: 10m
+defaults:
+  image: registry.example.com/nginx:1.27
+imagePolicy:
+  allowedRegistries: [registry.example.com/]
+featureGates:
+  ContentVerification: false
+`)
+	fs, err := newFlagSet("--max-concurrent-reconciles=8", "--namespaces=prod")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := Load(path, fs)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.Controller.MaxConcurrentReconciles != 8 {
+		t.Errorf("Expected flag to override maxConcurrentReconciles, got %d", cfg.Controller.MaxConcurrentReconciles)
+	}
+	if strings.Join(cfg.Cache.Namespaces, ",") != "prod" {
+		t.Errorf("Expected flag to override namespaces, got %v", cfg.Cache.Namespaces)
+	}
+	if cfg.Cache.LabelSelector != "team=web" {
+		t.Errorf("Expected label selector from file, got %q", cfg.Cache.LabelSelector)
+	}
+	if cfg.Controller.ResyncPeriod.Duration != 10*time.Minute {
+		t.Errorf("Expected resync period from file, got %s", cfg.Controller.ResyncPeriod.Duration)
+	}
+	if cfg.Defaults.Image != "registry.example.com/nginx:1.27" {
+		t.Errorf("Expected default image from file, got %q", cfg.Defaults.Image)
+	}
+	if cfg.Enabled(ContentVerification) {
+		t.Error("Expected ContentVerification to be disabled by the file")
+	}
+	if !cfg.Enabled(StorageVersionMigration) {
+		t.Error("Expected gates missing from the file to keep their default")
+	}
+	if cfg.LeaderElection.LeaseDuration.Duration != 15*time.Second {
+		t.Errorf("Expected default lease duration, got %s", cfg.LeaderElection.LeaseDuration.Duration)
+	}
+}
+
+func TestLoadRejectsUnknownFields(t *testing.T) {
+	path := writeConfig(t, `
+apiVersion: config.webserver.io/v1alpha1
+kind: OperatorConfig
+controller:
+  maxConcurentReconciles: 4
+`)
+	fs, err := newFlagSet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Load(path, fs); err == nil || !strings.Contains(err.Error(), "maxConcurentReconciles") {
+		t.Errorf("Expected error naming the unknown field, got %v", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		mutate  func(*OperatorConfig)
+		wantErr string
+	}{
+		{
+			name:   "defaults",
+			mutate: func(*OperatorConfig) {},
+		},
+		{
+			name:    "wrong apiVersion",
+			mutate:  func(c *OperatorConfig) { c.APIVersion = "config.webserver.io/v2" },
+			wantErr: "apiVersion",
+		},
+		{
+			name:    "wrong kind",
+			mutate:  func(c *OperatorConfig) { c.Kind = "ManagerConfig" },
+			wantErr: "kind",
+		},
+		{
+			name:    "no reconcilers",
+			mutate:  func(c *OperatorConfig) { c.Controller.MaxConcurrentReconciles = 0 },
+			wantErr: "controller.maxConcurrentReconciles",
+		},
+		{
+			name:    "resync too short",
+			mutate:  func(c *OperatorConfig) { c.Controller.ResyncPeriod.Duration = time.Millisecond },
+			wantErr: "controller.resyncPeriod",
+		},
+		{
+			name:    "max delay below base delay",
+			mutate:  func(c *OperatorConfig) { c.Controller.RateLimiter.MaxDelay.Duration = time.Millisecond },
+			wantErr: "controller.rateLimiter.maxDelay",
+		},
+		{
+			name:    "no retry rate",
+			mutate:  func(c *OperatorConfig) { c.Controller.RateLimiter.QPS = 0 },
+			wantErr: "controller.rateLimiter.qps",
+		},
+		{
+			name:    "renew deadline beyond lease",
+			mutate:  func(c *OperatorConfig) { c.LeaderElection.RenewDeadline.Duration = time.Minute },
+			wantErr: "leaderElection.leaseDuration",
+		},
+		{
+			name:    "invalid namespace",
+			mutate:  func(c *OperatorConfig) { c.Cache.Namespaces = []string{"Web_Team"} },
+			wantErr: "cache.namespaces",
+		},
+		{
+			name:    "invalid selector",
+			mutate:  func(c *OperatorConfig) { c.Cache.LabelSelector = "team in (web" },
+			wantErr: "cache.labelSelector",
+		},
+		{
+			name:    "empty default image",
+			mutate:  func(c *OperatorConfig) { c.Defaults.Image = "" },
+			wantErr: "defaults.image",
+		},
+		{
+			name:    "sampling ratio above one",
+			mutate:  func(c *OperatorConfig) { c.Tracing.SamplingRatio = 1.5 },
+			wantErr: "tracing.samplingRatio",
+		},
+		{
+			name:    "empty label prefix",
+			mutate:  func(c *OperatorConfig) { c.Propagation.LabelPrefixes = []string{"example.com/", " "} },
+			wantErr: "propagation.labelPrefixes",
+		},
+		{
+			name:    "debug address without port",
+			mutate:  func(c *OperatorConfig) { c.Debug.BindAddress = "localhost" },
+			wantErr: "debug.bindAddress",
+		},
+		{
+			name:    "unknown feature gate",
+			mutate:  func(c *OperatorConfig) { c.FeatureGates["Teleport"] = true },
+			wantErr: `unknown feature gate "Teleport"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := Default()
+			tt.mutate(cfg)
+			err := cfg.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Expected no error, got %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestFeatureGatesFlag(t *testing.T) {
+	path := writeConfig(t, `
+apiVersion: config.webserver.io/v1alpha1
+kind: OperatorConfig
+featureGates:
+  StorageVersionMigration: false
+`)
+	fs, err := newFlagSet("--feature-gates=ContentVerification=false")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := Load(path, fs)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if cfg.Enabled(ContentVerification) {
+		t.Error("Expected ContentVerification to be disabled by the flag")
+	}
+	if cfg.Enabled(StorageVersionMigration) {
+		t.Error("Expected the flag to leave gates it does not name as set in the file")
+	}
+
+	if _, err := newFlagSet("--feature-gates=ContentVerification=maybe"); err == nil {
+		t.Error("Expected an error for a non-boolean feature gate")
+	}
+}
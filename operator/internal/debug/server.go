@@ -0,0 +1,190 @@
+// Package debug serves pprof and reconcile introspection endpoints of the manager
+package debug
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/webserver/webserver-operator/controllers"
+)
+
+// workqueueDepthMetric is the gauge controller-runtime reports queue depths in
+const workqueueDepthMetric = "workqueue_depth"
+
+// Server serves the debug endpoints:
+//
+//	/debug/pprof/     runtime profiles
+//	/debug/reconciles the last reconcile of every Webserver and the workqueue depths
+//	/debug/reconcile  POST ?namespace=&name= queues a reconcile of one Webserver
+type Server struct {
+	// BindAddress is the address the server listens on
+	BindAddress string
+
+	// History holds the last reconcile of every Webserver
+	History *controllers.ReconcileHistory
+
+	// ForceReconcile queues a reconcile of a Webserver
+	ForceReconcile func(ctx context.Context, key types.NamespacedName) error
+
+	// Gatherer supplies the workqueue metrics
+	Gatherer prometheus.Gatherer
+
+	// Elected is closed once this replica leads; only the leader reconciles,
+	// so standbys refuse forced reconciles. A nil channel means always leading.
+	Elected <-chan struct{}
+}
+
+// Reconciles is the body of /debug/reconciles
+type Reconciles struct {
+	// Webservers lists the last reconcile of every Webserver
+	Webservers []controllers.ReconcileRecord `json:"webservers"`
+
+	// Workqueues maps each controller to the number of objects waiting in its queue
+	Workqueues map[string]int `json:"workqueues"`
+
+	// Leader reports whether this replica leads; standbys reconcile nothing
+	Leader bool `json:"leader"`
+}
+
+// Handler returns the mux serving the debug endpoints
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.HandleFunc("/debug/reconciles", s.reconciles)
+	mux.HandleFunc("/debug/reconcile", s.reconcile)
+	return mux
+}
+
+// Start serves the debug endpoints until ctx is done
+func (s *Server) Start(ctx context.Context) error {
+	listener, err := net.Listen("tcp", s.BindAddress)
+	if err != nil {
+		return err
+	}
+	server := &http.Server{Handler: s.Handler(), ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			log.FromContext(ctx).Error(err, "Failed to shut down the debug server")
+		}
+	}()
+
+	log.FromContext(ctx).Info("Serving debug endpoints", "address", listener.Addr().String())
+	if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// NeedLeaderElection lets every manager replica serve the debug endpoints
+func (s *Server) NeedLeaderElection() bool {
+	return false
+}
+
+// leader reports whether this replica has been elected
+func (s *Server) leader() bool {
+	if s.Elected == nil {
+		return true
+	}
+	select {
+	case <-s.Elected:
+		return true
+	default:
+		return false
+	}
+}
+
+// reconciles reports the reconcile history and the workqueue depths
+func (s *Server) reconciles(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		http.Error(w, "only GET is allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	body := Reconciles{Webservers: s.History.Records(), Workqueues: map[string]int{}, Leader: s.leader()}
+	if body.Webservers == nil {
+		body.Webservers = []controllers.ReconcileRecord{}
+	}
+	if s.Gatherer != nil {
+		depths, err := workqueueDepths(s.Gatherer)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		body.Workqueues = depths
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	_ = encoder.Encode(body)
+}
+
+// reconcile queues a reconcile of the Webserver named in the query
+func (s *Server) reconcile(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		http.Error(w, "only POST is allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	key := types.NamespacedName{Namespace: req.URL.Query().Get("namespace"), Name: req.URL.Query().Get("name")}
+	if key.Namespace == "" || key.Name == "" {
+		http.Error(w, "namespace and name are required", http.StatusBadRequest)
+		return
+	}
+	if !s.leader() {
+		http.Error(w, "this replica is not the leader; forward to the leader replica", http.StatusServiceUnavailable)
+		return
+	}
+
+	err := s.ForceReconcile(req.Context(), key)
+	switch {
+	case apierrors.IsNotFound(err):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, controllers.ErrForcedReconcileBusy):
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	default:
+		log.FromContext(req.Context()).Info("Forced reconcile", "webserver", key)
+		w.WriteHeader(http.StatusAccepted)
+	}
+}
+
+// workqueueDepths sums the queue depth of every controller over all priorities
+func workqueueDepths(gatherer prometheus.Gatherer) (map[string]int, error) {
+	families, err := gatherer.Gather()
+	if err != nil {
+		return nil, err
+	}
+	depths := map[string]int{}
+	for _, family := range families {
+		if family.GetName() != workqueueDepthMetric {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			name := ""
+			for _, label := range metric.GetLabel() {
+				if label.GetName() == "name" {
+					name = label.GetValue()
+				}
+			}
+			depths[name] += int(metric.GetGauge().GetValue())
+		}
+	}
+	return depths, nil
+}
@@ -0,0 +1,146 @@
+package debug
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	webserverv1alpha1 "github.com/webserver/webserver-operator/api/v1alpha1"
+	"github.com/webserver/webserver-operator/controllers"
+)
+
+func TestReconcilesEndpoint(t *testing.T) {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := webserverv1alpha1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	c := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(&webserverv1alpha1.Webserver{ObjectMeta: metav1.ObjectMeta{Name: "site", Namespace: "web"}}).
+		WithStatusSubresource(&webserverv1alpha1.Webserver{}).
+		Build()
+	r := &controllers.WebserverReconciler{Client: c, Scheme: scheme, History: controllers.NewReconcileHistory()}
+	if _, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: types.NamespacedName{Name: "site", Namespace: "web"}}); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+
+	registry := prometheus.NewRegistry()
+	depth := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: workqueueDepthMetric}, []string{"name", "controller", "priority"})
+	registry.MustRegister(depth)
+	depth.WithLabelValues("webserver", "webserver", "").Set(2)
+	depth.WithLabelValues("webserver", "webserver", "-100").Set(1)
+	depth.WithLabelValues("webserverquota", "webserverquota", "").Set(0)
+
+	s := &Server{History: r.History, Gatherer: registry}
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/reconciles", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body)
+	}
+	var body Reconciles
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if len(body.Webservers) != 1 || body.Webservers[0].Name != "site" || body.Webservers[0].Result == "" {
+		t.Errorf("Expected the reconcile of site, got %+v", body.Webservers)
+	}
+	if body.Workqueues["webserver"] != 3 || body.Workqueues["webserverquota"] != 0 {
+		t.Errorf("Expected the depths summed over priorities, got %v", body.Workqueues)
+	}
+}
+
+func TestStandbyRefusesForcedReconcile(t *testing.T) {
+	elected := make(chan struct{})
+	forced := 0
+	s := &Server{
+		History: controllers.NewReconcileHistory(),
+		Elected: elected,
+		ForceReconcile: func(context.Context, types.NamespacedName) error {
+			forced++
+			return nil
+		},
+	}
+
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/debug/reconcile?namespace=web&name=site", nil))
+	if rec.Code != http.StatusServiceUnavailable || !strings.Contains(rec.Body.String(), "not the leader") || forced != 0 {
+		t.Errorf("Expected a standby to refuse the reconcile, got %d: %s", rec.Code, rec.Body)
+	}
+	rec = httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/reconciles", nil))
+	var body Reconciles
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if body.Leader {
+		t.Error("Expected a standby to report it does not lead")
+	}
+
+	close(elected)
+	rec = httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/debug/reconcile?namespace=web&name=site", nil))
+	if rec.Code != http.StatusAccepted || forced != 1 {
+		t.Errorf("Expected the leader to queue the reconcile, got %d: %s", rec.Code, rec.Body)
+	}
+}
+
+func TestReconcileEndpoint(t *testing.T) {
+	var forced []types.NamespacedName
+	s := &Server{ForceReconcile: func(_ context.Context, key types.NamespacedName) error {
+		switch key.Name {
+		case "missing":
+			return apierrors.NewNotFound(schema.GroupResource{Group: "webserver.io", Resource: "webservers"}, key.Name)
+		case "busy":
+			return controllers.ErrForcedReconcileBusy
+		}
+		forced = append(forced, key)
+		return nil
+	}}
+
+	tests := []struct {
+		method string
+		target string
+		want   int
+	}{
+		{http.MethodPost, "/debug/reconcile?namespace=web&name=site", http.StatusAccepted},
+		{http.MethodGet, "/debug/reconcile?namespace=web&name=site", http.StatusMethodNotAllowed},
+		{http.MethodPost, "/debug/reconcile?name=site", http.StatusBadRequest},
+		{http.MethodPost, "/debug/reconcile?namespace=web&name=missing", http.StatusNotFound},
+		{http.MethodPost, "/debug/reconcile?namespace=web&name=busy", http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		s.Handler().ServeHTTP(rec, httptest.NewRequest(tt.method, tt.target, nil))
+		if rec.Code != tt.want {
+			t.Errorf("%s %s: expected status %d, got %d", tt.method, tt.target, tt.want, rec.Code)
+		}
+	}
+	if len(forced) != 1 || forced[0] != (types.NamespacedName{Namespace: "web", Name: "site"}) {
+		t.Errorf("Expected one forced reconcile of web/site, got %v", forced)
+	}
+}
+
+func TestPprofEndpoint(t *testing.T) {
+	rec := httptest.NewRecorder()
+	(&Server{}).Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/pprof/", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected the pprof index, got status %d", rec.Code)
+	}
+}
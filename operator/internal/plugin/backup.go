@@ -0,0 +1,432 @@
+package plugin
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/yaml"
+
+	webserverv1alpha1 "github.com/webserver/webserver-operator/api/v1alpha1"
+)
+
+const (
+	// backupVersion is the archive format written by Backup and read by Restore
+	backupVersion = 1
+
+	// manifestPath is the archive entry listing the objects and their checksums
+	manifestPath = "manifest.json"
+)
+
+// BackupManifest describes the contents of a backup archive
+type BackupManifest struct {
+	// Version is the archive format
+	Version int `json:"version"`
+
+	// Created is when the backup was taken
+	Created metav1.Time `json:"created"`
+
+	// Namespace is the namespace backed up; all namespaces when empty
+	Namespace string `json:"namespace,omitempty"`
+
+	// Selector is the label selector the Webservers were filtered by
+	Selector string `json:"selector,omitempty"`
+
+	// Objects lists the archived objects in the order they are restored
+	Objects []BackupObject `json:"objects"`
+}
+
+// BackupObject is an object stored in a backup archive
+type BackupObject struct {
+	// Path is the archive entry holding the object as YAML
+	Path string `json:"path"`
+
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+
+	// SHA256 is the hex digest of the entry
+	SHA256 string `json:"sha256"`
+}
+
+// restoreOrder creates the objects Webservers reference before the Webservers
+var restoreOrder = map[string]int{"ConfigMap": 0, "Secret": 1, "Webserver": 2}
+
+// Backup writes the Webservers of the plugin namespace, or of all namespaces,
+// that match selector to a gzip-compressed tar archive at path, together
+// with the ConfigMaps and Secrets they reference. Objects created by the
+// operator are left out since it recreates them from the Webserver.
+func (p *Plugin) Backup(ctx context.Context, path string, allNamespaces bool, selector string) error {
+	parsed, err := labels.Parse(selector)
+	if err != nil {
+		return fmt.Errorf("invalid selector: %w", err)
+	}
+	opts := []client.ListOption{client.MatchingLabelsSelector{Selector: parsed}}
+	namespace := ""
+	if !allNamespaces {
+		namespace = p.Namespace
+		opts = append(opts, client.InNamespace(namespace))
+	}
+	webservers := &webserverv1alpha1.WebserverList{}
+	if err := p.Client.List(ctx, webservers, opts...); err != nil {
+		return err
+	}
+
+	var objects []client.Object
+	seen := map[string]bool{}
+	for i := range webservers.Items {
+		webserver := &webservers.Items[i]
+		for _, obj := range referencedObjects(webserver) {
+			key := client.ObjectKeyFromObject(obj)
+			id := resourceName(obj) + "/" + key.String()
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+			if err := p.Client.Get(ctx, key, obj); err != nil {
+				if apierrors.IsNotFound(err) {
+					fmt.Fprintf(p.Out, "warning: webserver/%s references missing %s/%s\n", webserver.Name, resourceName(obj), key.Name)
+					continue
+				}
+				return err
+			}
+			if metav1.IsControlledBy(obj, webserver) {
+				continue
+			}
+			objects = append(objects, obj)
+		}
+		objects = append(objects, webserver)
+	}
+
+	manifest := BackupManifest{
+		Version:   backupVersion,
+		Created:   metav1.NewTime(p.now().UTC()),
+		Namespace: namespace,
+		Selector:  selector,
+		Objects:   []BackupObject{},
+	}
+	entries := map[string][]byte{}
+	for _, obj := range objects {
+		data, err := p.exportObject(obj)
+		if err != nil {
+			return err
+		}
+		kind := obj.GetObjectKind().GroupVersionKind().Kind
+		entry := BackupObject{
+			Path:      fmt.Sprintf("%s/%s/%s.yaml", obj.GetNamespace(), resourceName(obj), obj.GetName()),
+			Kind:      kind,
+			Namespace: obj.GetNamespace(),
+			Name:      obj.GetName(),
+			SHA256:    checksum(data),
+		}
+		manifest.Objects = append(manifest.Objects, entry)
+		entries[entry.Path] = data
+	}
+	sort.SliceStable(manifest.Objects, func(i, j int) bool {
+		return restoreOrder[manifest.Objects[i].Kind] < restoreOrder[manifest.Objects[j].Kind]
+	})
+
+	if err := writeArchive(path, manifest, entries); err != nil {
+		return err
+	}
+	fmt.Fprintf(p.Out, "%d objects of %d Webservers written to %s\n", len(manifest.Objects), len(webservers.Items), path)
+	return nil
+}
+
+// Restore creates the objects of the backup archive at path. Namespaces are
+// renamed according to namespaceMap. Existing objects are left untouched.
+// With dryRun the API server validates the objects without persisting them.
+func (p *Plugin) Restore(ctx context.Context, path string, namespaceMap map[string]string, dryRun bool) error {
+	manifest, entries, err := readArchive(path)
+	if err != nil {
+		return err
+	}
+
+	decoder := serializer.NewCodecFactory(p.Client.Scheme()).UniversalDeserializer()
+	var opts []client.CreateOption
+	suffix := ""
+	if dryRun {
+		opts = append(opts, client.DryRunAll)
+		suffix = " (dry run)"
+	}
+	var errs []error
+	for _, entry := range manifest.Objects {
+		decoded, _, err := decoder.Decode(entries[entry.Path], nil, nil)
+		if err != nil {
+			return fmt.Errorf("%s: %w", entry.Path, err)
+		}
+		obj, ok := decoded.(client.Object)
+		if !ok || decoded.GetObjectKind().GroupVersionKind().Kind != entry.Kind {
+			return fmt.Errorf("%s does not contain a %s", entry.Path, entry.Kind)
+		}
+		if namespace, ok := namespaceMap[obj.GetNamespace()]; ok {
+			obj.SetNamespace(namespace)
+		}
+
+		name := fmt.Sprintf("%s/%s", strings.ToLower(entry.Kind), obj.GetName())
+		err = p.Client.Create(ctx, obj, opts...)
+		switch {
+		case apierrors.IsAlreadyExists(err):
+			fmt.Fprintf(p.Out, "%s already exists in %s, skipped%s\n", name, obj.GetNamespace(), suffix)
+		case err != nil:
+			errs = append(errs, fmt.Errorf("%s in %s: %w", name, obj.GetNamespace(), err))
+		default:
+			fmt.Fprintf(p.Out, "%s created in %s%s\n", name, obj.GetNamespace(), suffix)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// referencedObjects returns the ConfigMaps and then the Secrets the
+// Webserver reads through environment variables and volumes, with only
+// their keys set
+func referencedObjects(webserver *webserverv1alpha1.Webserver) []client.Object {
+	var configMapNames, secretNames []string
+	addConfigMap := func(name string) {
+		if name != "" {
+			configMapNames = append(configMapNames, name)
+		}
+	}
+	addSecret := func(name string) {
+		if name != "" {
+			secretNames = append(secretNames, name)
+		}
+	}
+	addEnv := func(env []corev1.EnvVar, envFrom []corev1.EnvFromSource) {
+		for _, source := range envFrom {
+			if source.ConfigMapRef != nil {
+				addConfigMap(source.ConfigMapRef.Name)
+			}
+			if source.SecretRef != nil {
+				addSecret(source.SecretRef.Name)
+			}
+		}
+		for _, variable := range env {
+			if variable.ValueFrom == nil {
+				continue
+			}
+			if ref := variable.ValueFrom.ConfigMapKeyRef; ref != nil {
+				addConfigMap(ref.Name)
+			}
+			if ref := variable.ValueFrom.SecretKeyRef; ref != nil {
+				addSecret(ref.Name)
+			}
+		}
+	}
+
+	addEnv(webserver.Spec.Env, webserver.Spec.EnvFrom)
+	for _, container := range append(append([]corev1.Container{}, webserver.Spec.InitContainers...), webserver.Spec.ExtraContainers...) {
+		addEnv(container.Env, container.EnvFrom)
+	}
+	for _, volume := range webserver.Spec.ExtraVolumes {
+		if volume.ConfigMap != nil {
+			addConfigMap(volume.ConfigMap.Name)
+		}
+		if volume.Secret != nil {
+			addSecret(volume.Secret.SecretName)
+		}
+		if volume.Projected != nil {
+			for _, source := range volume.Projected.Sources {
+				if source.ConfigMap != nil {
+					addConfigMap(source.ConfigMap.Name)
+				}
+				if source.Secret != nil {
+					addSecret(source.Secret.Name)
+				}
+			}
+		}
+	}
+
+	var objects []client.Object
+	for _, name := range dedupe(configMapNames) {
+		objects = append(objects, &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: webserver.Namespace}})
+	}
+	for _, name := range dedupe(secretNames) {
+		objects = append(objects, &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: webserver.Namespace}})
+	}
+	return objects
+}
+
+// dedupe drops repeated names, keeping the first occurrence
+func dedupe(names []string) []string {
+	seen := map[string]bool{}
+	var unique []string
+	for _, name := range names {
+		if !seen[name] {
+			seen[name] = true
+			unique = append(unique, name)
+		}
+	}
+	return unique
+}
+
+// resourceName returns the plural resource of an archived kind
+func resourceName(obj client.Object) string {
+	switch obj.(type) {
+	case *corev1.ConfigMap:
+		return "configmaps"
+	case *corev1.Secret:
+		return "secrets"
+	default:
+		return "webservers"
+	}
+}
+
+// exportObject returns the object as YAML without the fields the cluster
+// assigns, so that it can be created in another cluster
+func (p *Plugin) exportObject(obj client.Object) ([]byte, error) {
+	gvk, err := apiutil.GVKForObject(obj, p.Client.Scheme())
+	if err != nil {
+		return nil, err
+	}
+	obj.GetObjectKind().SetGroupVersionKind(gvk)
+	obj = obj.DeepCopyObject().(client.Object)
+	obj.SetUID("")
+	obj.SetResourceVersion("")
+	obj.SetGeneration(0)
+	obj.SetCreationTimestamp(metav1.Time{})
+	obj.SetManagedFields(nil)
+	obj.SetOwnerReferences(nil)
+	if webserver, ok := obj.(*webserverv1alpha1.Webserver); ok {
+		webserver.Status = webserverv1alpha1.WebserverStatus{}
+	}
+	return yaml.Marshal(obj)
+}
+
+// checksum returns the hex SHA-256 digest of data
+func checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// writeArchive writes the manifest followed by the entries it lists. The
+// archive may hold Secrets, so it is only readable by its owner.
+func writeArchive(path string, manifest BackupManifest, entries map[string][]byte) error {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	buf := &bytes.Buffer{}
+	gz := gzip.NewWriter(buf)
+	tw := tar.NewWriter(gz)
+	write := func(name string, data []byte) error {
+		header := &tar.Header{Name: name, Mode: 0o600, Size: int64(len(data)), ModTime: manifest.Created.Time}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		_, err := tw.Write(data)
+		return err
+	}
+	if err := write(manifestPath, data); err != nil {
+		return err
+	}
+	for _, object := range manifest.Objects {
+		if err := write(object.Path, entries[object.Path]); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+	return os.WriteFile(path, buf.Bytes(), 0o600)
+}
+
+// readArchive reads a backup archive and verifies every object listed in the
+// manifest against its checksum
+func readArchive(path string) (*BackupManifest, map[string][]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer file.Close()
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s is not a backup archive: %w", path, err)
+	}
+	tr := tar.NewReader(gz)
+	entries := map[string][]byte{}
+	for {
+		header, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s is not a backup archive: %w", path, err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, nil, err
+		}
+		entries[header.Name] = data
+	}
+
+	data, ok := entries[manifestPath]
+	if !ok {
+		return nil, nil, fmt.Errorf("%s has no %s", path, manifestPath)
+	}
+	manifest := &BackupManifest{}
+	if err := json.Unmarshal(data, manifest); err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", manifestPath, err)
+	}
+	if manifest.Version != backupVersion {
+		return nil, nil, fmt.Errorf("unsupported backup version %d", manifest.Version)
+	}
+	for _, object := range manifest.Objects {
+		data, ok := entries[object.Path]
+		if !ok {
+			return nil, nil, fmt.Errorf("%s is missing from the archive", object.Path)
+		}
+		if checksum(data) != object.SHA256 {
+			return nil, nil, fmt.Errorf("checksum mismatch for %s", object.Path)
+		}
+	}
+	return manifest, entries, nil
+}
+
+// parseNamespaceMap parses comma-separated FROM=TO namespace pairs
+func parseNamespaceMap(value string) (map[string]string, error) {
+	namespaceMap := map[string]string{}
+	if value == "" {
+		return namespaceMap, nil
+	}
+	for _, pair := range strings.Split(value, ",") {
+		from, to, ok := strings.Cut(pair, "=")
+		if !ok || from == "" || to == "" {
+			return nil, fmt.Errorf("invalid namespace mapping %q, expected FROM=TO", pair)
+		}
+		namespaceMap[from] = to
+	}
+	return namespaceMap, nil
+}
+
+// now returns the current time from the injected clock
+func (p *Plugin) now() time.Time {
+	if p.Now != nil {
+		return p.Now()
+	}
+	return time.Now()
+}
@@ -0,0 +1,160 @@
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	webserverv1alpha1 "github.com/webserver/webserver-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// newBackupPlugin returns the test plugin with the site Webserver reading a
+// ConfigMap and a Secret, and a second labelled Webserver
+func newBackupPlugin(t *testing.T) *Plugin {
+	t.Helper()
+	p, _ := newTestPlugin(t)
+	ctx := context.Background()
+	for _, obj := range []client.Object{
+		&corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "site-env", Namespace: "web"}, Data: map[string]string{"MODE": "production"}},
+		&corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "site-tls", Namespace: "web"}, Data: map[string][]byte{"tls.key": []byte("key")}},
+		&webserverv1alpha1.Webserver{ObjectMeta: metav1.ObjectMeta{Name: "docs", Namespace: "web", Labels: map[string]string{"team": "docs"}}},
+	} {
+		if err := p.Client.Create(ctx, obj); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	webserver, err := p.getWebserver(ctx, "site")
+	if err != nil {
+		t.Fatal(err)
+	}
+	patch := client.MergeFrom(webserver.DeepCopy())
+	webserver.Labels = map[string]string{"team": "web"}
+	webserver.Spec.EnvFrom = []corev1.EnvFromSource{{ConfigMapRef: &corev1.ConfigMapEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: "site-env"}}}}
+	webserver.Spec.ExtraVolumes = []corev1.Volume{
+		{Name: "tls", VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{SecretName: "site-tls"}}},
+		{Name: "config", VolumeSource: corev1.VolumeSource{ConfigMap: &corev1.ConfigMapVolumeSource{LocalObjectReference: corev1.LocalObjectReference{Name: "site-config"}}}},
+		{Name: "missing", VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{SecretName: "gone"}}},
+	}
+	if err := p.Client.Patch(ctx, webserver, patch); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestBackupArchive(t *testing.T) {
+	p := newBackupPlugin(t)
+	path := filepath.Join(t.TempDir(), "backup.tar.gz")
+
+	if err := p.Run(context.Background(), []string{"backup", "-o", path, "-l", "team=web"}); err != nil {
+		t.Fatalf("backup error = %v", err)
+	}
+
+	manifest, entries, err := readArchive(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var paths []string
+	for _, object := range manifest.Objects {
+		paths = append(paths, object.Path)
+	}
+	// site-config is created by the operator and docs does not match the selector
+	if strings.Join(paths, ",") != "web/configmaps/site-env.yaml,web/secrets/site-tls.yaml,web/webservers/site.yaml" {
+		t.Errorf("Expected the referenced objects before the Webserver, got %v", paths)
+	}
+	if manifest.Namespace != "web" || manifest.Selector != "team=web" || manifest.Created.IsZero() {
+		t.Errorf("Expected the filters and time in the manifest, got %+v", manifest)
+	}
+	site := string(entries["web/webservers/site.yaml"])
+	if !strings.Contains(site, "kind: Webserver") || strings.Contains(site, "uid:") || strings.Contains(site, "phase:") {
+		t.Errorf("Expected the Webserver without cluster fields and status, got\n%s", site)
+	}
+	if out := p.Out.(*bytes.Buffer).String(); !strings.Contains(out, "references missing secrets/gone") {
+		t.Errorf("Expected a warning about the missing Secret, got %q", out)
+	}
+}
+
+func TestRestoreArchive(t *testing.T) {
+	source := newBackupPlugin(t)
+	path := filepath.Join(t.TempDir(), "backup.tar.gz")
+	if err := source.Run(context.Background(), []string{"backup", "-o", path}); err != nil {
+		t.Fatalf("backup error = %v", err)
+	}
+
+	target, out := newTestPlugin(t)
+	ctx := context.Background()
+	if err := target.Run(ctx, []string{"restore", "-f", path, "--namespace-map", "web=staging", "--dry-run"}); err != nil {
+		t.Fatalf("restore --dry-run error = %v", err)
+	}
+	if err := target.Client.Get(ctx, types.NamespacedName{Name: "site", Namespace: "staging"}, &webserverv1alpha1.Webserver{}); !apierrors.IsNotFound(err) {
+		t.Errorf("Expected a dry run to create nothing, got %v", err)
+	}
+	if !strings.Contains(out.String(), "webserver/site created in staging (dry run)") {
+		t.Errorf("Expected the dry run to be reported, got\n%s", out.String())
+	}
+
+	if err := target.Run(ctx, []string{"restore", "-f", path, "--namespace-map", "web=staging"}); err != nil {
+		t.Fatalf("restore error = %v", err)
+	}
+	webserver := &webserverv1alpha1.Webserver{}
+	if err := target.Client.Get(ctx, types.NamespacedName{Name: "site", Namespace: "staging"}, webserver); err != nil {
+		t.Fatal(err)
+	}
+	if len(webserver.Spec.EnvFrom) != 1 || webserver.Status.Phase != "" {
+		t.Errorf("Expected the spec without the status, got %+v", webserver)
+	}
+	secret := &corev1.Secret{}
+	if err := target.Client.Get(ctx, types.NamespacedName{Name: "site-tls", Namespace: "staging"}, secret); err != nil {
+		t.Fatal(err)
+	}
+	if string(secret.Data["tls.key"]) != "key" {
+		t.Errorf("Expected the Secret data to be restored, got %v", secret.Data)
+	}
+
+	// Restoring into the original namespace leaves the existing Webserver alone
+	out.Reset()
+	if err := target.Run(ctx, []string{"restore", "-f", path}); err != nil {
+		t.Fatalf("restore error = %v", err)
+	}
+	if !strings.Contains(out.String(), "webserver/site already exists in web, skipped") {
+		t.Errorf("Expected the existing Webserver to be skipped, got\n%s", out.String())
+	}
+}
+
+func TestRestoreRejectsTamperedArchive(t *testing.T) {
+	p := newBackupPlugin(t)
+	path := filepath.Join(t.TempDir(), "backup.tar.gz")
+	if err := p.Run(context.Background(), []string{"backup", "-o", path}); err != nil {
+		t.Fatalf("backup error = %v", err)
+	}
+	manifest, entries, err := readArchive(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries["web/webservers/site.yaml"] = append(entries["web/webservers/site.yaml"], []byte("  replicas: 10\n")...)
+	if err := writeArchive(path, *manifest, entries); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := p.Run(context.Background(), []string{"restore", "-f", path}); err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Errorf("Expected a checksum mismatch, got %v", err)
+	}
+}
+
+func TestBackupUsage(t *testing.T) {
+	p, _ := newTestPlugin(t)
+
+	for _, args := range [][]string{{"backup"}, {"backup", "site", "-o", "x"}, {"restore"}, {"restore", "-f", "x", "--namespace-map", "web"}} {
+		if err := p.Run(context.Background(), args); !errors.Is(err, ErrUsage) {
+			t.Errorf("Expected a usage error for %v, got %v", args, err)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Open port-forwards localPort to a ready pod of the Webserver, prints the
+// URL and keeps forwarding until ctx is done
+func (p *Plugin) Open(ctx context.Context, name string, localPort int) error {
+	webserver, err := p.getWebserver(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	pods := &corev1.PodList{}
+	if err := p.Client.List(ctx, pods, client.InNamespace(webserver.Namespace),
+		client.MatchingLabels{"app": "webserver", "instance": webserver.Name}); err != nil {
+		return err
+	}
+	sort.Slice(pods.Items, func(i, j int) bool { return pods.Items[i].Name < pods.Items[j].Name })
+
+	var pod *corev1.Pod
+	for i := range pods.Items {
+		if podReady(&pods.Items[i]) {
+			pod = &pods.Items[i]
+			break
+		}
+	}
+	if pod == nil {
+		return fmt.Errorf("webserver/%s has no ready pod", name)
+	}
+	remotePort := httpPort(pod)
+	if remotePort == 0 {
+		return fmt.Errorf("pod/%s exposes no http port", pod.Name)
+	}
+
+	forwardCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	ready := make(chan int, 1)
+	done := make(chan error, 1)
+	go func() {
+		done <- p.Forwarder.Forward(forwardCtx, types.NamespacedName{Name: pod.Name, Namespace: pod.Namespace}, localPort, remotePort, ready)
+	}()
+
+	select {
+	case port := <-ready:
+		fmt.Fprintf(p.Out, "Forwarding http://localhost:%d to pod/%s, press Ctrl-C to stop\n", port, pod.Name)
+	case err := <-done:
+		return err
+	}
+	return <-done
+}
+
+// podReady reports whether the pod passes its readiness checks
+func podReady(pod *corev1.Pod) bool {
+	if pod.DeletionTimestamp != nil {
+		return false
+	}
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodReady {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// httpPort returns the http port of the web server container
+func httpPort(pod *corev1.Pod) int {
+	for _, container := range pod.Spec.Containers {
+		for _, port := range container.Ports {
+			if port.Name == "http" {
+				return int(port.ContainerPort)
+			}
+		}
+	}
+	return 0
+}
@@ -0,0 +1,296 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/yaml"
+
+	webserverv1alpha1 "github.com/webserver/webserver-operator/api/v1alpha1"
+	webserverv1beta1 "github.com/webserver/webserver-operator/api/v1beta1"
+	"github.com/webserver/webserver-operator/controllers"
+)
+
+// Usage describes the commands of kubectl-webserver
+const Usage = `Inspect and operate Webservers.
+
+Usage:
+  kubectl webserver [-n NAMESPACE] [--context CONTEXT] COMMAND
+
+The global flags may also follow the command, e.g. "status site -n web".
+
+Commands:
+  status NAME                       Show the Webserver and its children with their conditions
+  open NAME [--port PORT]           Port-forward to a ready pod and print the URL
+  rollout history NAME              List the revisions of the Webserver's Deployment
+  rollout undo NAME [--to-revision N]
+                                    Roll the image back to the previous or given revision
+  suspend NAME                      Stop the operator from reconciling the Webserver
+  resume NAME                       Let the operator reconcile the Webserver again
+  render (NAME | -f FILE)           Print the objects the operator would apply
+  backup -o FILE [-A] [-l SELECTOR] Archive Webservers with the ConfigMaps and Secrets they reference
+  restore -f FILE [--namespace-map FROM=TO,...] [--dry-run]
+                                    Create the objects of a backup archive
+`
+
+// ErrUsage is returned for unknown commands or missing arguments
+var ErrUsage = errors.New("invalid usage")
+
+// Plugin implements the kubectl-webserver commands
+type Plugin struct {
+	// Client reads and patches objects; its scheme must include the Webserver API
+	Client client.Client
+
+	// Forwarder opens port-forwards for the open command
+	Forwarder PortForwarder
+
+	// Namespace is the namespace commands operate in
+	Namespace string
+
+	// Out receives the command output
+	Out io.Writer
+
+	// Now tells the time for render; the real clock is used when nil
+	Now func() time.Time
+}
+
+// Run dispatches args to a command
+func (p *Plugin) Run(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		return ErrUsage
+	}
+	command, args := args[0], args[1:]
+	if command == "rollout" {
+		if len(args) == 0 {
+			return ErrUsage
+		}
+		command, args = "rollout "+args[0], args[1:]
+	}
+
+	fs := flag.NewFlagSet(command, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	port := fs.Int("port", 0, "local port; a free port is chosen when 0")
+	revision := fs.Int64("to-revision", 0, "revision to roll back to; the previous one when 0")
+	file := fs.String("f", "", "Webserver manifest to render or backup archive to restore")
+	output := fs.String("o", "", "backup archive to write")
+	allNamespaces := fs.Bool("A", false, "back up Webservers of all namespaces")
+	selector := fs.String("l", "", "label selector of the Webservers to back up")
+	namespaceMap := fs.String("namespace-map", "", "comma-separated FROM=TO namespaces to restore into")
+	dryRun := fs.Bool("dry-run", false, "validate the restored objects without creating them")
+	namespace := fs.String("namespace", "", "namespace of the Webserver; overrides the one given before the command")
+	fs.StringVar(namespace, "n", "", "shorthand for --namespace")
+	positional, err := parseInterspersed(fs, args)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrUsage, err)
+	}
+	if *namespace != "" {
+		scoped := *p
+		scoped.Namespace = *namespace
+		p = &scoped
+	}
+
+	// backup and restore work on many Webservers and take no name
+	switch command {
+	case "backup":
+		if len(positional) > 0 || *output == "" || *file != "" {
+			return ErrUsage
+		}
+		return p.Backup(ctx, *output, *allNamespaces, *selector)
+	case "restore":
+		if len(positional) > 0 || *file == "" {
+			return ErrUsage
+		}
+		mapping, err := parseNamespaceMap(*namespaceMap)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrUsage, err)
+		}
+		return p.Restore(ctx, *file, mapping, *dryRun)
+	}
+	name := ""
+	if len(positional) > 0 {
+		name = positional[0]
+	}
+	if len(positional) > 1 || (name == "") == (*file == "") {
+		return ErrUsage
+	}
+	if *file != "" && command != "render" {
+		return ErrUsage
+	}
+
+	switch command {
+	case "status":
+		return p.Status(ctx, name)
+	case "open":
+		return p.Open(ctx, name, *port)
+	case "rollout history":
+		return p.RolloutHistory(ctx, name)
+	case "rollout undo":
+		return p.RolloutUndo(ctx, name, *revision)
+	case "suspend":
+		return p.setSuspended(ctx, name, true)
+	case "resume":
+		return p.setSuspended(ctx, name, false)
+	case "render":
+		if *file != "" {
+			return p.RenderFile(ctx, *file)
+		}
+		return p.Render(ctx, name)
+	default:
+		return ErrUsage
+	}
+}
+
+// parseInterspersed parses flags placed before or after the positional arguments
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		if fs.NArg() == 0 {
+			return positional, nil
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
+
+// connectionFlags select the cluster and are needed before a command runs
+var connectionFlags = map[string]bool{"context": true, "kubeconfig": true}
+
+// SplitConnectionFlags separates --context and --kubeconfig, with their
+// values, from the other arguments so they can be parsed before connecting
+// wherever they appear. Arguments after "--" are left alone.
+func SplitConnectionFlags(args []string) (connection, rest []string) {
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			return connection, append(rest, args[i:]...)
+		}
+		name, _, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if !strings.HasPrefix(arg, "-") || !connectionFlags[name] {
+			rest = append(rest, arg)
+			continue
+		}
+		connection = append(connection, arg)
+		if !hasValue && i+1 < len(args) {
+			i++
+			connection = append(connection, args[i])
+		}
+	}
+	return connection, rest
+}
+
+// getWebserver fetches a Webserver from the plugin namespace
+func (p *Plugin) getWebserver(ctx context.Context, name string) (*webserverv1alpha1.Webserver, error) {
+	webserver := &webserverv1alpha1.Webserver{}
+	if err := p.Client.Get(ctx, types.NamespacedName{Name: name, Namespace: p.Namespace}, webserver); err != nil {
+		return nil, err
+	}
+	return webserver, nil
+}
+
+// setSuspended adds or removes the suspend annotation
+func (p *Plugin) setSuspended(ctx context.Context, name string, suspended bool) error {
+	webserver, err := p.getWebserver(ctx, name)
+	if err != nil {
+		return err
+	}
+	if (webserver.Annotations[webserverv1alpha1.SuspendAnnotation] == "true") == suspended {
+		fmt.Fprintf(p.Out, "webserver/%s is already %s\n", name, suspendedWord(suspended))
+		return nil
+	}
+
+	patch := client.MergeFrom(webserver.DeepCopy())
+	if suspended {
+		if webserver.Annotations == nil {
+			webserver.Annotations = map[string]string{}
+		}
+		webserver.Annotations[webserverv1alpha1.SuspendAnnotation] = "true"
+	} else {
+		delete(webserver.Annotations, webserverv1alpha1.SuspendAnnotation)
+	}
+	if err := p.Client.Patch(ctx, webserver, patch); err != nil {
+		return err
+	}
+	fmt.Fprintf(p.Out, "webserver/%s %s\n", name, suspendedWord(suspended))
+	return nil
+}
+
+func suspendedWord(suspended bool) string {
+	if suspended {
+		return "suspended"
+	}
+	return "resumed"
+}
+
+// Render prints the objects the operator would apply for a Webserver in the cluster
+func (p *Plugin) Render(ctx context.Context, name string) error {
+	webserver, err := p.getWebserver(ctx, name)
+	if err != nil {
+		return err
+	}
+	return p.render(ctx, webserver)
+}
+
+// RenderFile prints the objects the operator would apply for a Webserver manifest
+func (p *Plugin) RenderFile(ctx context.Context, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	obj, _, err := serializer.NewCodecFactory(p.Client.Scheme()).UniversalDeserializer().Decode(data, nil, nil)
+	if err != nil {
+		return err
+	}
+
+	var webserver *webserverv1alpha1.Webserver
+	switch obj := obj.(type) {
+	case *webserverv1alpha1.Webserver:
+		webserver = obj
+	case *webserverv1beta1.Webserver:
+		webserver = &webserverv1alpha1.Webserver{}
+		if err := webserver.ConvertFrom(obj); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("%s does not contain a Webserver", path)
+	}
+	if webserver.Namespace == "" {
+		webserver.Namespace = p.Namespace
+	}
+	return p.render(ctx, webserver)
+}
+
+// render prints the children of the Webserver as a YAML stream
+func (p *Plugin) render(ctx context.Context, webserver *webserverv1alpha1.Webserver) error {
+	now := time.Now
+	if p.Now != nil {
+		now = p.Now
+	}
+	reconciler := &controllers.WebserverReconciler{Client: p.Client, Scheme: p.Client.Scheme()}
+	objects, err := reconciler.Render(ctx, webserver, now())
+	if err != nil {
+		return err
+	}
+
+	documents := make([]string, 0, len(objects))
+	for _, obj := range objects {
+		data, err := yaml.Marshal(obj)
+		if err != nil {
+			return err
+		}
+		documents = append(documents, string(data))
+	}
+	_, err = fmt.Fprint(p.Out, strings.Join(documents, "---\n"))
+	return err
+}
@@ -0,0 +1,317 @@
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	webserverv1alpha1 "github.com/webserver/webserver-operator/api/v1alpha1"
+	webserverv1beta1 "github.com/webserver/webserver-operator/api/v1beta1"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// fakeForwarder records the forwarded pod and reports a fixed local port
+type fakeForwarder struct {
+	pod        types.NamespacedName
+	remotePort int
+}
+
+func (f *fakeForwarder) Forward(_ context.Context, pod types.NamespacedName, _, remotePort int, ready chan<- int) error {
+	f.pod = pod
+	f.remotePort = remotePort
+	ready <- 18080
+	return nil
+}
+
+// newTestPlugin returns a plugin backed by a fake cluster running the site Webserver at revision 2
+func newTestPlugin(t *testing.T) (*Plugin, *bytes.Buffer) {
+	t.Helper()
+	scheme := runtime.NewScheme()
+	for _, add := range []func(*runtime.Scheme) error{clientgoscheme.AddToScheme, webserverv1alpha1.AddToScheme, webserverv1beta1.AddToScheme} {
+		if err := add(scheme); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	labels := map[string]string{"app": "webserver", "instance": "site"}
+	webserver := &webserverv1alpha1.Webserver{
+		ObjectMeta: metav1.ObjectMeta{Name: "site", Namespace: "web", UID: "webserver-uid"},
+		Spec:       webserverv1alpha1.WebserverSpec{Replicas: 1, Image: "nginx:1.27", Port: 8080},
+		Status: webserverv1alpha1.WebserverStatus{
+			Phase:         "Running",
+			ReadyReplicas: 1,
+			Conditions: []metav1.Condition{{
+				Type: "Ready", Status: metav1.ConditionTrue, Reason: "Available", Message: "All replicas are ready",
+			}},
+		},
+	}
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "site-deployment", Namespace: "web", UID: "deployment-uid", Labels: labels,
+			Annotations: map[string]string{revisionAnnotation: "2"},
+		},
+		Spec:   appsv1.DeploymentSpec{Replicas: ptr.To[int32](1)},
+		Status: appsv1.DeploymentStatus{ReadyReplicas: 1},
+	}
+	replicaSet := func(name, rev, image string, replicas int32) *appsv1.ReplicaSet {
+		return &appsv1.ReplicaSet{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: name, Namespace: "web", UID: types.UID(name), Labels: labels,
+				Annotations:       map[string]string{revisionAnnotation: rev},
+				CreationTimestamp: metav1.NewTime(time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)),
+			},
+			Spec: appsv1.ReplicaSetSpec{
+				Replicas: ptr.To(replicas),
+				Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "webserver", Image: image}}}},
+			},
+			Status: appsv1.ReplicaSetStatus{ReadyReplicas: replicas},
+		}
+	}
+	oldReplicaSet := replicaSet("site-deployment-old", "1", "nginx:1.25", 0)
+	newReplicaSet := replicaSet("site-deployment-new", "2", "nginx:1.27", 1)
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "site-deployment-new-abcde", Namespace: "web", Labels: labels},
+		Spec: corev1.PodSpec{Containers: []corev1.Container{{
+			Name: "webserver", Ports: []corev1.ContainerPort{{Name: "http", ContainerPort: 8080}},
+		}}},
+		Status: corev1.PodStatus{
+			Phase:             corev1.PodRunning,
+			Conditions:        []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}},
+			ContainerStatuses: []corev1.ContainerStatus{{Name: "webserver", RestartCount: 2}},
+		},
+	}
+	configmap := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "site-config", Namespace: "web", Labels: labels}}
+	service := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "site-service", Namespace: "web", Labels: labels},
+		Spec:       corev1.ServiceSpec{Type: corev1.ServiceTypeClusterIP, ClusterIP: "10.96.0.10"},
+	}
+
+	for _, owned := range []struct{ owner, obj client.Object }{
+		{webserver, deployment}, {webserver, configmap}, {webserver, service},
+		{deployment, oldReplicaSet}, {deployment, newReplicaSet}, {newReplicaSet, pod},
+	} {
+		if err := controllerutil.SetControllerReference(owned.owner, owned.obj, scheme); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	c := fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(webserver, deployment, oldReplicaSet, newReplicaSet, pod, configmap, service).
+		Build()
+	out := &bytes.Buffer{}
+	return &Plugin{
+		Client:    c,
+		Forwarder: &fakeForwarder{},
+		Namespace: "web",
+		Out:       out,
+		Now:       func() time.Time { return time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC) },
+	}, out
+}
+
+func TestStatusTree(t *testing.T) {
+	p, out := newTestPlugin(t)
+
+	if err := p.Run(context.Background(), []string{"status", "site"}); err != nil {
+		t.Fatalf("status error = %v", err)
+	}
+
+	want := `Webserver/site Running, 1/1 ready
+│   Ready=True Available: All replicas are ready
+├── Deployment/site-deployment 1/1 ready
+│   ├── ReplicaSet/site-deployment-new revision 2, 1/1 ready
+│   │   └── Pod/site-deployment-new-abcde Running, ready, 2 restarts
+│   └── ReplicaSet/site-deployment-old revision 1, 0/0 ready
+├── ConfigMap/site-config
+└── Service/site-service ClusterIP 10.96.0.10
+`
+	if out.String() != want {
+		t.Errorf("Expected tree\n%s\ngot\n%s", want, out.String())
+	}
+}
+
+func TestRolloutHistory(t *testing.T) {
+	p, out := newTestPlugin(t)
+
+	if err := p.Run(context.Background(), []string{"rollout", "history", "site"}); err != nil {
+		t.Fatalf("rollout history error = %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[1], "1 ") || !strings.Contains(lines[1], "nginx:1.25") ||
+		!strings.HasPrefix(lines[2], "2 (current)") || !strings.Contains(lines[2], "nginx:1.27") {
+		t.Errorf("Expected both revisions oldest first, got\n%s", out.String())
+	}
+}
+
+func TestRolloutUndo(t *testing.T) {
+	p, out := newTestPlugin(t)
+
+	if err := p.Run(context.Background(), []string{"rollout", "undo", "site"}); err != nil {
+		t.Fatalf("rollout undo error = %v", err)
+	}
+
+	webserver, err := p.getWebserver(context.Background(), "site")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if webserver.Spec.Image != "nginx:1.25" {
+		t.Errorf("Expected the image of revision 1, got %s", webserver.Spec.Image)
+	}
+	if !strings.Contains(out.String(), "rolled back to revision 1") {
+		t.Errorf("Expected a rollback message, got %q", out.String())
+	}
+
+	if err := p.Run(context.Background(), []string{"rollout", "undo", "site", "--to-revision", "7"}); err == nil {
+		t.Error("Expected an error for an unknown revision")
+	}
+}
+
+func TestSuspendResume(t *testing.T) {
+	p, _ := newTestPlugin(t)
+
+	if err := p.Run(context.Background(), []string{"suspend", "site"}); err != nil {
+		t.Fatalf("suspend error = %v", err)
+	}
+	webserver, err := p.getWebserver(context.Background(), "site")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if webserver.Annotations[webserverv1alpha1.SuspendAnnotation] != "true" {
+		t.Errorf("Expected the suspend annotation, got %v", webserver.Annotations)
+	}
+
+	if err := p.Run(context.Background(), []string{"resume", "site"}); err != nil {
+		t.Fatalf("resume error = %v", err)
+	}
+	if webserver, err = p.getWebserver(context.Background(), "site"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := webserver.Annotations[webserverv1alpha1.SuspendAnnotation]; ok {
+		t.Errorf("Expected the suspend annotation to be removed, got %v", webserver.Annotations)
+	}
+}
+
+func TestOpen(t *testing.T) {
+	p, out := newTestPlugin(t)
+
+	if err := p.Run(context.Background(), []string{"open", "site", "--port", "18080"}); err != nil {
+		t.Fatalf("open error = %v", err)
+	}
+
+	forwarder := p.Forwarder.(*fakeForwarder)
+	if forwarder.pod.Name != "site-deployment-new-abcde" || forwarder.remotePort != 8080 {
+		t.Errorf("Expected a forward to port 8080 of the ready pod, got %v:%d", forwarder.pod, forwarder.remotePort)
+	}
+	if !strings.Contains(out.String(), "http://localhost:18080") {
+		t.Errorf("Expected the local URL, got %q", out.String())
+	}
+}
+
+func TestRender(t *testing.T) {
+	p, out := newTestPlugin(t)
+
+	if err := p.Run(context.Background(), []string{"render", "site"}); err != nil {
+		t.Fatalf("render error = %v", err)
+	}
+
+	documents := strings.Split(out.String(), "---\n")
+	if len(documents) != 3 {
+		t.Fatalf("Expected a Deployment, ConfigMap and Service, got %d documents", len(documents))
+	}
+	for i, want := range []string{"kind: Deployment", "kind: ConfigMap", "kind: Service"} {
+		if !strings.Contains(documents[i], want) {
+			t.Errorf("Expected document %d to be a %s, got\n%s", i, want, documents[i])
+		}
+	}
+	if !strings.Contains(documents[0], "image: nginx:1.27") {
+		t.Errorf("Expected the Webserver image in the Deployment, got\n%s", documents[0])
+	}
+}
+
+func TestRenderFile(t *testing.T) {
+	p, out := newTestPlugin(t)
+	path := filepath.Join(t.TempDir(), "webserver.yaml")
+	manifest := `apiVersion: webserver.io/v1beta1
+kind: Webserver
+metadata:
+  name: docs
+spec:
+  replicas: 3
+  image: nginx:1.27
+`
+	if err := os.WriteFile(path, []byte(manifest), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := p.Run(context.Background(), []string{"render", "-f", path}); err != nil {
+		t.Fatalf("render error = %v", err)
+	}
+
+	if !strings.Contains(out.String(), "name: docs-deployment") || !strings.Contains(out.String(), "namespace: web") {
+		t.Errorf("Expected the Deployment of docs in the plugin namespace, got\n%s", out.String())
+	}
+	if !strings.Contains(out.String(), "kind: PodDisruptionBudget") {
+		t.Errorf("Expected a PodDisruptionBudget for 3 replicas, got\n%s", out.String())
+	}
+}
+
+func TestNamespaceFlagAfterCommand(t *testing.T) {
+	p, out := newTestPlugin(t)
+	p.Namespace = "default"
+
+	if err := p.Run(context.Background(), []string{"status", "site", "-n", "web"}); err != nil {
+		t.Fatalf("status error = %v", err)
+	}
+	if !strings.HasPrefix(out.String(), "Webserver/site Running") {
+		t.Errorf("Expected the Webserver in web, got\n%s", out.String())
+	}
+	path := filepath.Join(t.TempDir(), "backup.tar.gz")
+	if err := p.Run(context.Background(), []string{"backup", "--namespace=web", "-o", path}); err != nil {
+		t.Fatalf("backup error = %v", err)
+	}
+	manifest, _, err := readArchive(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if manifest.Namespace != "web" || len(manifest.Objects) == 0 {
+		t.Errorf("Expected the Webservers of web to be backed up, got %+v", manifest)
+	}
+	if p.Namespace != "default" {
+		t.Errorf("Expected the flag to apply to one command only, got namespace %q", p.Namespace)
+	}
+}
+
+func TestSplitConnectionFlags(t *testing.T) {
+	connection, rest := SplitConnectionFlags([]string{"status", "site", "--context", "prod", "-n", "web", "-kubeconfig=/tmp/config", "--", "--context"})
+	if strings.Join(connection, " ") != "--context prod -kubeconfig=/tmp/config" {
+		t.Errorf("Expected the context and kubeconfig flags, got %v", connection)
+	}
+	if strings.Join(rest, " ") != "status site -n web -- --context" {
+		t.Errorf("Expected the command arguments to remain, got %v", rest)
+	}
+}
+
+func TestRunUsage(t *testing.T) {
+	p, _ := newTestPlugin(t)
+
+	for _, args := range [][]string{nil, {"restart", "site"}, {"rollout"}, {"status"}, {"status", "site", "extra"}, {"open", "-f", "x.yaml"}} {
+		if err := p.Run(context.Background(), args); !errors.Is(err, ErrUsage) {
+			t.Errorf("Expected a usage error for %v, got %v", args, err)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/portforward"
+	"k8s.io/client-go/transport/spdy"
+)
+
+// PortForwarder forwards a local port to a pod
+type PortForwarder interface {
+	// Forward forwards localPort, or a free port when zero, to remotePort of
+	// the pod and sends the local port on ready once it listens. It blocks
+	// until ctx is done or the connection fails.
+	Forward(ctx context.Context, pod types.NamespacedName, localPort, remotePort int, ready chan<- int) error
+}
+
+// SPDYForwarder forwards ports through the API server like kubectl port-forward
+type SPDYForwarder struct {
+	// Config is the REST config of the cluster
+	Config *rest.Config
+
+	// ErrOut receives errors of individual forwarded connections
+	ErrOut io.Writer
+}
+
+// Forward implements PortForwarder
+func (f *SPDYForwarder) Forward(ctx context.Context, pod types.NamespacedName, localPort, remotePort int, ready chan<- int) error {
+	transport, upgrader, err := spdy.RoundTripperFor(f.Config)
+	if err != nil {
+		return err
+	}
+	clientset, err := kubernetes.NewForConfig(f.Config)
+	if err != nil {
+		return err
+	}
+	url := clientset.CoreV1().RESTClient().Post().Resource("pods").Namespace(pod.Namespace).Name(pod.Name).SubResource("portforward").URL()
+	dialer := spdy.NewDialer(upgrader, &http.Client{Transport: transport}, http.MethodPost, url)
+
+	stop := make(chan struct{})
+	listening := make(chan struct{})
+	forwarder, err := portforward.NewOnAddresses(dialer, []string{"localhost"},
+		[]string{fmt.Sprintf("%d:%d", localPort, remotePort)}, stop, listening, io.Discard, f.ErrOut)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		select {
+		case <-listening:
+			ports, err := forwarder.GetPorts()
+			if err == nil && len(ports) > 0 {
+				ready <- int(ports[0].Local)
+			}
+		case <-ctx.Done():
+		}
+		<-ctx.Done()
+		close(stop)
+	}()
+	return forwarder.ForwardPorts()
+}
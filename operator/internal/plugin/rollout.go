@@ -0,0 +1,126 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+
+	appsv1 "k8s.io/api/apps/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	webserverv1alpha1 "github.com/webserver/webserver-operator/api/v1alpha1"
+)
+
+// revisionAnnotation is set by the Deployment controller on every ReplicaSet
+const revisionAnnotation = "deployment.kubernetes.io/revision"
+
+// revision returns the Deployment revision of a ReplicaSet
+func revision(replicaSet *appsv1.ReplicaSet) int64 {
+	value, _ := strconv.ParseInt(replicaSet.Annotations[revisionAnnotation], 10, 64)
+	return value
+}
+
+// revisions returns the ReplicaSets of the Webserver's Deployment, oldest first
+func (p *Plugin) revisions(ctx context.Context, webserver *webserverv1alpha1.Webserver) (*appsv1.Deployment, []*appsv1.ReplicaSet, error) {
+	deployment := &appsv1.Deployment{}
+	key := types.NamespacedName{Name: webserver.Name + "-deployment", Namespace: webserver.Namespace}
+	if err := p.Client.Get(ctx, key, deployment); err != nil {
+		return nil, nil, err
+	}
+
+	replicaSets := &appsv1.ReplicaSetList{}
+	if err := p.Client.List(ctx, replicaSets, client.InNamespace(webserver.Namespace), client.MatchingLabels{"instance": webserver.Name}); err != nil {
+		return nil, nil, err
+	}
+	var owned []*appsv1.ReplicaSet
+	for i := range replicaSets.Items {
+		if metav1.IsControlledBy(&replicaSets.Items[i], deployment) {
+			owned = append(owned, &replicaSets.Items[i])
+		}
+	}
+	sort.Slice(owned, func(i, j int) bool { return revision(owned[i]) < revision(owned[j]) })
+	return deployment, owned, nil
+}
+
+// webserverImage returns the image of the web server container in a ReplicaSet
+func webserverImage(replicaSet *appsv1.ReplicaSet) string {
+	for _, container := range replicaSet.Spec.Template.Spec.Containers {
+		if container.Name == "webserver" {
+			return container.Image
+		}
+	}
+	return ""
+}
+
+// RolloutHistory lists the revisions of the Webserver's Deployment
+func (p *Plugin) RolloutHistory(ctx context.Context, name string) error {
+	webserver, err := p.getWebserver(ctx, name)
+	if err != nil {
+		return err
+	}
+	deployment, replicaSets, err := p.revisions(ctx, webserver)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(p.Out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "REVISION\tIMAGE\tREADY\tCREATED\t")
+	for _, replicaSet := range replicaSets {
+		current := ""
+		if replicaSet.Annotations[revisionAnnotation] == deployment.Annotations[revisionAnnotation] {
+			current = " (current)"
+		}
+		fmt.Fprintf(w, "%d%s\t%s\t%d\t%s\t\n", revision(replicaSet), current, webserverImage(replicaSet),
+			replicaSet.Status.ReadyReplicas, replicaSet.CreationTimestamp.UTC().Format("2006-01-02 15:04:05"))
+	}
+	return w.Flush()
+}
+
+// RolloutUndo sets spec.image of the Webserver to the image of an earlier
+// revision. The operator owns the Deployment, so rolling back its template
+// directly would be reverted on the next reconcile.
+func (p *Plugin) RolloutUndo(ctx context.Context, name string, toRevision int64) error {
+	webserver, err := p.getWebserver(ctx, name)
+	if err != nil {
+		return err
+	}
+	deployment, replicaSets, err := p.revisions(ctx, webserver)
+	if err != nil {
+		return err
+	}
+
+	current, _ := strconv.ParseInt(deployment.Annotations[revisionAnnotation], 10, 64)
+	var target *appsv1.ReplicaSet
+	for _, replicaSet := range replicaSets {
+		rev := revision(replicaSet)
+		if (toRevision == 0 && rev < current) || rev == toRevision {
+			target = replicaSet
+		}
+	}
+	if target == nil {
+		if toRevision == 0 {
+			return fmt.Errorf("webserver/%s has no revision before %d", name, current)
+		}
+		return fmt.Errorf("webserver/%s has no revision %d", name, toRevision)
+	}
+	image := webserverImage(target)
+	if image == "" {
+		return fmt.Errorf("revision %d has no webserver container", revision(target))
+	}
+	if image == webserver.Spec.Image {
+		fmt.Fprintf(p.Out, "webserver/%s already runs %s\n", name, image)
+		return nil
+	}
+
+	patch := client.MergeFrom(webserver.DeepCopy())
+	webserver.Spec.Image = image
+	if err := p.Client.Patch(ctx, webserver, patch); err != nil {
+		return err
+	}
+	fmt.Fprintf(p.Out, "webserver/%s rolled back to revision %d (%s)\n", name, revision(target), image)
+	return nil
+}
@@ -0,0 +1,212 @@
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// node is one object in the status tree
+type node struct {
+	label    string
+	details  []string
+	children []*node
+}
+
+// Status prints the Webserver and the objects it owns as a tree
+func (p *Plugin) Status(ctx context.Context, name string) error {
+	webserver, err := p.getWebserver(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	root := &node{
+		label:   fmt.Sprintf("Webserver/%s %s, %d/%d ready", webserver.Name, orUnknown(webserver.Status.Phase), webserver.Status.ReadyReplicas, webserver.Spec.Replicas),
+		details: conditionLines(webserver.Status.Conditions),
+	}
+	inNamespace := client.InNamespace(webserver.Namespace)
+	byInstance := client.MatchingLabels{"instance": webserver.Name}
+
+	deployments := &appsv1.DeploymentList{}
+	if err := p.Client.List(ctx, deployments, inNamespace, byInstance); err != nil {
+		return err
+	}
+	replicaSets := &appsv1.ReplicaSetList{}
+	if err := p.Client.List(ctx, replicaSets, inNamespace, byInstance); err != nil {
+		return err
+	}
+	pods := &corev1.PodList{}
+	if err := p.Client.List(ctx, pods, inNamespace, byInstance); err != nil {
+		return err
+	}
+	for i := range deployments.Items {
+		deployment := &deployments.Items[i]
+		if !metav1.IsControlledBy(deployment, webserver) {
+			continue
+		}
+		root.children = append(root.children, deploymentNode(deployment, replicaSets.Items, pods.Items))
+	}
+
+	configmaps := &corev1.ConfigMapList{}
+	if err := p.Client.List(ctx, configmaps, inNamespace, byInstance); err != nil {
+		return err
+	}
+	for i := range configmaps.Items {
+		if metav1.IsControlledBy(&configmaps.Items[i], webserver) {
+			root.children = append(root.children, &node{label: "ConfigMap/" + configmaps.Items[i].Name})
+		}
+	}
+
+	services := &corev1.ServiceList{}
+	if err := p.Client.List(ctx, services, inNamespace, byInstance); err != nil {
+		return err
+	}
+	for i := range services.Items {
+		service := &services.Items[i]
+		if metav1.IsControlledBy(service, webserver) {
+			root.children = append(root.children, &node{
+				label: fmt.Sprintf("Service/%s %s %s", service.Name, service.Spec.Type, service.Spec.ClusterIP),
+			})
+		}
+	}
+
+	pdbs := &policyv1.PodDisruptionBudgetList{}
+	if err := p.Client.List(ctx, pdbs, inNamespace, byInstance); err != nil {
+		return err
+	}
+	for i := range pdbs.Items {
+		pdb := &pdbs.Items[i]
+		if metav1.IsControlledBy(pdb, webserver) {
+			root.children = append(root.children, &node{
+				label: fmt.Sprintf("PodDisruptionBudget/%s %d disruptions allowed", pdb.Name, pdb.Status.DisruptionsAllowed),
+			})
+		}
+	}
+
+	printTree(p.Out, root, "", "")
+	return nil
+}
+
+// deploymentNode shows a Deployment with its ReplicaSets and their pods, newest revision first
+func deploymentNode(deployment *appsv1.Deployment, replicaSets []appsv1.ReplicaSet, pods []corev1.Pod) *node {
+	desired := int32(1)
+	if deployment.Spec.Replicas != nil {
+		desired = *deployment.Spec.Replicas
+	}
+	n := &node{
+		label:   fmt.Sprintf("Deployment/%s %d/%d ready", deployment.Name, deployment.Status.ReadyReplicas, desired),
+		details: deploymentConditionLines(deployment.Status.Conditions),
+	}
+
+	var owned []*appsv1.ReplicaSet
+	for i := range replicaSets {
+		if metav1.IsControlledBy(&replicaSets[i], deployment) {
+			owned = append(owned, &replicaSets[i])
+		}
+	}
+	sort.Slice(owned, func(i, j int) bool { return revision(owned[i]) > revision(owned[j]) })
+
+	for _, replicaSet := range owned {
+		replicas := int32(0)
+		if replicaSet.Spec.Replicas != nil {
+			replicas = *replicaSet.Spec.Replicas
+		}
+		child := &node{label: fmt.Sprintf("ReplicaSet/%s revision %d, %d/%d ready", replicaSet.Name, revision(replicaSet), replicaSet.Status.ReadyReplicas, replicas)}
+		for i := range pods {
+			if metav1.IsControlledBy(&pods[i], replicaSet) {
+				child.children = append(child.children, podNode(&pods[i]))
+			}
+		}
+		n.children = append(n.children, child)
+	}
+	return n
+}
+
+// podNode shows a pod with its readiness, restarts and container problems
+func podNode(pod *corev1.Pod) *node {
+	ready := "not ready"
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodReady && condition.Status == corev1.ConditionTrue {
+			ready = "ready"
+		}
+	}
+	restarts := int32(0)
+	var details []string
+	for _, status := range pod.Status.ContainerStatuses {
+		restarts += status.RestartCount
+		if waiting := status.State.Waiting; waiting != nil && waiting.Reason != "" {
+			details = append(details, fmt.Sprintf("%s: %s %s", status.Name, waiting.Reason, waiting.Message))
+		}
+	}
+	return &node{
+		label:   fmt.Sprintf("Pod/%s %s, %s, %d restarts", pod.Name, pod.Status.Phase, ready, restarts),
+		details: details,
+	}
+}
+
+// conditionLines formats conditions as "Type=Status Reason: Message"
+func conditionLines(conditions []metav1.Condition) []string {
+	var lines []string
+	for _, condition := range conditions {
+		lines = append(lines, conditionLine(condition.Type, string(condition.Status), condition.Reason, condition.Message))
+	}
+	return lines
+}
+
+func deploymentConditionLines(conditions []appsv1.DeploymentCondition) []string {
+	var lines []string
+	for _, condition := range conditions {
+		lines = append(lines, conditionLine(string(condition.Type), string(condition.Status), condition.Reason, condition.Message))
+	}
+	return lines
+}
+
+func conditionLine(conditionType, status, reason, message string) string {
+	line := fmt.Sprintf("%s=%s %s", conditionType, status, reason)
+	if message != "" {
+		line += ": " + message
+	}
+	return line
+}
+
+func orUnknown(phase string) string {
+	if phase == "" {
+		return "Unknown"
+	}
+	return phase
+}
+
+// printTree writes n and its children with box-drawing guides
+func printTree(out io.Writer, n *node, prefix, branch string) {
+	fmt.Fprintf(out, "%s%s%s\n", prefix, branch, n.label)
+
+	childPrefix := prefix
+	switch branch {
+	case "├── ":
+		childPrefix += "│   "
+	case "└── ":
+		childPrefix += "    "
+	}
+	guide := "    "
+	if len(n.children) > 0 {
+		guide = "│   "
+	}
+	for _, detail := range n.details {
+		fmt.Fprintf(out, "%s%s%s\n", childPrefix, guide, strings.TrimSpace(detail))
+	}
+	for i, child := range n.children {
+		if i == len(n.children)-1 {
+			printTree(out, child, childPrefix, "└── ")
+		} else {
+			printTree(out, child, childPrefix, "├── ")
+		}
+	}
+}
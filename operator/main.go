@@ -1,23 +1,47 @@
 package main
 
 import (
+	"context"
 	"flag"
+	"net/http"
 	"os"
+	"time"
+
+	// Embed the time zone database so schedule time zones resolve in minimal images
+	_ "time/tzdata"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
 	_ "k8s.io/client-go/plugin/pkg/client/auth"
 
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+	"golang.org/x/time/rate"
+	corev1 "k8s.io/api/core/v1"
+	apiextensionsv1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/util/workqueue"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/cache"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
 	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
 	metricsserver "sigs.k8s.io/controller-runtime/pkg/metrics/server"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	webserverv1alpha1 "github.com/webserver/webserver-operator/api/v1alpha1"
+	webserverv1beta1 "github.com/webserver/webserver-operator/api/v1beta1"
 	"github.com/webserver/webserver-operator/controllers"
+	operatorconfig "github.com/webserver/webserver-operator/internal/config"
+	"github.com/webserver/webserver-operator/internal/debug"
 	//+kubebuilder:scaffold:imports
 )
 
@@ -29,19 +53,19 @@ var (
 func init() {
 	utilruntime.Must(clientgoscheme.AddToScheme(scheme))
 
+	utilruntime.Must(apiextensionsv1.AddToScheme(scheme))
+
 	utilruntime.Must(webserverv1alpha1.AddToScheme(scheme))
+	utilruntime.Must(webserverv1beta1.AddToScheme(scheme))
 	//+kubebuilder:scaffold:scheme
 }
 
 func main() {
-	var metricsAddr string
-	var enableLeaderElection bool
-	var probeAddr string
-	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
-	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
-	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
-		"Enable leader election for controller manager. "+
-			"Enabling this will ensure there is only one active controller manager.")
+	var configFile string
+	flag.StringVar(&configFile, "config", "",
+		"Path to an OperatorConfig file. Flags set on the command line override values from the file.")
+	// Flags are bound to a scratch config; config.Load replays the ones set explicitly
+	operatorconfig.BindFlags(flag.CommandLine, operatorconfig.Default())
 	opts := zap.Options{
 		Development: true,
 	}
@@ -50,25 +74,129 @@ func main() {
 
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
 
+	cfg, err := operatorconfig.Load(configFile, flag.CommandLine)
+	if err != nil {
+		setupLog.Error(err, "invalid operator configuration")
+		os.Exit(1)
+	}
+
+	cacheOptions, err := cacheOptionsFor(cfg)
+	if err != nil {
+		setupLog.Error(err, "invalid cache configuration")
+		os.Exit(1)
+	}
+
+	ctx := ctrl.SetupSignalHandler()
+	tracerProvider, shutdownTracing, err := tracerProviderFor(ctx, cfg.Tracing)
+	if err != nil {
+		setupLog.Error(err, "unable to set up tracing")
+		os.Exit(1)
+	}
+	defer shutdownTracing()
+
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
 		Scheme:                 scheme,
-		Metrics:                metricsserver.Options{BindAddress: metricsAddr},
-		HealthProbeBindAddress: probeAddr,
-		LeaderElection:         enableLeaderElection,
-		LeaderElectionID:       "f1c5ece8.webserver.io",
+		Cache:                  cacheOptions,
+		Metrics:                metricsserver.Options{BindAddress: cfg.MetricsBindAddress},
+		HealthProbeBindAddress: cfg.HealthProbeBindAddress,
+		LeaderElection:         cfg.LeaderElection.Enabled,
+		LeaderElectionID:       cfg.LeaderElection.ID,
+		LeaseDuration:          &cfg.LeaderElection.LeaseDuration.Duration,
+		RenewDeadline:          &cfg.LeaderElection.RenewDeadline.Duration,
+		RetryPeriod:            &cfg.LeaderElection.RetryPeriod.Duration,
 	})
 	if err != nil {
 		setupLog.Error(err, "unable to start manager")
 		os.Exit(1)
 	}
 
-	if err = (&controllers.WebserverReconciler{
+	reconciler := &controllers.WebserverReconciler{
 		Client: mgr.GetClient(),
 		Scheme: mgr.GetScheme(),
-	}).SetupWithManager(mgr); err != nil {
+		ImagePolicy: controllers.ImagePolicy{
+			AllowedRegistries: cfg.ImagePolicy.AllowedRegistries,
+			ForbidLatest:      cfg.ImagePolicy.ForbidLatestTag,
+			ResolveDigests:    cfg.ImagePolicy.ResolveDigests,
+		},
+		Resolver: &controllers.RegistryDigestResolver{
+			Client:             &http.Client{Timeout: 10 * time.Second},
+			InsecureRegistries: cfg.ImagePolicy.InsecureRegistries,
+		},
+		Fetcher:                 &controllers.HTTPContentFetcher{Timeout: 30 * time.Second},
+		DefaultImage:            cfg.Defaults.Image,
+		MetricsExporterImage:    cfg.Defaults.MetricsExporterImage,
+		InheritedLabelPrefixes:  cfg.Propagation.LabelPrefixes,
+		ResyncPeriod:            cfg.Controller.ResyncPeriod.Duration,
+		MaxConcurrentReconciles: cfg.Controller.MaxConcurrentReconciles,
+		RateLimiter:             rateLimiter(cfg.Controller.RateLimiter),
+		Tracer:                  tracerProvider.Tracer(controllers.TracerName),
+		Recorder:                mgr.GetEventRecorderFor("webserver-controller"),
+		History:                 controllers.NewReconcileHistory(),
+	}
+	if cfg.Enabled(operatorconfig.ContentVerification) {
+		reconciler.Verifier = &controllers.HTTPContentVerifier{Timeout: 10 * time.Second}
+	}
+	reconciler.ServiceMonitors, err = controllers.ServiceMonitorsAvailable(mgr.GetRESTMapper())
+	if err != nil {
+		setupLog.Error(err, "unable to discover the ServiceMonitor API")
+		os.Exit(1)
+	}
+	setupLog.Info("ServiceMonitor support", "enabled", reconciler.ServiceMonitors)
+	if err = reconciler.SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "Webserver")
 		os.Exit(1)
 	}
+	if err = (&controllers.WebserverSetReconciler{
+		Client:                  mgr.GetClient(),
+		Scheme:                  mgr.GetScheme(),
+		ResyncPeriod:            cfg.Controller.ResyncPeriod.Duration,
+		MaxConcurrentReconciles: cfg.Controller.MaxConcurrentReconciles,
+		RateLimiter:             rateLimiter(cfg.Controller.RateLimiter),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "WebserverSet")
+		os.Exit(1)
+	}
+	if err = (&controllers.WebserverQuotaReconciler{
+		Client:                  mgr.GetClient(),
+		Scheme:                  mgr.GetScheme(),
+		MaxConcurrentReconciles: cfg.Controller.MaxConcurrentReconciles,
+		RateLimiter:             rateLimiter(cfg.Controller.RateLimiter),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "WebserverQuota")
+		os.Exit(1)
+	}
+	if os.Getenv("ENABLE_WEBHOOKS") != "false" {
+		if err = (&webserverv1beta1.Webserver{}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "Webserver")
+			os.Exit(1)
+		}
+		if err = (&controllers.WebserverQuotaValidator{Client: mgr.GetClient()}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "WebserverQuota")
+			os.Exit(1)
+		}
+	}
+	if cfg.Enabled(operatorconfig.StorageVersionMigration) {
+		if err = mgr.Add(&controllers.StorageVersionMigrator{
+			Client:  mgr.GetClient(),
+			Reader:  mgr.GetAPIReader(),
+			CRDName: "webservers.webserver.io",
+		}); err != nil {
+			setupLog.Error(err, "unable to set up storage version migration")
+			os.Exit(1)
+		}
+	}
+	if cfg.Debug.BindAddress != "" {
+		if err = mgr.Add(&debug.Server{
+			BindAddress:    cfg.Debug.BindAddress,
+			History:        reconciler.History,
+			ForceReconcile: reconciler.ForceReconcile,
+			Gatherer:       metrics.Registry,
+			Elected:        mgr.Elected(),
+		}); err != nil {
+			setupLog.Error(err, "unable to set up the debug server")
+			os.Exit(1)
+		}
+	}
 	//+kubebuilder:scaffold:builder
 
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
@@ -80,9 +208,78 @@ func main() {
 		os.Exit(1)
 	}
 
-	setupLog.Info("starting manager")
-	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
+	setupLog.Info("starting manager", "namespaces", cfg.Cache.Namespaces, "labelSelector", cfg.Cache.LabelSelector)
+	if err := mgr.Start(ctx); err != nil {
 		setupLog.Error(err, "problem running manager")
+		shutdownTracing()
 		os.Exit(1)
 	}
 }
+
+// tracerProviderFor exports spans to the configured OTLP/HTTP collector. A
+// no-op provider is returned when no endpoint is set. The returned function
+// flushes pending spans.
+func tracerProviderFor(ctx context.Context, cfg operatorconfig.TracingConfig) (trace.TracerProvider, func(), error) {
+	if cfg.Endpoint == "" {
+		return noop.NewTracerProvider(), func() {}, nil
+	}
+
+	options := []otlptracehttp.Option{otlptracehttp.WithEndpoint(cfg.Endpoint)}
+	if cfg.Insecure {
+		options = append(options, otlptracehttp.WithInsecure())
+	}
+	exporter, err := otlptracehttp.New(ctx, options...)
+	if err != nil {
+		return nil, nil, err
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SamplingRatio))),
+		sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceName(cfg.ServiceName))),
+	)
+	shutdown := func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := provider.Shutdown(ctx); err != nil {
+			setupLog.Error(err, "unable to flush spans")
+		}
+	}
+	return provider, shutdown, nil
+}
+
+// cacheOptionsFor scopes the manager cache to the configured namespaces and
+// restricts the cached Webservers to the configured label selector. Objects
+// owned by Webservers are not filtered by that selector, so the operator keeps
+// seeing its own Deployments, Services and ConfigMaps. Only web server pods
+// are cached.
+func cacheOptionsFor(cfg *operatorconfig.OperatorConfig) (cache.Options, error) {
+	options := cache.Options{
+		ByObject: map[client.Object]cache.ByObject{
+			&corev1.Pod{}: {Label: labels.SelectorFromSet(labels.Set{"app": "webserver"})},
+		},
+	}
+	if len(cfg.Cache.Namespaces) > 0 {
+		options.DefaultNamespaces = map[string]cache.Config{}
+		for _, namespace := range cfg.Cache.Namespaces {
+			options.DefaultNamespaces[namespace] = cache.Config{}
+		}
+	}
+	if cfg.Cache.LabelSelector != "" {
+		selector, err := labels.Parse(cfg.Cache.LabelSelector)
+		if err != nil {
+			return options, err
+		}
+		options.ByObject[&webserverv1alpha1.Webserver{}] = cache.ByObject{Label: selector}
+	}
+	return options, nil
+}
+
+// rateLimiter builds the retry rate limiter of one controller: a per-object
+// exponential backoff capped by an overall token bucket. Every controller
+// needs its own instance since the limiter tracks the failures of its objects.
+func rateLimiter(cfg operatorconfig.RateLimiterConfig) workqueue.TypedRateLimiter[reconcile.Request] {
+	return workqueue.NewTypedMaxOfRateLimiter(
+		workqueue.NewTypedItemExponentialFailureRateLimiter[reconcile.Request](cfg.BaseDelay.Duration, cfg.MaxDelay.Duration),
+		&workqueue.TypedBucketRateLimiter[reconcile.Request]{Limiter: rate.NewLimiter(rate.Limit(cfg.QPS), cfg.Burst)},
+	)
+}
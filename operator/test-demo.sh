@@ -55,9 +55,9 @@ echo "✅ Web server deployment is ready"
 echo "📊 Checking status..."
 make status
 
-# Get service info
-echo "🌐 Service information:"
-kubectl get service webserver-sample-service
+# Show what the operator would apply
+echo "🧾 Rendered children:"
+bin/kubectl-webserver render webserver-sample | grep -E '^(kind|  name):'
 
 echo ""
 echo "🎉 Demo setup complete!"